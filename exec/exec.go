@@ -1,6 +1,7 @@
 package exec
 
 import (
+	"bytes"
 	"io"
 	"os"
 	"os/exec"
@@ -20,6 +21,18 @@ func ExecCommand(program string, args ...string) error {
 	return cmd.Run()
 }
 
+// Start a command with content written to its standard input and wait
+// for it to finish, capturing its standard output and error
+func CaptureCommand(input, program string, args ...string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(program, args...)
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
+
 // Open the configured pager and a writer for Stdin.
 // When the process is complete, close the writer and
 // invoke Wait() on the command.
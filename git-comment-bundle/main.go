@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	gg "git"
+	"github.com/kylef/result.go/src/result"
+	kp "gopkg.in/alecthomas/kingpin.v2"
+	gc "libgitcomment"
+	"os"
+	gs "search"
+)
+
+var (
+	buildVersion  string
+	app           = kp.New("git-comment-bundle", "Move comments between repositories without a network connection")
+	createCmd     = app.Command("create", "Write comments to a bundle file")
+	createFile    = createCmd.Arg("file", "Path of the bundle to create").Required().String()
+	createRange   = createCmd.Arg("revision range", "Commits for which to bundle comments, defaulting to all comments").String()
+	unbundleCmd   = app.Command("unbundle", "Add the comments within a bundle file to the repository")
+	unbundleFile  = unbundleCmd.Arg("file", "Path of the bundle to read").Required().ExistingFile()
+	unbundleQuiet = unbundleCmd.Flag("quiet", "Only print the number of comments added, skipped, and orphaned").Short('q').Bool()
+)
+
+func main() {
+	app.Version(buildVersion)
+	dir, args, err := gg.StartDirectory(os.Args[1:])
+	app.FatalIfError(err, "pwd")
+	location, err := gg.DiscoverRepository(dir)
+	app.FatalIfError(err, "git")
+	pwd := location.RepoPath
+	fatalIfError(app, gc.VersionCheck(pwd, buildVersion), "version")
+	switch kp.MustParse(app.Parse(args)) {
+	case "create":
+		if len(*createRange) > 0 {
+			*createRange = location.Revision(*createRange)
+		}
+		createBundle(pwd)
+	case "unbundle":
+		file, err := os.Open(*unbundleFile)
+		app.FatalIfError(err, "unbundle")
+		defer file.Close()
+		summary := fatalIfError(app, gc.UnbundleComments(pwd, file), "unbundle")
+		gs.RefreshIndex(pwd)
+		printSummary(summary.(*gc.UnbundleSummary))
+	}
+}
+
+func createBundle(pwd string) {
+	file, err := os.Create(*createFile)
+	app.FatalIfError(err, "create")
+	bundled := gc.CreateCommentBundle(pwd, *createRange, file)
+	file.Close()
+	if bundled.Failure != nil {
+		os.Remove(*createFile)
+	}
+	comments := fatalIfError(app, bundled, "create")
+	fmt.Printf("Bundled %d comments\n", len(comments.(gc.CommentSlice)))
+}
+
+func printSummary(summary *gc.UnbundleSummary) {
+	if !*unbundleQuiet {
+		printComments("added", summary.Added)
+		printComments("skipped", summary.Skipped)
+		printComments("orphaned", summary.Orphaned)
+	}
+	fmt.Printf("Added %d comments, skipped %d, orphaned %d\n", len(summary.Added), len(summary.Skipped), len(summary.Orphaned))
+}
+
+func printComments(status string, comments gc.CommentSlice) {
+	for _, comment := range comments {
+		fmt.Printf("%-8v %v %v %v\n", status, (*comment.Commit)[:7], (*comment.ID)[:7], comment.Title())
+	}
+}
+
+// Return the success value, otherwise kill the app with
+// the error code specified
+func fatalIfError(app *kp.Application, r result.Result, code string) interface{} {
+	app.FatalIfError(r.Failure, code)
+	return r.Success
+}
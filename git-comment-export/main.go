@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	gg "git"
+	"github.com/kylef/result.go/src/result"
+	kp "gopkg.in/alecthomas/kingpin.v2"
+	gc "libgitcomment"
+	"os"
+	"strings"
+)
+
+const (
+	toGerrit           = "gerrit"
+	toAppraise         = "appraise"
+	toNotes            = "notes"
+	formatSeparator    = "="
+	missingNotesError  = "No notes reference provided, such as notes=refs/notes/comments"
+	unknownFormatError = "Unknown export format '%v'"
+)
+
+var (
+	buildVersion string
+	app          = kp.New("git-comment-export", "Export comments to other code review systems")
+	to           = app.Flag("to", "Format of the exported comments: gerrit, appraise, notes=<ref>").Required().String()
+	revision     = app.Arg("revision", "Commit to export comments from, for formats which export a single commit").Default("HEAD").String()
+)
+
+func main() {
+	app.Version(buildVersion)
+	dir, args, err := gg.StartDirectory(os.Args[1:])
+	app.FatalIfError(err, "pwd")
+	kp.MustParse(app.Parse(args))
+	location, err := gg.DiscoverRepository(dir)
+	app.FatalIfError(err, "git")
+	pwd := location.RepoPath
+	*revision = location.Revision(*revision)
+	fatalIfError(app, gc.VersionCheck(pwd, buildVersion), "version")
+	format, option := splitFormat(*to)
+	switch format {
+	case toGerrit:
+		review := fatalIfError(app, gc.ExportGerritReview(pwd, *revision), "export")
+		printJSON(review)
+	case toAppraise:
+		comments := fatalIfError(app, gc.ExportAppraiseComments(pwd), "export")
+		fmt.Printf("Exported %d comments\n", len(comments.(gc.CommentSlice)))
+	case toNotes:
+		if len(option) == 0 {
+			app.FatalIfError(errors.New(missingNotesError), "export")
+		}
+		summary := fatalIfError(app, gc.ExportNotes(pwd, gc.ExpandNotesRef(option)), "export").(*gc.NotesSummary)
+		fmt.Printf("Wrote notes for %d commits\n", summary.Written)
+		if summary.Removed > 0 {
+			fmt.Printf("Removed notes from %d commits without comments\n", summary.Removed)
+		}
+	default:
+		app.FatalIfError(fmt.Errorf(unknownFormatError, *to), "export")
+	}
+}
+
+// Split a format into its name and option, such as the notes
+// reference of `notes=<ref>`
+func splitFormat(format string) (string, string) {
+	parts := strings.SplitN(format, formatSeparator, 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return parts[0], ""
+}
+
+func printJSON(value interface{}) {
+	content, err := json.MarshalIndent(value, "", "  ")
+	app.FatalIfError(err, "json")
+	fmt.Println(string(content))
+}
+
+// Return the success value, otherwise kill the app with
+// the error code specified
+func fatalIfError(app *kp.Application, r result.Result, code string) interface{} {
+	app.FatalIfError(r.Failure, code)
+	return r.Success
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"encoding/json"
 	gx "exec"
 	"fmt"
 	gc "libgitcomment"
 	"path/filepath"
+	gs "search"
 	"strings"
+	"time"
 )
 
 type Formatter struct {
@@ -16,33 +19,166 @@ func NewFormatter(useColor bool) *Formatter {
 	return &Formatter{useColor}
 }
 
-func (f *Formatter) FormatComment(c *gc.Comment, highlight string) string {
-	return fmt.Sprintf("%v  %v\n",
-		gx.Colorize(gx.Cyan, f.formatHeader(c), f.useColor),
-		f.formatTitle(c, highlight))
+func (f *Formatter) FormatHit(hit *gs.SearchHit) string {
+	output := fmt.Sprintf("%v  %v\n",
+		gx.Colorize(gx.Cyan, f.formatHeader(hit.Comment, hit.Sources), f.useColor),
+		f.formatTitle(hit.Comment))
+	if len(hit.Snippet) > 0 {
+		output += fmt.Sprintf("  %v\n", f.formatSnippet(hit.Snippet))
+	}
+	return output
+}
+
+// Format a similar comment with its similarity as a percentage
+func (f *Formatter) FormatSimilar(similar *gs.SimilarComment) string {
+	return fmt.Sprintf("%v  %3.0f%%  %v\n",
+		gx.Colorize(gx.Cyan, f.formatHeader(similar.Comment, similar.Sources), f.useColor),
+		similar.Similarity*100,
+		f.formatTitle(similar.Comment))
+}
+
+// Summarize which matching comments are shown
+func (f *Formatter) FormatTotal(results *gs.SearchResults, offset int) string {
+	if len(results.Hits) == results.Total {
+		return fmt.Sprintf("%d matching comments\n", results.Total)
+	} else if len(results.Hits) == 0 {
+		return fmt.Sprintf("No comments shown of %d matching comments\n", results.Total)
+	}
+	return fmt.Sprintf("Showing %d-%d of %d matching comments\n",
+		offset+1, offset+len(results.Hits), results.Total)
+}
+
+// Format the counts of a facet, one value per line
+func (f *Formatter) FormatFacet(facet *gs.FacetCounts) string {
+	output := fmt.Sprintf("\n%v\n", gx.Colorize(gx.Cyan, string(facet.Facet), f.useColor))
+	for _, count := range facet.Counts {
+		output += fmt.Sprintf("%7d  %v\n", count.Count, count.Value)
+	}
+	if facet.Missing > 0 {
+		output += fmt.Sprintf("%7d  (none)\n", facet.Missing)
+	}
+	return output
+}
+
+// A matching comment as printed in JSON
+type jsonHit struct {
+	ID          string    `json:"id"`
+	AuthorName  string    `json:"author_name"`
+	AuthorEmail string    `json:"author_email"`
+	Created     time.Time `json:"created"`
+	Commit      string    `json:"commit"`
+	Path        string    `json:"path,omitempty"`
+	Line        int       `json:"line,omitempty"`
+	Title       string    `json:"title"`
+	Score       float64   `json:"score"`
+	Snippet     string    `json:"snippet,omitempty"`
+	Sources     []string  `json:"sources"`
+}
+
+type jsonResults struct {
+	Total    int               `json:"total"`
+	Comments []*jsonHit        `json:"comments"`
+	Facets   []*gs.FacetCounts `json:"facets,omitempty"`
+}
+
+// Format search results and facet counts as JSON
+func (f *Formatter) FormatJSON(results *gs.SearchResults) (string, error) {
+	output := &jsonResults{results.Total, make([]*jsonHit, 0, len(results.Hits)), results.Facets}
+	for _, hit := range results.Hits {
+		c := hit.Comment
+		comment := &jsonHit{
+			ID:          *c.ID,
+			AuthorName:  c.Author.Name,
+			AuthorEmail: c.Author.Email,
+			Created:     c.Author.Date,
+			Commit:      *c.Commit,
+			Title:       strings.Split(c.Content, "\n")[0],
+			Score:       hit.Score,
+			Snippet:     strings.NewReplacer(gs.MatchStart, "", gs.MatchEnd, "").Replace(hit.Snippet),
+			Sources:     hit.Sources,
+		}
+		if c.FileRef != nil {
+			comment.Path, comment.Line = c.FileRef.Path, c.FileRef.Line
+		}
+		output.Comments = append(output.Comments, comment)
+	}
+	content, err := json.MarshalIndent(output, "", "  ")
+	return string(content), err
 }
 
-func (f *Formatter) formatHeader(c *gc.Comment) string {
+func (f *Formatter) formatHeader(c *gc.Comment, sources []string) string {
 	var path string
 	if c.FileRef != nil {
 		_, path = filepath.Split(c.FileRef.Serialize())
 	}
 	name := c.Author.Name
-	return fmt.Sprintf("%v %v %v:%v\n",
+	return fmt.Sprintf("%v %v %v:%v%v\n",
 		name,
 		c.Author.Date.Format("2006-01-02"),
 		(*c.Commit)[:7],
-		path)
+		path,
+		f.formatSources(sources))
 }
 
-func (f *Formatter) formatTitle(c *gc.Comment, highlight string) string {
+// Tag comments fetched from remotes with where they were found
+func (f *Formatter) formatSources(sources []string) string {
+	if len(sources) == 0 || len(sources) == 1 && sources[0] == gs.LocalSource {
+		return ""
+	}
+	return fmt.Sprintf(" [%v]", strings.Join(sources, ", "))
+}
+
+// The abbreviated ID of a comment, prefixed by a remote name if the
+// comment is not local
+func (f *Formatter) formatID(id string, sources []string) string {
+	for _, source := range sources {
+		if source == gs.LocalSource {
+			return id[:7]
+		}
+	}
+	if len(sources) > 0 {
+		return fmt.Sprintf("%v/%v", sources[0], id[:7])
+	}
+	return id[:7]
+}
+
+func (f *Formatter) formatTitle(c *gc.Comment) string {
 	lines := strings.Split(c.Content, "\n")
 	title := lines[0]
 	if len(lines) > 1 {
 		title = fmt.Sprintf("%v...", title)
 	}
+	return title
+}
+
+// Format a line of comment content as `<comment>:<line>:<content>`, or
+// with `-` separators for context lines
+func (f *Formatter) FormatGrepLine(match *gs.GrepMatch, line *gs.GrepLine) string {
+	separator := "-"
+	if len(line.Matches) > 0 {
+		separator = ":"
+	}
+	content, current := "", 0
+	for _, match := range line.Matches {
+		content += line.Content[current:match[0]]
+		content += gx.Colorize(gx.Red, line.Content[match[0]:match[1]], f.useColor)
+		current = match[1]
+	}
+	content += line.Content[current:]
+	return fmt.Sprintf("%v%v%d%v%v\n",
+		gx.Colorize(gx.Cyan, f.formatID(*match.Comment.ID, match.Sources), f.useColor),
+		separator, line.Number, separator, content)
+}
+
+func (f *Formatter) FormatGrepCount(match *gs.GrepMatch) string {
+	return fmt.Sprintf("%v:%d\n", gx.Colorize(gx.Cyan, f.formatID(*match.Comment.ID, match.Sources), f.useColor), match.Count)
+}
+
+// Color the matched terms within a snippet
+func (f *Formatter) formatSnippet(snippet string) string {
+	start, end := "", ""
 	if f.useColor {
-		title = strings.Replace(title, highlight, gx.Colorize(gx.Red, highlight, true), -1)
+		start, end = gx.Red, gx.Clear
 	}
-	return title
+	return strings.NewReplacer(gs.MatchStart, start, gs.MatchEnd, end).Replace(snippet)
 }
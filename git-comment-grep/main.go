@@ -8,48 +8,143 @@ import (
 	kp "gopkg.in/alecthomas/kingpin.v2"
 	gc "libgitcomment"
 	"os"
+	gs "search"
+	"strings"
 )
 
 var (
 	buildVersion string
 	app          = kp.New("git-comment-grep", "Index and look for comments")
-	findCmd      = app.Command("find", "Look for comments containing text")
-	indexCmd     = app.Command("index", "Index and cache comment content")
+	findCmd      = app.Command("find", "Look for comments matching a query")
+	indexCmd     = app.Command("index", "Rebuild the index of comment content")
+	grepCmd      = app.Command("grep", "Look for comment content matching a pattern without using the index").Default()
+	similarCmd   = app.Command("similar", "Look for comments with content similar to a comment")
 	noPager      = app.Flag("nopager", "Disable pager").Bool()
 	noColor      = app.Flag("nocolor", "Disable color").Bool()
-	text         = findCmd.Arg("text", "Search text").Required().String()
+	findAuthor   = findCmd.Flag("author", "Only comments by an author name or email").String()
+	findPath     = findCmd.Flag("path", "Only comments on a file or directory").String()
+	findSince    = findCmd.Flag("since", "Only comments created on or after a date").String()
+	findRemote   = findCmd.Flag("remote", "Only comments fetched from a remote, or local comments").String()
+	findSort     = findCmd.Flag("sort", "Order comments by score, date, or author").Default(string(gs.SortByScore)).Enum(string(gs.SortByScore), string(gs.SortByDate), string(gs.SortByAuthor))
+	findLimit    = findCmd.Flag("limit", "Maximum number of comments to print").Int()
+	findOffset   = findCmd.Flag("offset", "Number of matching comments to skip").Int()
+	findFacets   = findCmd.Flag("facets", "Count matching comments by author, path, or month").String()
+	findJSON     = findCmd.Flag("json", "Print matching comments and counts as JSON").Bool()
+	text         = findCmd.Arg("query", "Search text and field filters").Strings()
+	grepRegexp   = grepCmd.Flag("extended-regexp", "Match the pattern as a regular expression, the default").Short('E').Bool()
+	grepFixed    = grepCmd.Flag("fixed-strings", "Match the pattern as a fixed string").Short('F').Bool()
+	ignoreCase   = grepCmd.Flag("ignore-case", "Ignore case differences").Short('i').Bool()
+	wordRegexp   = grepCmd.Flag("word-regexp", "Only match whole words").Short('w').Bool()
+	countOnly    = grepCmd.Flag("count", "Print the number of matching lines in each comment").Short('c').Bool()
+	listOnly     = grepCmd.Flag("comments-with-matches", "Print only the IDs of matching comments").Short('l').Bool()
+	afterLines   = grepCmd.Flag("after-context", "Print lines of context after matches").Short('A').Int()
+	beforeLines  = grepCmd.Flag("before-context", "Print lines of context before matches").Short('B').Int()
+	contextLines = grepCmd.Flag("context", "Print lines of context before and after matches").Short('C').Int()
+	grepRemote   = grepCmd.Flag("remote", "Only comments fetched from a remote, or local comments").String()
+	grepPattern  = grepCmd.Arg("pattern", "Regular expression or fixed string to match").Required().String()
+	grepRange    = grepCmd.Arg("revision range", "Only comments on commits in a range").String()
+	similarLimit = similarCmd.Flag("limit", "Maximum number of comments to print").Default("10").Int()
+	minSimilar   = similarCmd.Flag("min-similarity", "Minimum similarity of comments to print, from 0 to 1").Default("0.3").Float64()
+	similarID    = similarCmd.Arg("comment", "ID of the comment").Required().String()
 )
 
 func main() {
 	app.Version(buildVersion)
-	pwd, err := os.Getwd()
+	// A leading -C is the context option of the default grep command
+	// rather than a directory to start in, which `git -C <path>` gives
+	dir, err := os.Getwd()
 	app.FatalIfError(err, "pwd")
+	command := kp.MustParse(app.Parse(os.Args[1:]))
+	location, err := gg.DiscoverRepository(dir)
+	app.FatalIfError(err, "git")
+	pwd := location.RepoPath
 	fatalIfError(app, gc.VersionCheck(pwd, buildVersion), "version")
-	switch kp.MustParse(app.Parse(os.Args[1:])) {
+	switch command {
 	case "find":
-		findText(pwd, *text)
+		findText(pwd, strings.Join(*text, " "))
 	case "index":
 		indexComments(pwd)
+	case "grep":
+		if len(*grepRange) > 0 {
+			*grepRange = location.Revision(*grepRange)
+		}
+		grepComments(pwd)
+	case "similar":
+		options := &gs.SimilarOptions{MinSimilarity: *minSimilar, Limit: *similarLimit}
+		fatalIfError(app, newPrinter(pwd).PrintSimilarComments(pwd, *similarID, options), "similar")
 	}
 }
 
 func findText(wd, text string) {
+	query, err := gs.ParseQuery(text)
+	app.FatalIfError(err, "query")
+	addFilter(query, "author", *findAuthor, "")
+	addFilter(query, "path", *findPath, "")
+	addFilter(query, "created", *findSince, ">=")
+	addFilter(query, "remote", *findRemote, "")
+	facets, err := gs.ParseFacets(*findFacets)
+	app.FatalIfError(err, "facets")
+	options := &gs.SearchOptions{Sort: gs.SortOrder(*findSort), Offset: *findOffset, Limit: *findLimit, Facets: facets}
+	if *findJSON {
+		results := fatalIfError(app, gs.CommentsMatching(wd, query, options), "find").(*gs.SearchResults)
+		content, err := NewFormatter(false).FormatJSON(results)
+		app.FatalIfError(err, "json")
+		fmt.Println(content)
+		return
+	}
+	fatalIfError(app, newPrinter(wd).PrintCommentsMatching(wd, query, options), "find")
+}
+
+func grepComments(wd string) {
+	options := &gs.GrepOptions{
+		Pattern:    *grepPattern,
+		Fixed:      *grepFixed,
+		IgnoreCase: *ignoreCase,
+		WordRegexp: *wordRegexp,
+		Before:     *beforeLines,
+		After:      *afterLines,
+		Remote:     *grepRemote,
+	}
+	if *contextLines > 0 {
+		options.Before, options.After = *contextLines, *contextLines
+	}
+	matches := fatalIfError(app, gs.GrepComments(wd, *grepRange, options), "grep").([]*gs.GrepMatch)
+	printer := newPrinter(wd)
+	if *listOnly {
+		printer.PrintMatchingIDs(matches)
+	} else if *countOnly {
+		printer.PrintMatchCounts(matches)
+	} else {
+		printer.PrintMatchingLines(matches, options.Before+options.After > 0)
+	}
+	if len(matches) == 0 {
+		os.Exit(1)
+	}
+}
+
+func newPrinter(wd string) *Printer {
 	termHeight, _ := gx.CalculateDimensions()
 	var useColor bool
 	if !*noColor {
 		useColor = gg.ConfiguredBool(wd, "color.pager", false)
 	}
 	pager := gx.NewPager(app, wd, gg.ConfiguredPager(wd), termHeight, *noPager)
-	printer := NewPrinter(useColor, pager)
-	fatalIfError(app, printer.PrintCommentsMatching(wd, text), "find")
+	return NewPrinter(useColor, pager)
 }
 
 func indexComments(wd string) {
 	fmt.Printf("Indexing...")
-	fatalIfError(app, IndexComments(wd), "index")
+	fatalIfError(app, gs.IndexComments(wd), "index")
 	fmt.Printf("done\n")
 }
 
+// Restrict a query using the value of a flag, if provided
+func addFilter(query *gs.Query, field, value, operator string) {
+	if len(value) > 0 {
+		app.FatalIfError(query.AddFilter(field, operator+value), "query")
+	}
+}
+
 // Return the success value, otherwise kill the app with
 // the error code specified
 func fatalIfError(app *kp.Application, r result.Result, code string) interface{} {
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/stvp/assert"
+	"testing"
+)
+
+func TestParseLeadingContextOption(t *testing.T) {
+	command, err := app.Parse([]string{"-C", "2", "-E", "foo"})
+	assert.Nil(t, err)
+	assert.Equal(t, command, "grep")
+	assert.Equal(t, *contextLines, 2)
+	assert.True(t, *grepRegexp)
+	assert.Equal(t, *grepPattern, "foo")
+}
+
+func TestParseBarePattern(t *testing.T) {
+	*grepRange = ""
+	command, err := app.Parse([]string{"TODO"})
+	assert.Nil(t, err)
+	assert.Equal(t, command, "grep")
+	assert.Equal(t, *grepPattern, "TODO")
+	assert.Equal(t, *grepRange, "")
+}
+
+func TestParsePatternAndRange(t *testing.T) {
+	command, err := app.Parse([]string{"-F", "a.b", "master~10..master"})
+	assert.Nil(t, err)
+	assert.Equal(t, command, "grep")
+	assert.True(t, *grepFixed)
+	assert.Equal(t, *grepPattern, "a.b")
+	assert.Equal(t, *grepRange, "master~10..master")
+}
@@ -2,8 +2,9 @@ package main
 
 import (
 	gx "exec"
+	"fmt"
 	"github.com/kylef/result.go/src/result"
-	gc "libgitcomment"
+	gs "search"
 )
 
 type Printer struct {
@@ -15,12 +16,57 @@ func NewPrinter(useColor bool, pager *gx.Pager) *Printer {
 	return &Printer{&Formatter{useColor}, pager}
 }
 
-func (p *Printer) PrintCommentsMatching(wd, text string) result.Result {
-	return CommentsWithContent(wd, text).FlatMap(func(matches interface{}) result.Result {
-		for _, comment := range matches.([]*gc.Comment) {
-			p.pager.AddContent(p.formatter.FormatComment(comment, text))
+func (p *Printer) PrintCommentsMatching(wd string, query *gs.Query, options *gs.SearchOptions) result.Result {
+	return gs.CommentsMatching(wd, query, options).FlatMap(func(value interface{}) result.Result {
+		results := value.(*gs.SearchResults)
+		for _, hit := range results.Hits {
+			p.pager.AddContent(p.formatter.FormatHit(hit))
+		}
+		p.pager.AddContent(p.formatter.FormatTotal(results, options.Offset))
+		for _, facet := range results.Facets {
+			p.pager.AddContent(p.formatter.FormatFacet(facet))
+		}
+		p.pager.Finish()
+		return result.NewSuccess(true)
+	})
+}
+
+func (p *Printer) PrintSimilarComments(wd, id string, options *gs.SimilarOptions) result.Result {
+	return gs.SimilarComments(wd, id, options).FlatMap(func(value interface{}) result.Result {
+		for _, similar := range value.([]*gs.SimilarComment) {
+			p.pager.AddContent(p.formatter.FormatSimilar(similar))
 		}
 		p.pager.Finish()
 		return result.NewSuccess(true)
 	})
 }
+
+// Print the matching lines of each comment, separating groups of lines
+// when context is shown
+func (p *Printer) PrintMatchingLines(matches []*gs.GrepMatch, separateGroups bool) {
+	for index, match := range matches {
+		for lineIndex, line := range match.Lines {
+			newGroup := lineIndex == 0 && index > 0 ||
+				lineIndex > 0 && line.Number > match.Lines[lineIndex-1].Number+1
+			if separateGroups && newGroup {
+				p.pager.AddContent("--\n")
+			}
+			p.pager.AddContent(p.formatter.FormatGrepLine(match, line))
+		}
+	}
+	p.pager.Finish()
+}
+
+func (p *Printer) PrintMatchCounts(matches []*gs.GrepMatch) {
+	for _, match := range matches {
+		p.pager.AddContent(p.formatter.FormatGrepCount(match))
+	}
+	p.pager.Finish()
+}
+
+func (p *Printer) PrintMatchingIDs(matches []*gs.GrepMatch) {
+	for _, match := range matches {
+		p.pager.AddContent(fmt.Sprintf("%v\n", *match.Comment.ID))
+	}
+	p.pager.Finish()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	gg "git"
+	"github.com/kylef/result.go/src/result"
+	kp "gopkg.in/alecthomas/kingpin.v2"
+	gc "libgitcomment"
+	"os"
+	gs "search"
+	"strings"
+)
+
+const (
+	fromGitHub         = "github"
+	fromGitLab         = "gitlab"
+	fromGerrit         = "gerrit"
+	fromAppraise       = "appraise"
+	fromNotes          = "notes"
+	formatSeparator    = "="
+	unknownFormatError = "Unknown import format '%v'"
+	missingFileError   = "No file provided to import from"
+)
+
+var (
+	buildVersion string
+	app          = kp.New("git-comment-import", "Import comments from other code review systems")
+	from         = app.Flag("from", "Format of the imported comments: github, gitlab, gerrit, appraise, notes[=<ref>]").Required().String()
+	commit       = app.Flag("commit", "Commit the comments were made on, for formats which omit it").Default("HEAD").String()
+	file         = app.Arg("file", "File containing exported comments").String()
+)
+
+func main() {
+	app.Version(buildVersion)
+	dir, args, err := gg.StartDirectory(os.Args[1:])
+	app.FatalIfError(err, "pwd")
+	kp.MustParse(app.Parse(args))
+	location, err := gg.DiscoverRepository(dir)
+	app.FatalIfError(err, "git")
+	pwd := location.RepoPath
+	*commit = location.Revision(*commit)
+	fatalIfError(app, gc.VersionCheck(pwd, buildVersion), "version")
+	format, option := splitFormat(*from)
+	switch format {
+	case fromGitHub:
+		summary := fatalIfError(app, gc.ImportGitHubComments(pwd, openFile()), "import")
+		printSummary(summary.(*gc.ImportSummary))
+	case fromGitLab:
+		summary := fatalIfError(app, gc.ImportGitLabComments(pwd, openFile()), "import")
+		printSummary(summary.(*gc.ImportSummary))
+	case fromGerrit:
+		summary := fatalIfError(app, gc.ImportGerritComments(pwd, openFile(), *commit), "import")
+		printSummary(summary.(*gc.ImportSummary))
+	case fromAppraise:
+		summary := fatalIfError(app, gc.ImportAppraiseComments(pwd), "import")
+		printSummary(summary.(*gc.ImportSummary))
+	case fromNotes:
+		summary := fatalIfError(app, gc.ImportNotes(pwd, gc.ExpandNotesRef(option)), "import")
+		printSummary(summary.(*gc.ImportSummary))
+	default:
+		app.FatalIfError(fmt.Errorf(unknownFormatError, *from), "import")
+	}
+	gs.RefreshIndex(pwd)
+}
+
+// Split a format into its name and option, such as the notes
+// reference of `notes=<ref>`
+func splitFormat(format string) (string, string) {
+	parts := strings.SplitN(format, formatSeparator, 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return parts[0], ""
+}
+
+func openFile() *os.File {
+	if len(*file) == 0 {
+		app.FatalIfError(errors.New(missingFileError), "io")
+	}
+	content, err := os.Open(*file)
+	app.FatalIfError(err, "io")
+	return content
+}
+
+func printSummary(summary *gc.ImportSummary) {
+	fmt.Printf("Imported %d comments\n", len(summary.Added))
+	if len(summary.Skipped) > 0 {
+		fmt.Printf("Skipped %d previously imported comments\n", len(summary.Skipped))
+	}
+	if len(summary.Orphaned) > 0 {
+		fmt.Printf("Skipped %d comments on unknown commits\n", len(summary.Orphaned))
+	}
+	if len(summary.Invalid) > 0 {
+		fmt.Printf("Failed to import %d invalid comments, such as comments without content\n", len(summary.Invalid))
+	}
+}
+
+// Return the success value, otherwise kill the app with
+// the error code specified
+func fatalIfError(app *kp.Application, r result.Result, code string) interface{} {
+	app.FatalIfError(r.Failure, code)
+	return r.Success
+}
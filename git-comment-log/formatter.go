@@ -27,6 +27,10 @@ const (
 	titleLine            = "%t"
 	newLine              = "%n"
 	dividerLine          = "%d"
+	signaturePrefix      = "%G"
+	signatureStatus      = "%G?"
+	signatureSigner      = "%GS"
+	signatureKey         = "%GK"
 	black                = "black("
 	red                  = "red("
 	green                = "green("
@@ -60,6 +64,9 @@ type Formatter struct {
 	termWidth      uint16
 	colorMapping   map[string]string
 	indent         string
+	// Check the signature of a comment when formatting signature
+	// placeholders
+	Verifier func(comment *gc.Comment) *gc.Verification
 }
 
 func NewFormatter(format string, useLineNumbers, useColor, useMargin bool, termWidth uint16) *Formatter {
@@ -93,7 +100,7 @@ func NewFormatter(format string, useLineNumbers, useColor, useMargin bool, termW
 			resetColor: "",
 		}
 	}
-	return &Formatter{format, useLineNumbers, useColor, useMargin, termWidth, colorMapping, indent}
+	return &Formatter{format, useLineNumbers, useColor, useMargin, termWidth, colorMapping, indent, nil}
 }
 
 func (f *Formatter) FormatLine(line *gc.DiffLine) string {
@@ -200,6 +207,9 @@ func (f *Formatter) formatLineContent(line *gc.DiffLine) string {
 }
 
 func (f *Formatter) substituteVariables(format string, comment *gc.Comment) string {
+	if strings.Contains(format, signaturePrefix) {
+		format = replaceAll(format, f.signatureMapping(comment))
+	}
 	return replaceAll(replaceAll(format, f.commentMapping(comment)), f.colorMapping)
 }
 
@@ -232,6 +242,18 @@ func (f *Formatter) commentMapping(comment *gc.Comment) map[string]string {
 	}
 }
 
+func (f *Formatter) signatureMapping(comment *gc.Comment) map[string]string {
+	verification := &gc.Verification{Status: gc.SignatureNone}
+	if f.Verifier != nil {
+		verification = f.Verifier(comment)
+	}
+	return map[string]string{
+		signatureStatus: string(verification.Status),
+		signatureSigner: verification.Signer,
+		signatureKey:    verification.Key,
+	}
+}
+
 func replaceAll(format string, substitutions map[string]string) string {
 	for key, value := range substitutions {
 		format = strings.Replace(format, key, value, -1)
@@ -196,3 +196,16 @@ func comment() *gc.Comment {
 	comment.ID = &id
 	return comment
 }
+
+func TestPrettyFormatUnsignedComment(t *testing.T) {
+	formatter := NewFormatter("format:%G?", false, false, false, 0)
+	assert.Equal(t, formatter.FormatComment(comment()), "N\n\n\n")
+}
+
+func TestPrettyFormatSignature(t *testing.T) {
+	formatter := NewFormatter("format:%G? %GK %GS", false, false, false, 0)
+	formatter.Verifier = func(comment *gc.Comment) *gc.Verification {
+		return &gc.Verification{gc.SignatureGood, "Simon <iceking@example.com>", "4AEE18F83AFDEB23", ""}
+	}
+	assert.Equal(t, formatter.FormatComment(comment()), "G 4AEE18F83AFDEB23 Simon <iceking@example.com>\n\n\n")
+}
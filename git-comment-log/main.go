@@ -33,9 +33,13 @@ var (
 
 func main() {
 	app.Version(buildVersion)
-	kp.MustParse(app.Parse(os.Args[1:]))
-	pwd, err := os.Getwd()
+	dir, args, err := gg.StartDirectory(os.Args[1:])
 	app.FatalIfError(err, "pwd")
+	kp.MustParse(app.Parse(args))
+	location, err := gg.DiscoverRepository(dir)
+	app.FatalIfError(err, "git")
+	pwd := location.RepoPath
+	*revision = location.Revision(*revision)
 	fatalIfError(app, gc.VersionCheck(pwd, buildVersion), "version")
 	showComments(pwd)
 }
@@ -56,7 +60,12 @@ func newFormatter(wd string, termWidth uint16) *Formatter {
 	if *enableColor {
 		useColor = gg.ConfiguredBool(wd, "color.pager", false)
 	}
-	return NewFormatter(*pretty, *lineNumbers, useColor, *enableMarginLine, termWidth)
+	formatter := NewFormatter(*pretty, *lineNumbers, useColor, *enableMarginLine, termWidth)
+	formatter.Verifier = func(comment *gc.Comment) *gc.Verification {
+		failed := &gc.Verification{Status: gc.SignatureCannotCheck}
+		return gc.VerifyCommentSignature(wd, comment).Recover(failed).(*gc.Verification)
+	}
+	return formatter
 }
 
 func newPrinter(pager *gx.Pager, formatter *Formatter) *DiffPrinter {
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	gg "git"
+	kp "gopkg.in/alecthomas/kingpin.v2"
+	gc "libgitcomment"
+	"os"
+)
+
+const (
+	pusherEnvironmentVariable = "GIT_COMMENT_PUSHER"
+	rejectedFormat            = "rejected %v: %v\n"
+	rejectedError             = "%d comment reference updates rejected"
+)
+
+var (
+	buildVersion string
+	app          = kp.New("git-comment-receive-hook", "Validate comments pushed to a repository from a pre-receive or update hook")
+	pusher       = app.Flag("pusher", "Email address which new comments must be authored or amended by").OverrideDefaultFromEnvar(pusherEnvironmentVariable).String()
+	refName      = app.Arg("ref", "Updated reference, when run as an update hook").String()
+	oldID        = app.Arg("old", "Previous object ID of the reference").String()
+	newID        = app.Arg("new", "New object ID of the reference").String()
+)
+
+func main() {
+	app.Version(buildVersion)
+	dir, args, err := gg.StartDirectory(os.Args[1:])
+	app.FatalIfError(err, "pwd")
+	kp.MustParse(app.Parse(args))
+	location, err := gg.DiscoverRepository(dir)
+	app.FatalIfError(err, "git")
+	pwd := location.RepoPath
+	objects := &repositoryObjects{pwd}
+	rejected := 0
+	for _, update := range refUpdates() {
+		if err := gc.ValidateRefUpdate(objects, update, *pusher); err != nil {
+			fmt.Fprintf(os.Stderr, rejectedFormat, update.Name, err)
+			rejected += 1
+		}
+	}
+	if rejected > 0 {
+		app.Fatalf(rejectedError, rejected)
+	}
+}
+
+// Read the updated references from the arguments of an update hook,
+// otherwise from the input of a pre-receive hook
+func refUpdates() []*gc.RefUpdate {
+	if len(*refName) > 0 {
+		return []*gc.RefUpdate{&gc.RefUpdate{*refName, *oldID, *newID}}
+	}
+	updates, err := gc.ParseRefUpdates(os.Stdin)
+	app.FatalIfError(err, "input")
+	return updates
+}
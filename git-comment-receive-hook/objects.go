@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	gx "exec"
+	gg "git"
+	gc "libgitcomment"
+	"strings"
+)
+
+// Reads objects using git rather than libgit2, so that objects
+// quarantined while a push is received are visible
+type repositoryObjects struct {
+	path string
+}
+
+func (r *repositoryObjects) ReadBlob(identifier string) (string, error) {
+	return r.git("cat-file", "blob", identifier)
+}
+
+func (r *repositoryObjects) HasCommit(hash string) bool {
+	_, err := r.git("cat-file", "-e", hash+"^{commit}")
+	return err == nil
+}
+
+func (r *repositoryObjects) CommentsOnCommit(hash string) (gc.CommentSlice, error) {
+	dir, err := gg.CommitRefDir(hash).Dematerialize()
+	if err != nil {
+		return nil, err
+	}
+	output, err := r.git("for-each-ref", "--format=%(objectname)", dir.(string)+"/")
+	if err != nil {
+		return nil, err
+	}
+	comments := make(gc.CommentSlice, 0)
+	for _, identifier := range strings.Fields(output) {
+		content, err := r.ReadBlob(identifier)
+		if err != nil {
+			return nil, err
+		}
+		comment := gc.DeserializeComment(content).Success.(*gc.Comment)
+		id := identifier
+		comment.ID = &id
+		comments = append(comments, comment)
+	}
+	return comments, nil
+}
+
+func (r *repositoryObjects) git(args ...string) (string, error) {
+	output, stderr, err := gx.CaptureCommand("", "git", append([]string{"-C", r.path}, args...)...)
+	if err != nil {
+		if message := strings.TrimSpace(stderr); len(message) > 0 {
+			return "", errors.New(message)
+		}
+		return "", err
+	}
+	return output, nil
+}
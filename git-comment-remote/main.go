@@ -2,10 +2,12 @@ package main
 
 import (
 	"fmt"
+	gg "git"
 	"github.com/kylef/result.go/src/result"
 	kp "gopkg.in/alecthomas/kingpin.v2"
 	gc "libgitcomment"
 	"os"
+	gs "search"
 )
 
 var (
@@ -13,23 +15,106 @@ var (
 	app           = kp.New("git-comment-remote", "Helper commands for the merge workflow")
 	configCmd     = app.Command("config", "Configure remote to fetch and push comments by default")
 	configRemote  = configCmd.Arg("remote", "Remote to configure").Required().String()
-	deleteCmd     = app.Command("delete", "Delete remote copy of a comment")
-	deleteRemote  = deleteCmd.Arg("remote", "Remote from which to delete comment").Required().String()
-	deleteComment = deleteCmd.Arg("comment", "Comment to delete").Required().String()
+	deleteCmd     = app.Command("delete", "Delete remote copies of comments")
+	deleteRemote  = deleteCmd.Arg("remote", "Remote from which to delete comments").Required().String()
+	deleteComment = deleteCmd.Arg("comment", "Comments to delete").Required().Strings()
+	fetchCmd      = app.Command("fetch", "Fetch and merge comments on a range of commits")
+	fetchRemote   = fetchCmd.Arg("remote", "Remote from which to fetch comments").Required().String()
+	fetchRange    = fetchCmd.Arg("revision range", "Commits for which to fetch comments").Required().String()
+	pruneCmd      = app.Command("prune", "Delete remote copies of comments which were deleted locally")
+	pruneRemote   = pruneCmd.Arg("remote", "Remote from which to delete comments").Required().String()
+	pruneDryRun   = pruneCmd.Flag("dry-run", "List the comments which would be deleted without deleting them").Short('n').Bool()
+	statusCmd     = app.Command("status", "Compare local comments with the comments of a remote")
+	statusRemote  = statusCmd.Arg("remote", "Remote with which to compare comments").Default("origin").String()
+	statusLive    = statusCmd.Flag("live", "Compare with the comments currently on the remote instead of those last fetched").Bool()
+	syncCmd       = app.Command("sync", "Fetch, merge and push comments")
+	syncRemote    = syncCmd.Arg("remote", "Remote with which to synchronize comments").Required().String()
+	syncPrefer    = syncCmd.Flag("prefer", "Version to keep when a comment was amended both locally and remotely: newest, local, or remote").Default(string(gc.PreferNewest)).Enum(string(gc.PreferNewest), string(gc.PreferLocal), string(gc.PreferRemote))
 )
 
 func main() {
 	app.Version(buildVersion)
-	pwd, err := os.Getwd()
+	dir, args, err := gg.StartDirectory(os.Args[1:])
 	app.FatalIfError(err, "pwd")
+	location, err := gg.DiscoverRepository(dir)
+	app.FatalIfError(err, "git")
+	pwd := location.RepoPath
 	fatalIfError(app, gc.VersionCheck(pwd, buildVersion), "version")
-	switch kp.MustParse(app.Parse(os.Args[1:])) {
+	switch kp.MustParse(app.Parse(args)) {
 	case "config":
 		app.FatalIfError(gc.ConfigureRemoteForComments(pwd, *configRemote).Failure, "git")
 		fmt.Printf("Remote '%v' updated\n", *configRemote)
 	case "delete":
-		app.FatalIfError(gc.DeleteRemoteComment(pwd, *deleteRemote, *deleteComment).Failure, "git")
-		fmt.Printf("Remote comment reference deleted\n")
+		deleted := fatalIfError(app, gc.DeleteRemoteComments(pwd, *deleteRemote, *deleteComment), "git")
+		fmt.Printf("Deleted %d remote comment references\n", len(deleted.(gc.CommentSlice)))
+	case "fetch":
+		merged := fatalIfError(app, gc.FetchRemoteComments(pwd, *fetchRemote, location.Revision(*fetchRange)), "fetch")
+		gs.RefreshIndex(pwd)
+		fmt.Printf("Merged %d comments from '%v'\n", len(merged.(gc.CommentSlice)), *fetchRemote)
+	case "prune":
+		pruned := fatalIfError(app, gc.PruneRemoteComments(pwd, *pruneRemote, *pruneDryRun), "git").(gc.CommentSlice)
+		action := "Deleted"
+		if *pruneDryRun {
+			action = "Would delete"
+		}
+		for _, comment := range pruned {
+			fmt.Printf("%v %v %v\n", action, (*comment.ID)[:7], comment.Title())
+		}
+		fmt.Printf("%v %d remote comment references\n", action, len(pruned))
+	case "status":
+		status := fatalIfError(app, gc.RemoteCommentStatus(pwd, *statusRemote, *statusLive), "status")
+		printStatus(status.(*gc.RemoteStatus))
+	case "sync":
+		summary := fatalIfError(app, gc.SyncRemoteComments(pwd, *syncRemote, gc.ConflictPolicy(*syncPrefer)), "sync")
+		gs.RefreshIndex(pwd)
+		printSyncSummary(summary.(*gc.RemoteSyncSummary))
+	}
+}
+
+func printStatus(status *gc.RemoteStatus) {
+	if status.UpToDate() {
+		fmt.Printf("Comments are up to date with '%v'\n", *statusRemote)
+		return
+	}
+	printComments(fmt.Sprintf("Comments not pushed to '%v':", *statusRemote), status.Unpushed)
+	printComments(fmt.Sprintf("Comments not merged from '%v':", *statusRemote), status.Unmerged)
+	if len(status.Differing) > 0 {
+		fmt.Printf("Comments which differ from '%v':\n", *statusRemote)
+		for _, difference := range status.Differing {
+			local, remote := difference.Local, difference.Remote
+			fmt.Printf("  %v %v -> %v %v\n", (*local.Commit)[:7], (*remote.ID)[:7], (*local.ID)[:7], local.Title())
+		}
+	}
+	if len(status.Unfetched) > 0 {
+		fmt.Printf("Comments not fetched from '%v':\n", *statusRemote)
+		for _, refName := range status.Unfetched {
+			fmt.Printf("  %v\n", refName)
+		}
+	}
+}
+
+func printComments(heading string, comments gc.CommentSlice) {
+	if len(comments) == 0 {
+		return
+	}
+	fmt.Println(heading)
+	for _, comment := range comments {
+		fmt.Printf("  %v %v %v\n", (*comment.Commit)[:7], (*comment.ID)[:7], comment.Title())
+	}
+}
+
+func printSyncSummary(summary *gc.RemoteSyncSummary) {
+	for _, conflict := range summary.Conflicts {
+		kept := "remote"
+		if conflict.Resolution == conflict.Local {
+			kept = "local"
+		}
+		fmt.Printf("Comment %v was amended locally and remotely, kept the %v version\n", (*conflict.Local.ID)[:7], kept)
+	}
+	fmt.Printf("Merged %d comments from '%v'\n", len(summary.Merged), *syncRemote)
+	fmt.Printf("Pushed %d comments to '%v'\n", len(summary.Pushed), *syncRemote)
+	if len(summary.Removed) > 0 {
+		fmt.Printf("Removed %d comments deleted from '%v'\n", len(summary.Removed), *syncRemote)
 	}
 }
 
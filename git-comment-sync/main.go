@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	gg "git"
+	"github.com/kylef/result.go/src/result"
+	kp "gopkg.in/alecthomas/kingpin.v2"
+	gc "libgitcomment"
+	"os"
+	gs "search"
+)
+
+const (
+	defaultGitHubURL    = "https://api.github.com"
+	githubURLConfig     = "comment.github.url"
+	githubRepoConfig    = "comment.github.repo"
+	githubTokenConfig   = "comment.github.token"
+	githubTokenEnv      = "GITHUB_TOKEN"
+	defaultGitLabURL    = "https://gitlab.com/api/v4"
+	gitlabURLConfig     = "comment.gitlab.url"
+	gitlabProjectConfig = "comment.gitlab.project"
+	gitlabTokenConfig   = "comment.gitlab.token"
+	gitlabTokenEnv      = "GITLAB_TOKEN"
+	missingRepoError    = "No repository specified. Use --repo or set %v"
+	missingProjectError = "No project specified. Use --project or set %v"
+)
+
+var (
+	buildVersion  string
+	app           = kp.New("git-comment-sync", "Synchronize comments with code review services")
+	githubCmd     = app.Command("github", "Synchronize comments with GitHub pull request review comments")
+	githubURL     = githubCmd.Flag("url", "Base URL of the GitHub API").String()
+	githubRepo    = githubCmd.Flag("repo", "Repository in the format owner/name").String()
+	githubPull    = githubCmd.Arg("pull request", "Pull request to synchronize").Int()
+	gitlabCmd     = app.Command("gitlab", "Synchronize comments with GitLab merge request diff notes")
+	gitlabURL     = gitlabCmd.Flag("url", "Base URL of the GitLab API").String()
+	gitlabProject = gitlabCmd.Flag("project", "Project ID or path in the format namespace/name").String()
+	gitlabMerge   = gitlabCmd.Arg("merge request", "Merge request to synchronize").Required().Int()
+)
+
+func main() {
+	app.Version(buildVersion)
+	dir, args, err := gg.StartDirectory(os.Args[1:])
+	app.FatalIfError(err, "pwd")
+	location, err := gg.DiscoverRepository(dir)
+	app.FatalIfError(err, "git")
+	pwd := location.RepoPath
+	fatalIfError(app, gc.VersionCheck(pwd, buildVersion), "version")
+	switch kp.MustParse(app.Parse(args)) {
+	case "github":
+		syncGitHub(pwd)
+	case "gitlab":
+		syncGitLab(pwd)
+	}
+}
+
+func syncGitHub(pwd string) {
+	baseURL := configuredValue(pwd, *githubURL, githubURLConfig, defaultGitHubURL)
+	repo := configuredValue(pwd, *githubRepo, githubRepoConfig, "")
+	if len(repo) == 0 {
+		app.FatalIfError(fmt.Errorf(missingRepoError, githubRepoConfig), "config")
+	}
+	token := configuredToken(pwd, githubTokenEnv, githubTokenConfig)
+	syncComments(pwd, gc.NewGitHubClient(baseURL, token, repo, *githubPull))
+}
+
+func syncGitLab(pwd string) {
+	baseURL := configuredValue(pwd, *gitlabURL, gitlabURLConfig, defaultGitLabURL)
+	project := configuredValue(pwd, *gitlabProject, gitlabProjectConfig, "")
+	if len(project) == 0 {
+		app.FatalIfError(fmt.Errorf(missingProjectError, gitlabProjectConfig), "config")
+	}
+	token := configuredToken(pwd, gitlabTokenEnv, gitlabTokenConfig)
+	syncComments(pwd, gc.NewGitLabClient(baseURL, token, project, *gitlabMerge))
+}
+
+func syncComments(pwd string, service gc.ReviewService) {
+	summary := fatalIfError(app, gc.SyncComments(pwd, service), "sync")
+	gs.RefreshIndex(pwd)
+	printSummary(summary.(*gc.SyncSummary))
+}
+
+// Prefer a value provided on the command line, then the git
+// configuration, then the fallback value
+func configuredValue(pwd, value, config, fallback string) string {
+	if len(value) > 0 {
+		return value
+	}
+	return gg.ConfiguredString(pwd, config, fallback)
+}
+
+// Prefer a token provided by the environment, then the git configuration
+func configuredToken(pwd, env, config string) string {
+	if token := os.Getenv(env); len(token) > 0 {
+		return token
+	}
+	return gg.ConfiguredString(pwd, config, "")
+}
+
+func printSummary(summary *gc.SyncSummary) {
+	fmt.Printf("Fetched %d new comments\n", len(summary.Fetched))
+	fmt.Printf("Updated %d local comments\n", len(summary.UpdatedLocal))
+	fmt.Printf("Pushed %d new comments\n", len(summary.Pushed))
+	fmt.Printf("Updated %d remote comments\n", len(summary.UpdatedRemote))
+}
+
+// Return the success value, otherwise kill the app with
+// the error code specified
+func fatalIfError(app *kp.Application, r result.Result, code string) interface{} {
+	app.FatalIfError(r.Failure, code)
+	return r.Success
+}
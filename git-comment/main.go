@@ -1,12 +1,15 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	gg "git"
 	"github.com/kylef/result.go/src/result"
 	kp "gopkg.in/alecthomas/kingpin.v2"
 	gc "libgitcomment"
 	"os"
+	gs "search"
+	"strings"
 )
 
 var (
@@ -20,44 +23,108 @@ var (
 	update       = app.Flag("update", "Upgrade repository to use current version of git-comment").Bool()
 	fileref      = app.Arg("file:line", "File and line number to annotate").String()
 	markDeleted  = app.Flag("mark-deleted-line", "Add comment to the deleted version of the file and line number").Bool()
+	sign         = app.Flag("sign", "Sign the comment using the default signing key").Short('s').Bool()
+	localUser    = app.Flag("local-user", "Sign the comment using the given key").Short('u').String()
+	verifyID     = app.Flag("verify", "ID of a comment to verify the signature of").String()
+	language     = app.Flag("language", "Language of the comment content for searching, instead of detecting it").Enum(gs.SupportedLanguages()...)
+)
+
+const (
+	invalidSignatureError = "Comment signature could not be verified"
+	unsignedCommentError  = "Comment %v is not signed"
+	duplicateWarning      = "warning: similar to comment %v: %v\n"
+	warnDuplicatesConfig  = "comment.warnDuplicates"
 )
 
 func main() {
 	app.Version(buildVersion)
-	kp.MustParse(app.Parse(os.Args[1:]))
-	pwd, err := os.Getwd()
+	dir, args, err := gg.StartDirectory(os.Args[1:])
 	app.FatalIfError(err, "pwd")
+	kp.MustParse(app.Parse(args))
+	location, err := gg.DiscoverRepository(dir)
+	app.FatalIfError(err, "git")
+	pwd := location.RepoPath
 	if *update {
 		gc.VersionUpdate(pwd, buildVersion)
 		return
 	}
 	fatalIfError(app, gc.VersionCheck(pwd, buildVersion), "version")
+	if len(*verifyID) > 0 {
+		verifyComment(pwd)
+		return
+	}
 	if len(*deleteID) > 0 {
 		app.FatalIfError(gc.DeleteComment(pwd, *deleteID).Failure, "git")
 		fmt.Println("Comment deleted")
 	} else {
-		editComment(pwd)
+		editComment(location, dir)
 	}
+	gs.RefreshIndex(pwd)
 }
 
-func editComment(pwd string) {
-	resolved := fatalIfError(app, gg.ResolvedCommit(pwd, *commit), "git")
+func editComment(location *gg.Location, dir string) {
+	pwd := location.RepoPath
+	resolved := fatalIfError(app, gg.ResolvedCommit(pwd, location.Revision(*commit)), "git")
 	parsedCommit := resolved.(*string)
 	if len(*message) == 0 {
 		*message = getMessageFromEditor(app, pwd)
 	}
 	if len(*amendID) > 0 {
-		id := fatalIfError(app, gc.UpdateComment(pwd, *amendID, *author, *message), "git")
+		id := fatalIfError(app, gc.UpdateComment(pwd, *amendID, *author, *message, commentSigner(pwd)), "git")
 		fmt.Printf("[%v] Comment updated\n", (*id.(*string))[:7])
 	} else {
 		ref := gc.CreateFileRef(*fileref, *markDeleted)
-		id := fatalIfError(app, gc.CreateComment(pwd, *parsedCommit, *author, *message, ref), "git")
+		if len(ref.Path) > 0 {
+			path, err := location.WorkTreePath(dir, ref.Path)
+			app.FatalIfError(err, "path")
+			ref.Path = path
+		}
+		warnDuplicates(pwd, *parsedCommit, ref)
+		id := fatalIfError(app, gc.CreateComment(pwd, *parsedCommit, *author, *message, *language, ref, commentSigner(pwd)), "git")
 
 		hash := *(id.(*string))
 		fmt.Printf("[%v] Comment created\n", hash[:7])
 	}
 }
 
+// Warn about existing comments on the same commit and line which a new
+// comment nearly duplicates, if enabled
+func warnDuplicates(pwd, commit string, ref *gc.FileRef) {
+	if !gg.ConfiguredBool(pwd, warnDuplicatesConfig, false) {
+		return
+	}
+	duplicates, err := gs.DuplicateComments(pwd, commit, ref, *message, *language).Dematerialize()
+	if err != nil {
+		return
+	}
+	for _, duplicate := range duplicates.([]*gs.SimilarComment) {
+		title := strings.Split(duplicate.Comment.Content, "\n")[0]
+		fmt.Fprintf(os.Stderr, duplicateWarning, (*duplicate.Comment.ID)[:7], title)
+	}
+}
+
+// Signer for new comments, if signing was requested
+func commentSigner(pwd string) gc.Signer {
+	if !*sign && len(*localUser) == 0 {
+		return nil
+	}
+	return fatalIfError(app, gc.ConfiguredSigner(pwd, *localUser), "sign").(gc.Signer)
+}
+
+func verifyComment(pwd string) {
+	value := fatalIfError(app, gc.VerifyComment(pwd, *verifyID), "verify")
+	verification := value.(*gc.Verification)
+	fmt.Fprint(os.Stderr, verification.Output)
+	switch verification.Status {
+	case gc.SignatureGood, gc.SignatureUnknownValidity:
+		return
+	case gc.SignatureNone:
+		app.FatalIfError(fmt.Errorf(unsignedCommentError, *verifyID), "verify")
+	default:
+		app.FatalIfError(errors.New(invalidSignatureError), "verify")
+	}
+}
+
 // Return the success value, otherwise kill the app with
 // the error code specified
 func fatalIfError(app *kp.Application, r result.Result, code string) interface{} {
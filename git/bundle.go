@@ -0,0 +1,345 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+const (
+	bundleSignature        = "# v2 git bundle"
+	packSignature          = "PACK"
+	packVersion            = 2
+	blobObjectType         = 3
+	ofsDeltaObjectType     = 6
+	refDeltaObjectType     = 7
+	invalidBundleError     = "Invalid bundle: %v"
+	missingDeltaBaseError  = "Invalid bundle: missing delta base %v"
+	invalidDeltaError      = "Invalid bundle: malformed delta"
+	unsupportedObjectError = "Invalid bundle: unsupported object type %v"
+)
+
+// A reference within a bundle
+type BundleRef struct {
+	Name string
+	ID   string
+}
+
+// The contents of a git bundle, as described by git-bundle(1). Only
+// blob content is retained when reading a bundle.
+type Bundle struct {
+	// Objects which must already exist when unbundling
+	Prerequisites []string
+	Refs          []*BundleRef
+	// Blob content by object identifier
+	Blobs map[string][]byte
+}
+
+type packObject struct {
+	kind int
+	data []byte
+}
+
+// Create an empty bundle
+func NewBundle() *Bundle {
+	return &Bundle{[]string{}, []*BundleRef{}, make(map[string][]byte)}
+}
+
+// Add a blob to the bundle, returning its identifier
+func (b *Bundle) AddBlob(content []byte) string {
+	id := objectID("blob", content)
+	b.Blobs[id] = content
+	return id
+}
+
+// Write the bundle, packing each blob without deltas
+func (b *Bundle) Write(output io.Writer) error {
+	writer := bufio.NewWriter(output)
+	fmt.Fprintf(writer, "%v\n", bundleSignature)
+	for _, prerequisite := range b.Prerequisites {
+		fmt.Fprintf(writer, "-%v\n", prerequisite)
+	}
+	for _, ref := range b.Refs {
+		fmt.Fprintf(writer, "%v %v\n", ref.ID, ref.Name)
+	}
+	writer.WriteString("\n")
+	if err := b.writePack(writer); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func (b *Bundle) writePack(output io.Writer) error {
+	checksum := sha1.New()
+	pack := io.MultiWriter(output, checksum)
+	ids := make([]string, 0, len(b.Blobs))
+	for id := range b.Blobs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	header := make([]byte, 12)
+	copy(header, packSignature)
+	binary.BigEndian.PutUint32(header[4:], packVersion)
+	binary.BigEndian.PutUint32(header[8:], uint32(len(ids)))
+	if _, err := pack.Write(header); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		content := b.Blobs[id]
+		if _, err := pack.Write(packObjectHeader(blobObjectType, len(content))); err != nil {
+			return err
+		}
+		compressor := zlib.NewWriter(pack)
+		if _, err := compressor.Write(content); err != nil {
+			return err
+		}
+		if err := compressor.Close(); err != nil {
+			return err
+		}
+	}
+	_, err := output.Write(checksum.Sum(nil))
+	return err
+}
+
+// Read a bundle, resolving deltas within the pack. Deltas against
+// prerequisite objects are not supported.
+func ReadBundle(input io.Reader) (*Bundle, error) {
+	reader := bufio.NewReader(input)
+	bundle := NewBundle()
+	signature, err := reader.ReadString('\n')
+	if err != nil || strings.TrimSpace(signature) != bundleSignature {
+		return nil, fmt.Errorf(invalidBundleError, "unknown signature")
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf(invalidBundleError, "incomplete header")
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if len(line) == 0 {
+			break
+		} else if strings.HasPrefix(line, "-") {
+			fields := strings.Fields(line[1:])
+			if len(fields) == 0 {
+				return nil, fmt.Errorf(invalidBundleError, "missing prerequisite")
+			}
+			bundle.Prerequisites = append(bundle.Prerequisites, fields[0])
+		} else if fields := strings.SplitN(line, " ", 2); len(fields) == 2 {
+			bundle.Refs = append(bundle.Refs, &BundleRef{fields[1], fields[0]})
+		} else {
+			return nil, fmt.Errorf(invalidBundleError, line)
+		}
+	}
+	return bundle, bundle.readPack(reader)
+}
+
+// A reader which tracks the offset and checksum of the data read
+type packReader struct {
+	reader   *bufio.Reader
+	offset   int64
+	checksum hash.Hash
+}
+
+func (r *packReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.offset += int64(n)
+	r.checksum.Write(p[:n])
+	return n, err
+}
+
+func (r *packReader) ReadByte() (byte, error) {
+	c, err := r.reader.ReadByte()
+	if err == nil {
+		r.offset++
+		r.checksum.Write([]byte{c})
+	}
+	return c, err
+}
+
+func (b *Bundle) readPack(input *bufio.Reader) error {
+	reader := &packReader{input, 0, sha1.New()}
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(reader, header); err != nil || string(header[:4]) != packSignature {
+		return fmt.Errorf(invalidBundleError, "missing pack")
+	}
+	count := binary.BigEndian.Uint32(header[8:])
+	byOffset := make(map[int64]*packObject)
+	byID := make(map[string]*packObject)
+	for index := uint32(0); index < count; index++ {
+		offset := reader.offset
+		object, err := readPackObject(reader, offset, byOffset, byID)
+		if err != nil {
+			return err
+		}
+		byOffset[offset] = object
+		byID[objectID(objectTypeName(object.kind), object.data)] = object
+	}
+	expected := reader.checksum.Sum(nil)
+	trailer := make([]byte, sha1.Size)
+	if _, err := io.ReadFull(input, trailer); err != nil || !bytes.Equal(trailer, expected) {
+		return fmt.Errorf(invalidBundleError, "pack checksum mismatch")
+	}
+	for id, object := range byID {
+		if object.kind == blobObjectType {
+			b.Blobs[id] = object.data
+		}
+	}
+	return nil
+}
+
+func readPackObject(reader *packReader, offset int64, byOffset map[int64]*packObject, byID map[string]*packObject) (*packObject, error) {
+	c, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	kind := int(c>>4) & 7
+	for c&0x80 != 0 {
+		if c, err = reader.ReadByte(); err != nil {
+			return nil, err
+		}
+	}
+	var base *packObject
+	switch kind {
+	case ofsDeltaObjectType:
+		c, err = reader.ReadByte()
+		distance := int64(c & 0x7f)
+		for err == nil && c&0x80 != 0 {
+			c, err = reader.ReadByte()
+			distance = ((distance + 1) << 7) | int64(c&0x7f)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if base = byOffset[offset-distance]; base == nil {
+			return nil, fmt.Errorf(missingDeltaBaseError, offset-distance)
+		}
+	case refDeltaObjectType:
+		id := make([]byte, sha1.Size)
+		if _, err := io.ReadFull(reader, id); err != nil {
+			return nil, err
+		}
+		if base = byID[hex.EncodeToString(id)]; base == nil {
+			return nil, fmt.Errorf(missingDeltaBaseError, hex.EncodeToString(id))
+		}
+	case 1, 2, blobObjectType, 4:
+	default:
+		return nil, fmt.Errorf(unsupportedObjectError, kind)
+	}
+	decompressor, err := zlib.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(decompressor)
+	if err != nil {
+		return nil, err
+	}
+	if base == nil {
+		return &packObject{kind, data}, nil
+	}
+	data, err = applyDelta(base.data, data)
+	return &packObject{base.kind, data}, err
+}
+
+// Apply a git delta to the content of its base object
+func applyDelta(base, delta []byte) ([]byte, error) {
+	sourceSize, delta := deltaSize(delta)
+	targetSize, delta := deltaSize(delta)
+	if sourceSize != len(base) {
+		return nil, errors.New(invalidDeltaError)
+	}
+	target := make([]byte, 0, targetSize)
+	for len(delta) > 0 {
+		instruction := delta[0]
+		delta = delta[1:]
+		if instruction&0x80 == 0 {
+			size := int(instruction)
+			if size == 0 || size > len(delta) {
+				return nil, errors.New(invalidDeltaError)
+			}
+			target = append(target, delta[:size]...)
+			delta = delta[size:]
+			continue
+		}
+		var offset, size int
+		for bit := uint(0); bit < 7; bit++ {
+			if instruction&(1<<bit) == 0 {
+				continue
+			} else if len(delta) == 0 {
+				return nil, errors.New(invalidDeltaError)
+			}
+			if bit < 4 {
+				offset |= int(delta[0]) << (8 * bit)
+			} else {
+				size |= int(delta[0]) << (8 * (bit - 4))
+			}
+			delta = delta[1:]
+		}
+		if size == 0 {
+			size = 0x10000
+		}
+		if offset+size > len(base) {
+			return nil, errors.New(invalidDeltaError)
+		}
+		target = append(target, base[offset:offset+size]...)
+	}
+	if len(target) != targetSize {
+		return nil, errors.New(invalidDeltaError)
+	}
+	return target, nil
+}
+
+// Read a size encoded at the start of a delta
+func deltaSize(delta []byte) (int, []byte) {
+	size, shift := 0, uint(0)
+	for index, c := range delta {
+		size |= int(c&0x7f) << shift
+		shift += 7
+		if c&0x80 == 0 {
+			return size, delta[index+1:]
+		}
+	}
+	return size, nil
+}
+
+// Encode the type and size of an object within a pack
+func packObjectHeader(kind, size int) []byte {
+	c := byte(kind<<4) | byte(size&0x0f)
+	size >>= 4
+	header := make([]byte, 0, 8)
+	for size > 0 {
+		header = append(header, c|0x80)
+		c = byte(size & 0x7f)
+		size >>= 7
+	}
+	return append(header, c)
+}
+
+func objectTypeName(kind int) string {
+	switch kind {
+	case 1:
+		return "commit"
+	case 2:
+		return "tree"
+	case 4:
+		return "tag"
+	}
+	return "blob"
+}
+
+// The identifier of an object with the given type and content
+func objectID(kind string, content []byte) string {
+	checksum := sha1.New()
+	fmt.Fprintf(checksum, "%v %d\x00", kind, len(content))
+	checksum.Write(content)
+	return hex.EncodeToString(checksum.Sum(nil))
+}
@@ -0,0 +1,179 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stvp/assert"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	bundle := NewBundle()
+	id := bundle.AddBlob([]byte("commit 0155eb4229851634a0f03eb265b69f5a2d56f341\n\nNeeds a test\n"))
+	bundle.Refs = append(bundle.Refs, &BundleRef{"refs/comments/0155/eb42/" + id, id})
+	var buffer bytes.Buffer
+	assert.Nil(t, bundle.Write(&buffer))
+	read, err := ReadBundle(&buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, read.Refs, bundle.Refs)
+	assert.Equal(t, read.Blobs, bundle.Blobs)
+}
+
+func TestReadBundleChecksumMismatch(t *testing.T) {
+	bundle := NewBundle()
+	bundle.AddBlob([]byte("content"))
+	var buffer bytes.Buffer
+	assert.Nil(t, bundle.Write(&buffer))
+	content := buffer.Bytes()
+	content[len(content)-1] ^= 0xff
+	_, err := ReadBundle(bytes.NewReader(content))
+	assert.NotNil(t, err)
+}
+
+func TestReadBundleInvalidSignature(t *testing.T) {
+	_, err := ReadBundle(bytes.NewReader([]byte("# v3 git bundle\n\n")))
+	assert.NotNil(t, err)
+}
+
+func TestReadBundleMissingPrerequisite(t *testing.T) {
+	for _, line := range []string{"-", "- "} {
+		_, err := ReadBundle(bytes.NewReader([]byte("# v2 git bundle\n" + line + "\n\n")))
+		assert.NotNil(t, err)
+	}
+}
+
+func TestObjectID(t *testing.T) {
+	assert.Equal(t, objectID("blob", []byte("hello\n")), "ce013625030ba8dba906f756967f9e9ca394464a")
+}
+
+func TestPackObjectHeader(t *testing.T) {
+	assert.Equal(t, packObjectHeader(blobObjectType, 10), []byte{0x3a})
+	assert.Equal(t, packObjectHeader(blobObjectType, 100), []byte{0xb4, 0x06})
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("Needs a test")
+	// source size 12, target size 17, copy 12 bytes from offset 0,
+	// insert 5 bytes
+	delta := []byte{12, 17, 0x90, 12, 5, ' ', 'h', 'e', 'r', 'e'}
+	target, err := applyDelta(base, delta)
+	assert.Nil(t, err)
+	assert.Equal(t, string(target), "Needs a test here")
+}
+
+func TestApplyDeltaWrongBase(t *testing.T) {
+	_, err := applyDelta([]byte("short"), []byte{12, 0})
+	assert.NotNil(t, err)
+}
+
+// A repository whose two commits change a long file slightly, so that
+// git packs one version of it as a delta against the other
+func newBundleRepoTest(t *testing.T) (string, func(string, ...string) string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "git-comment-bundle")
+	assert.Nil(t, err)
+	run := func(dir string, args ...string) string {
+		command := exec.Command("git", args...)
+		command.Dir = dir
+		command.Env = append(os.Environ(), "GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		output, err := command.Output()
+		assert.Nil(t, err)
+		return strings.TrimSpace(string(output))
+	}
+	repo := filepath.Join(dir, "repo")
+	run(dir, "init", "-q", repo)
+	var content bytes.Buffer
+	for line := 1; line <= 200; line++ {
+		fmt.Fprintf(&content, "Line %d of a file long enough to be packed as a delta\n", line)
+	}
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(repo, "file.txt"), content.Bytes(), 0644))
+	run(repo, "add", "file.txt")
+	run(repo, "commit", "-q", "-m", "First")
+	content.WriteString("One more line\n")
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(repo, "file.txt"), content.Bytes(), 0644))
+	run(repo, "commit", "-q", "-a", "-m", "Second")
+	return dir, run
+}
+
+// Index the pack within a bundle using git, reporting whether it
+// contains any delta objects
+func packHasDeltas(t *testing.T, dir string, run func(string, ...string) string, content []byte) bool {
+	start := bytes.Index(content, []byte("\n\n"))
+	assert.True(t, start > 0)
+	pack := filepath.Join(dir, "check.pack")
+	assert.Nil(t, ioutil.WriteFile(pack, content[start+2:], 0644))
+	run(dir, "index-pack", pack)
+	return strings.Contains(run(dir, "verify-pack", "-s", pack), "chain length")
+}
+
+func assertBundleBlobs(t *testing.T, bundle *Bundle, repo string, run func(string, ...string) string) {
+	assert.Equal(t, len(bundle.Blobs), 2)
+	for _, rev := range []string{"HEAD:file.txt", "HEAD~1:file.txt"} {
+		id := run(repo, "rev-parse", rev)
+		assert.Equal(t, string(bundle.Blobs[id]), run(repo, "cat-file", "blob", id)+"\n")
+	}
+}
+
+func TestReadGitBundleOffsetDeltas(t *testing.T) {
+	dir, run := newBundleRepoTest(t)
+	defer os.RemoveAll(dir)
+	repo, path := filepath.Join(dir, "repo"), filepath.Join(dir, "test.bundle")
+	run(repo, "bundle", "create", "-q", path, "HEAD")
+	content, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.True(t, packHasDeltas(t, dir, run, content))
+	bundle, err := ReadBundle(bytes.NewReader(content))
+	assert.Nil(t, err)
+	assert.Equal(t, len(bundle.Prerequisites), 0)
+	assert.Equal(t, len(bundle.Refs), 1)
+	assert.Equal(t, bundle.Refs[0].ID+" "+bundle.Refs[0].Name, run(repo, "bundle", "list-heads", path))
+	assertBundleBlobs(t, bundle, repo, run)
+}
+
+func TestReadGitBundleRefDeltas(t *testing.T) {
+	dir, run := newBundleRepoTest(t)
+	defer os.RemoveAll(dir)
+	repo := filepath.Join(dir, "repo")
+	head := run(repo, "rev-parse", "HEAD")
+	// without --delta-base-offset, git refers to delta bases by name
+	command := exec.Command("git", "pack-objects", "-q", "--stdout", "--revs")
+	command.Dir = repo
+	command.Stdin = strings.NewReader("HEAD\n")
+	pack, err := command.Output()
+	assert.Nil(t, err)
+	content := append([]byte(bundleSignature+"\n"+head+" refs/heads/master\n\n"), pack...)
+	assert.True(t, packHasDeltas(t, dir, run, content))
+	bundle, err := ReadBundle(bytes.NewReader(content))
+	assert.Nil(t, err)
+	assertBundleBlobs(t, bundle, repo, run)
+}
+
+func TestGitVerifiesWrittenBundle(t *testing.T) {
+	dir, run := newBundleRepoTest(t)
+	defer os.RemoveAll(dir)
+	bundle := NewBundle()
+	small := bundle.AddBlob([]byte("commit 0155eb4229851634a0f03eb265b69f5a2d56f341\n\nNeeds a test\n"))
+	large := bundle.AddBlob(bytes.Repeat([]byte("A comment long enough to need a multi-byte size\n"), 100))
+	bundle.Refs = append(bundle.Refs, &BundleRef{"refs/comments/0155/eb42/" + small, small},
+		&BundleRef{"refs/comments/0155/eb42/" + large, large})
+	path := filepath.Join(dir, "test.bundle")
+	output, err := os.Create(path)
+	assert.Nil(t, err)
+	assert.Nil(t, bundle.Write(output))
+	assert.Nil(t, output.Close())
+	target := filepath.Join(dir, "target")
+	run(dir, "init", "-q", target)
+	run(target, "bundle", "verify", "-q", path)
+	run(target, "bundle", "unbundle", path)
+	for id, content := range bundle.Blobs {
+		assert.Equal(t, run(target, "cat-file", "blob", id)+"\n", string(content))
+	}
+}
@@ -23,10 +23,10 @@ func ConfiguredBool(repoPath, name string, fallback bool) bool {
 	}).Recover(fallback).(bool)
 }
 
+// Perform a block with the configuration of a repository, including
+// files from matching `includeIf` sections
 func WithConfig(repoPath string, ifSuccess func(config *git.Config) result.Result) result.Result {
-	return WithRepository(repoPath, func(repo *git.Repository) result.Result {
-		return result.NewResult(repo.Config())
-	}).FlatMap(func(config interface{}) result.Result {
+	return WithRepository(repoPath, repositoryConfig).FlatMap(func(config interface{}) result.Result {
 		return ifSuccess(config.(*git.Config))
 	})
 }
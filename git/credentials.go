@@ -0,0 +1,197 @@
+package git
+
+import (
+	git "gopkg.in/libgit2/git2go.v23"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	sslVerifyConfig  = "http.sslVerify"
+	sslNoVerifyEnv   = "GIT_SSL_NO_VERIFY"
+	sshAuthSockEnv   = "SSH_AUTH_SOCK"
+	defaultSSHUser   = "git"
+	credentialFill   = "fill"
+	credentialOK     = "approve"
+	credentialReject = "reject"
+	// Requests for credentials for one URL after which authentication
+	// fails, bounding the attempts when a remote keeps rejecting them
+	maxCredentialAttempts = 8
+)
+
+// Private keys tried in order when authenticating over SSH, relative
+// to the home directory
+var sshKeyFiles = []string{".ssh/id_rsa", ".ssh/id_ecdsa", ".ssh/id_ed25519", ".ssh/id_dsa"}
+
+// Credentials provided to a remote during a single fetch or push.
+// Credentials are tried in turn each time the remote rejects the
+// previous attempt, until none are left.
+type credentialSession struct {
+	repoPath string
+	// Number of credential requests for each URL
+	attempts map[string]int
+	// Remaining sources of SSH credentials, either the agent or key
+	// files
+	sshKeys []string
+	// Whether SSH credential sources have been found
+	sshStarted bool
+	// Credential provided by the credential helper, which is approved
+	// or rejected once the outcome is known
+	helperCredential string
+}
+
+func newCredentialSession(repoPath string) *credentialSession {
+	return &credentialSession{repoPath: repoPath, attempts: make(map[string]int)}
+}
+
+// Callbacks providing credentials and checking certificates
+func (s *credentialSession) callbacks() git.RemoteCallbacks {
+	return git.RemoteCallbacks{
+		CredentialsCallback:      s.credentials,
+		CertificateCheckCallback: s.checkCertificate,
+	}
+}
+
+// Store or erase the credential helper credential depending on whether
+// the operation succeeded, returning the error of the operation
+func (s *credentialSession) finish(err error) error {
+	if len(s.helperCredential) > 0 {
+		if err == nil {
+			s.runHelper(credentialOK, s.helperCredential)
+		} else if git.IsErrorCode(err, git.ErrAuth) {
+			s.runHelper(credentialReject, s.helperCredential)
+		}
+		s.helperCredential = ""
+	}
+	return err
+}
+
+// Provide the next credential for a URL. The default credential of the
+// current user is the same each time, so it is only provided once.
+func (s *credentialSession) credentials(url, usernameFromURL string, allowedTypes git.CredType) (git.ErrorCode, *git.Cred) {
+	s.attempts[url]++
+	if s.attempts[url] > maxCredentialAttempts {
+		return git.ErrAuth, nil
+	} else if allowedTypes&git.CredTypeSshKey != 0 {
+		return s.sshCredentials(usernameFromURL)
+	} else if allowedTypes&git.CredTypeUserpassPlaintext != 0 {
+		return s.plaintextCredentials(url, usernameFromURL)
+	} else if allowedTypes&git.CredTypeDefault != 0 && s.attempts[url] == 1 {
+		ret, cred := git.NewCredDefault()
+		return git.ErrorCode(ret), &cred
+	}
+	return git.ErrAuth, nil
+}
+
+// Authenticate using ssh-agent if available, followed by each private
+// key in the home directory
+func (s *credentialSession) sshCredentials(username string) (git.ErrorCode, *git.Cred) {
+	if len(username) == 0 {
+		username = defaultSSHUser
+	}
+	if !s.sshStarted {
+		s.sshKeys = sshKeyCandidates(os.Getenv("HOME"), len(os.Getenv(sshAuthSockEnv)) > 0)
+		s.sshStarted = true
+	}
+	if len(s.sshKeys) == 0 {
+		return git.ErrAuth, nil
+	}
+	key := s.sshKeys[0]
+	s.sshKeys = s.sshKeys[1:]
+	var ret int
+	var cred git.Cred
+	if len(key) == 0 {
+		ret, cred = git.NewCredSshKeyFromAgent(username)
+	} else {
+		ret, cred = git.NewCredSshKey(username, key+".pub", key, "")
+	}
+	return git.ErrorCode(ret), &cred
+}
+
+// Authenticate using the credential helpers configured for git. A
+// credential is only requested once, since the helper would provide
+// the same credential again.
+func (s *credentialSession) plaintextCredentials(url, username string) (git.ErrorCode, *git.Cred) {
+	if len(s.helperCredential) > 0 {
+		s.runHelper(credentialReject, s.helperCredential)
+		s.helperCredential = ""
+		return git.ErrAuth, nil
+	}
+	output, err := s.runHelper(credentialFill, credentialInput(url, username))
+	if err != nil {
+		return git.ErrAuth, nil
+	}
+	values := parseCredential(output)
+	if len(values["username"]) == 0 {
+		return git.ErrAuth, nil
+	}
+	s.helperCredential = output
+	ret, cred := git.NewCredUserpassPlaintext(values["username"], values["password"])
+	return git.ErrorCode(ret), &cred
+}
+
+// Accept certificates considered valid, or any certificate if SSL
+// verification is disabled by `http.sslVerify` or GIT_SSL_NO_VERIFY.
+// libgit2 does not validate SSH host keys, so those are accepted.
+func (s *credentialSession) checkCertificate(cert *git.Certificate, valid bool, hostname string) git.ErrorCode {
+	if valid || cert.Kind == git.CertificateHostkey || !s.verifySSL() {
+		return git.ErrOk
+	}
+	return git.ErrGeneric
+}
+
+func (s *credentialSession) verifySSL() bool {
+	if len(os.Getenv(sslNoVerifyEnv)) > 0 {
+		return false
+	}
+	return ConfiguredBool(s.repoPath, sslVerifyConfig, true)
+}
+
+// Run `git credential` with a subcommand, as described in
+// git-credential(1). Prompts for credentials are shown on the terminal.
+func (s *credentialSession) runHelper(action, input string) (string, error) {
+	cmd := exec.Command("git", "credential", action)
+	cmd.Dir = s.repoPath
+	cmd.Env = os.Environ()
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	return string(output), err
+}
+
+// Sources of SSH credentials, where an empty path refers to the agent
+func sshKeyCandidates(home string, hasAgent bool) []string {
+	keys := make([]string, 0, len(sshKeyFiles)+1)
+	if hasAgent {
+		keys = append(keys, "")
+	}
+	for _, file := range sshKeyFiles {
+		key := filepath.Join(home, file)
+		if _, err := os.Stat(key); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Describe a credential for `git credential fill`
+func credentialInput(url, username string) string {
+	input := "url=" + url + "\n"
+	if len(username) > 0 {
+		input += "username=" + username + "\n"
+	}
+	return input + "\n"
+}
+
+// Parse the attributes provided by `git credential fill`
+func parseCredential(output string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if index := strings.Index(line, "="); index > 0 {
+			values[line[:index]] = line[index+1:]
+		}
+	}
+	return values
+}
@@ -0,0 +1,170 @@
+package git
+
+import (
+	"github.com/stvp/assert"
+	git "gopkg.in/libgit2/git2go.v23"
+	"io/ioutil"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCredentialInput(t *testing.T) {
+	input := credentialInput("https://example.com/repo.git", "kyle")
+	assert.Equal(t, input, "url=https://example.com/repo.git\nusername=kyle\n\n")
+}
+
+func TestCredentialInputWithoutUsername(t *testing.T) {
+	assert.Equal(t, credentialInput("https://example.com/repo.git", ""), "url=https://example.com/repo.git\n\n")
+}
+
+func TestParseCredential(t *testing.T) {
+	values := parseCredential("protocol=https\nhost=example.com\nusername=kyle\npassword=a=b\n")
+	assert.Equal(t, values["host"], "example.com")
+	assert.Equal(t, values["username"], "kyle")
+	assert.Equal(t, values["password"], "a=b")
+}
+
+func TestSSHKeyCandidates(t *testing.T) {
+	home, err := ioutil.TempDir("", "git-comment-home")
+	assert.Nil(t, err)
+	defer os.RemoveAll(home)
+	assert.Nil(t, os.Mkdir(filepath.Join(home, ".ssh"), 0700))
+	key := filepath.Join(home, ".ssh", "id_ecdsa")
+	assert.Nil(t, ioutil.WriteFile(key, []byte("key"), 0600))
+	assert.Equal(t, sshKeyCandidates(home, true), []string{"", key})
+	assert.Equal(t, sshKeyCandidates(home, false), []string{key})
+}
+
+func TestCredentialsDefaultOnce(t *testing.T) {
+	session := newCredentialSession("")
+	code, cred := session.credentials("https://example.com/repo.git", "", git.CredTypeDefault)
+	assert.Equal(t, code, git.ErrOk)
+	assert.NotNil(t, cred)
+	code, cred = session.credentials("https://example.com/repo.git", "", git.CredTypeDefault)
+	assert.Equal(t, code, git.ErrAuth)
+	assert.Nil(t, cred)
+	code, _ = session.credentials("https://example.com/other.git", "", git.CredTypeDefault)
+	assert.Equal(t, code, git.ErrOk)
+}
+
+func TestCredentialsAttemptLimit(t *testing.T) {
+	session := newCredentialSession("")
+	session.attempts["ssh://example.com/repo.git"] = maxCredentialAttempts
+	code, _ := session.credentials("ssh://example.com/repo.git", "git", git.CredTypeSshKey)
+	assert.Equal(t, code, git.ErrAuth)
+}
+
+// A repository with a remote served over HTTP by git-http-backend,
+// which requires the username kyle and the password s3cret
+type httpRemoteTest struct {
+	dir    string
+	local  string
+	server *httptest.Server
+	run    func(dir string, args ...string)
+}
+
+func newHTTPRemoteTest(t *testing.T, tls bool) *httpRemoteTest {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "git-comment-repo")
+	assert.Nil(t, err)
+	test := &httpRemoteTest{dir: dir, local: filepath.Join(dir, "local")}
+	test.run = func(dir string, args ...string) {
+		command := exec.Command("git", args...)
+		command.Dir = dir
+		command.Env = append(os.Environ(), "GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		assert.Nil(t, command.Run())
+	}
+	source := filepath.Join(dir, "source")
+	test.run(dir, "init", "-q", source)
+	test.run(source, "commit", "-q", "--allow-empty", "-m", "First")
+	test.run(dir, "clone", "-q", "--bare", source, filepath.Join(dir, "remote.git"))
+	execPath, err := exec.Command("git", "--exec-path").Output()
+	assert.Nil(t, err)
+	backend := &cgi.Handler{
+		Path: filepath.Join(strings.TrimSpace(string(execPath)), "git-http-backend"),
+		Env:  []string{"GIT_PROJECT_ROOT=" + dir, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "kyle" || password != "s3cret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	})
+	if tls {
+		test.server = httptest.NewTLSServer(handler)
+	} else {
+		test.server = httptest.NewServer(handler)
+	}
+	test.run(dir, "init", "-q", test.local)
+	test.run(test.local, "remote", "add", "origin", test.server.URL+"/remote.git")
+	return test
+}
+
+func (test *httpRemoteTest) close() {
+	test.server.Close()
+	os.RemoveAll(test.dir)
+}
+
+// Configure a credential helper providing a password
+func (test *httpRemoteTest) useCredential(password string) {
+	helper := `!f() { if test "$1" = get; then printf 'username=kyle\npassword=` + password + `\n'; fi; }; f`
+	test.run(test.local, "config", "credential.helper", helper)
+}
+
+func (test *httpRemoteTest) fetch() error {
+	return Fetch(test.local, "origin", []string{"+refs/heads/*:refs/remotes/origin/*"}).Failure
+}
+
+// Isolate credential lookups from the configuration of the user
+func isolatedCredentials(t *testing.T) func() {
+	home, err := ioutil.TempDir("", "git-comment-home")
+	assert.Nil(t, err)
+	previous := map[string]string{}
+	for name, value := range map[string]string{"HOME": home, "GIT_CONFIG_NOSYSTEM": "1", "GIT_TERMINAL_PROMPT": "0", sslNoVerifyEnv: ""} {
+		previous[name] = os.Getenv(name)
+		os.Setenv(name, value)
+	}
+	return func() {
+		for name, value := range previous {
+			os.Setenv(name, value)
+		}
+		os.RemoveAll(home)
+	}
+}
+
+func TestFetchWithCredentialHelper(t *testing.T) {
+	defer isolatedCredentials(t)()
+	test := newHTTPRemoteTest(t, false)
+	defer test.close()
+	test.useCredential("s3cret")
+	assert.Nil(t, test.fetch())
+}
+
+func TestFetchWithRejectedCredential(t *testing.T) {
+	defer isolatedCredentials(t)()
+	test := newHTTPRemoteTest(t, false)
+	defer test.close()
+	test.useCredential("wrong")
+	assert.NotNil(t, test.fetch())
+}
+
+func TestFetchWithSSLVerify(t *testing.T) {
+	defer isolatedCredentials(t)()
+	test := newHTTPRemoteTest(t, true)
+	defer test.close()
+	test.useCredential("s3cret")
+	assert.NotNil(t, test.fetch())
+	test.run(test.local, "config", "http.sslVerify", "false")
+	assert.Nil(t, test.fetch())
+}
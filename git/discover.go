@@ -0,0 +1,271 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kylef/result.go/src/result"
+	git "gopkg.in/libgit2/git2go.v23"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	gitDirEnv       = "GIT_DIR"
+	gitWorkTreeEnv  = "GIT_WORK_TREE"
+	directoryOption = "-C"
+	// Configured root of the working tree, relative to the git directory
+	coreWorkTreeConfig = "core.worktree"
+	// File in the git directory of a linked working tree naming the
+	// git directory shared by every working tree
+	commonDirFile = "commondir"
+	// File in the git directory of a linked working tree naming the
+	// `.git` file at the root of the working tree
+	linkedGitDirFile  = "gitdir"
+	headFile          = "HEAD"
+	symbolicRefPrefix = "ref: "
+
+	missingDirectoryError = "Option -C requires a directory"
+	outsideWorkTreeError  = "'%v' is outside repository work tree"
+	bareRepositoryError   = "'%v' cannot be used in a bare repository"
+	linkedHeadError       = "Unable to resolve HEAD of the working tree at '%v': %v"
+)
+
+// A repository found from a starting directory
+type Location struct {
+	// Path used to open the repository, the root of the working tree or
+	// the git directory of a bare repository
+	RepoPath string
+	// The git directory of the repository
+	GitDir string
+	// The root of the working tree, or empty for bare repositories
+	WorkTree string
+	// The commit checked out in a linked working tree opened through
+	// its common git directory, or empty when HEAD of the repository
+	// opened is the commit checked out
+	Head string
+}
+
+// Find the directory commands start from, and the remaining arguments.
+// Any leading `-C <path>` options change the working directory in turn
+// as git(1) does, so other paths given are relative to it.
+func StartDirectory(args []string) (string, []string, error) {
+	for len(args) > 0 && args[0] == directoryOption {
+		if len(args) < 2 {
+			return "", nil, errors.New(missingDirectoryError)
+		}
+		if err := os.Chdir(args[1]); err != nil {
+			return "", nil, err
+		}
+		args = args[2:]
+	}
+	dir, err := os.Getwd()
+	return dir, args, err
+}
+
+// Find the repository containing a directory, or the repository named
+// by GIT_DIR and GIT_WORK_TREE if set. As for git, when only GIT_DIR is
+// set the working tree is core.worktree if configured, or otherwise the
+// current directory. Both variables are made absolute so commands run
+// from the repository find it too.
+func DiscoverRepository(start string) (*Location, error) {
+	gitDir := os.Getenv(gitDirEnv)
+	explicitGitDir := len(gitDir) > 0
+	if explicitGitDir {
+		gitDir = resolvePath(start, gitDir)
+		os.Setenv(gitDirEnv, gitDir)
+	} else {
+		discovered, err := git.Discover(start, false, nil)
+		if err != nil {
+			return nil, err
+		}
+		gitDir = filepath.Clean(discovered)
+	}
+	location := &Location{gitDir, gitDir, "", ""}
+	repo, err := git.OpenRepository(gitDir)
+	if err != nil {
+		// Versions of libgit2 without support for linked working trees
+		// can only open the common git directory
+		common := commonDir(gitDir)
+		if common == gitDir {
+			return nil, err
+		}
+		if repo, err = git.OpenRepository(common); err != nil {
+			return nil, err
+		}
+		location.RepoPath = common
+		location.WorkTree = linkedWorkTree(gitDir)
+		// HEAD of the common directory is the commit checked out in the
+		// main working tree, not this one
+		if location.Head, err = linkedHead(repo, gitDir); err != nil {
+			repo.Free()
+			return nil, fmt.Errorf(linkedHeadError, location.WorkTree, err)
+		}
+	}
+	defer repo.Free()
+	if workTree := os.Getenv(gitWorkTreeEnv); len(workTree) > 0 {
+		location.WorkTree = resolvePath(start, workTree)
+		os.Setenv(gitWorkTreeEnv, location.WorkTree)
+	} else if len(location.WorkTree) == 0 && !repo.IsBare() {
+		if !explicitGitDir {
+			location.WorkTree = filepath.Clean(repo.Workdir())
+			location.RepoPath = location.WorkTree
+		} else if workTree, err := explicitWorkTree(repo, gitDir); err != nil {
+			return nil, err
+		} else {
+			location.WorkTree = workTree
+			os.Setenv(gitWorkTreeEnv, location.WorkTree)
+		}
+	}
+	return location, nil
+}
+
+// The root of the working tree of a repository named by GIT_DIR alone,
+// which git takes to be core.worktree if set, or else the current
+// directory rather than the parent of the git directory
+func explicitWorkTree(repo *git.Repository, gitDir string) (string, error) {
+	config, err := repo.Config()
+	if err != nil {
+		return "", err
+	}
+	defer config.Free()
+	if workTree, err := config.LookupString(coreWorkTreeConfig); err == nil && len(workTree) > 0 {
+		return resolvePath(gitDir, workTree), nil
+	}
+	return os.Getwd()
+}
+
+// Convert a path relative to a directory into a path relative to the
+// root of the working tree, as stored in comments
+func (l *Location) WorkTreePath(dir, path string) (string, error) {
+	if len(l.WorkTree) == 0 {
+		return "", fmt.Errorf(bareRepositoryError, path)
+	}
+	relative, err := filepath.Rel(realPath(l.WorkTree), realPath(resolvePath(dir, path)))
+	if err != nil {
+		return "", err
+	}
+	if relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf(outsideWorkTreeError, path)
+	}
+	return filepath.ToSlash(relative), nil
+}
+
+// A revision with HEAD replaced by the commit checked out in the working
+// tree, for repositories opened through a common git directory where
+// HEAD names the commit checked out elsewhere
+func (l *Location) Revision(revision string) string {
+	if len(l.Head) == 0 {
+		return revision
+	}
+	return expandHead(revision, l.Head)
+}
+
+// Replace HEAD at the start of each end of a revision range with a
+// commit, along with the ends omitted, which git takes to mean HEAD
+func expandHead(revision, head string) string {
+	for _, separator := range []string{"...", ".."} {
+		if ends := strings.SplitN(revision, separator, 2); len(ends) == 2 {
+			return expandHead(ends[0], head) + separator + expandHead(ends[1], head)
+		}
+	}
+	exclude := strings.HasPrefix(revision, "^")
+	name := strings.TrimPrefix(revision, "^")
+	if len(name) == 0 {
+		name = head
+	} else if strings.HasPrefix(name, headCommit) {
+		if rest := name[len(headCommit):]; len(rest) == 0 || strings.ContainsAny(rest[:1], "~^@:") {
+			name = head + rest
+		}
+	}
+	if exclude {
+		return "^" + name
+	}
+	return name
+}
+
+// Find the git directory shared by every working tree of a repository
+// @return result.Result<string, error>
+func CommonDir(repoPath string) result.Result {
+	return WithRepository(repoPath, func(repo *git.Repository) result.Result {
+		return result.NewSuccess(commonDir(repo.Path()))
+	})
+}
+
+// The common git directory of a git directory, which is the directory
+// itself unless it belongs to a linked working tree
+func commonDir(gitDir string) string {
+	content, err := ioutil.ReadFile(filepath.Join(gitDir, commonDirFile))
+	if err != nil {
+		return filepath.Clean(gitDir)
+	}
+	return resolvePath(gitDir, strings.TrimSpace(string(content)))
+}
+
+// The root of a linked working tree, named by the `gitdir` file in its
+// git directory
+func linkedWorkTree(gitDir string) string {
+	content, err := ioutil.ReadFile(filepath.Join(gitDir, linkedGitDirFile))
+	if err != nil {
+		return ""
+	}
+	return filepath.Dir(resolvePath(gitDir, strings.TrimSpace(string(content))))
+}
+
+// The commit checked out in a linked working tree, named by the HEAD
+// file in its git directory and resolved against the shared references
+func linkedHead(repo *git.Repository, gitDir string) (string, error) {
+	id, ref, err := readHead(gitDir)
+	if err != nil || len(ref) == 0 {
+		return id, err
+	}
+	reference, err := repo.References.Lookup(ref)
+	if err != nil {
+		return "", err
+	}
+	defer reference.Free()
+	resolved, err := reference.Resolve()
+	if err != nil {
+		return "", err
+	}
+	defer resolved.Free()
+	return resolved.Target().String(), nil
+}
+
+// Read the HEAD file of a git directory, which holds either a commit
+// or the name of the reference checked out
+func readHead(gitDir string) (string, string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(gitDir, headFile))
+	if err != nil {
+		return "", "", err
+	}
+	head := strings.TrimSpace(string(content))
+	if strings.HasPrefix(head, symbolicRefPrefix) {
+		return "", strings.TrimSpace(strings.TrimPrefix(head, symbolicRefPrefix)), nil
+	}
+	if _, err := git.NewOid(head); err != nil {
+		return "", "", err
+	}
+	return head, "", nil
+}
+
+func resolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(dir, path)
+}
+
+// A path with symbolic links resolved where possible, so paths through
+// links compare equal to the paths they lead to
+func realPath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	dir, file := filepath.Split(path)
+	if len(file) == 0 || dir == path {
+		return path
+	}
+	return filepath.Join(realPath(filepath.Clean(dir)), file)
+}
@@ -0,0 +1,189 @@
+package git
+
+import (
+	"github.com/stvp/assert"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommonDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "git-comment-repo")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	gitDir := filepath.Join(dir, ".git", "worktrees", "feature")
+	assert.Nil(t, os.MkdirAll(gitDir, 0700))
+	assert.Equal(t, commonDir(gitDir), gitDir)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(gitDir, commonDirFile), []byte("../..\n"), 0600))
+	assert.Equal(t, commonDir(gitDir), filepath.Join(dir, ".git"))
+}
+
+func TestLinkedWorkTree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "git-comment-repo")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Equal(t, linkedWorkTree(dir), "")
+	link := filepath.Join(dir, "feature", ".git")
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, linkedGitDirFile), []byte(link+"\n"), 0600))
+	assert.Equal(t, linkedWorkTree(dir), filepath.Join(dir, "feature"))
+}
+
+func TestWorkTreePath(t *testing.T) {
+	location := &Location{"/src/repo", "/src/repo/.git", "/src/repo", ""}
+	path, err := location.WorkTreePath("/src/repo/lib/parse", "../util.c")
+	assert.Nil(t, err)
+	assert.Equal(t, path, "lib/util.c")
+	path, err = location.WorkTreePath("/tmp", "/src/repo/README")
+	assert.Nil(t, err)
+	assert.Equal(t, path, "README")
+}
+
+func TestWorkTreePathOutside(t *testing.T) {
+	location := &Location{"/src/repo", "/src/repo/.git", "/src/repo", ""}
+	_, err := location.WorkTreePath("/src/repo", "../other/README")
+	assert.NotNil(t, err)
+}
+
+func TestWorkTreePathBare(t *testing.T) {
+	location := &Location{"/src/repo.git", "/src/repo.git", "", ""}
+	_, err := location.WorkTreePath("/src/repo.git", "README")
+	assert.NotNil(t, err)
+}
+
+func TestWorkTreePathSymlink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "git-comment-repo")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	workTree := filepath.Join(dir, "repo")
+	assert.Nil(t, os.MkdirAll(filepath.Join(workTree, "lib"), 0700))
+	link := filepath.Join(dir, "link")
+	assert.Nil(t, os.Symlink(workTree, link))
+	location := &Location{workTree, filepath.Join(workTree, ".git"), workTree, ""}
+	path, err := location.WorkTreePath(filepath.Join(link, "lib"), "new.c")
+	assert.Nil(t, err)
+	assert.Equal(t, path, "lib/new.c")
+}
+
+func TestExpandHead(t *testing.T) {
+	head := "0155eb4229851634a0f03eb265b69f5a2d56f341"
+	assert.Equal(t, expandHead("HEAD", head), head)
+	assert.Equal(t, expandHead("", head), head)
+	assert.Equal(t, expandHead("HEAD~2", head), head+"~2")
+	assert.Equal(t, expandHead("HEAD^..HEAD", head), head+"^.."+head)
+	assert.Equal(t, expandHead("master...", head), "master..."+head)
+	assert.Equal(t, expandHead("^HEAD", head), "^"+head)
+	assert.Equal(t, expandHead("HEADLESS", head), "HEADLESS")
+	assert.Equal(t, expandHead("master", head), "master")
+}
+
+func TestLocationRevision(t *testing.T) {
+	location := &Location{"/src/repo", "/src/repo/.git", "/src/repo", ""}
+	assert.Equal(t, location.Revision("HEAD~1"), "HEAD~1")
+	location.Head = "0155eb4229851634a0f03eb265b69f5a2d56f341"
+	assert.Equal(t, location.Revision("HEAD~1"), location.Head+"~1")
+}
+
+func TestReadHead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "git-comment-repo")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	head := filepath.Join(dir, headFile)
+	assert.Nil(t, ioutil.WriteFile(head, []byte("ref: refs/heads/feature\n"), 0600))
+	id, ref, err := readHead(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, id, "")
+	assert.Equal(t, ref, "refs/heads/feature")
+	assert.Nil(t, ioutil.WriteFile(head, []byte("0155eb4229851634a0f03eb265b69f5a2d56f341\n"), 0600))
+	id, ref, err = readHead(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, id, "0155eb4229851634a0f03eb265b69f5a2d56f341")
+	assert.Equal(t, ref, "")
+	assert.Nil(t, ioutil.WriteFile(head, []byte("garbage\n"), 0600))
+	_, _, err = readHead(dir)
+	assert.NotNil(t, err)
+}
+
+func TestDiscoverLinkedWorkTree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "git-comment-repo")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	dir = realPath(dir)
+	main, linked := filepath.Join(dir, "main"), filepath.Join(dir, "linked")
+	run := func(dir string, args ...string) string {
+		command := exec.Command("git", args...)
+		command.Dir = dir
+		command.Env = append(os.Environ(), "GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		output, err := command.Output()
+		assert.Nil(t, err)
+		return strings.TrimSpace(string(output))
+	}
+	run(dir, "init", "-q", main)
+	run(main, "commit", "-q", "--allow-empty", "-m", "First")
+	run(main, "worktree", "add", "-q", "-b", "feature", linked)
+	run(linked, "commit", "-q", "--allow-empty", "-m", "Second")
+	head := run(linked, "rev-parse", "HEAD")
+	location, err := DiscoverRepository(linked)
+	assert.Nil(t, err)
+	assert.Equal(t, location.WorkTree, linked)
+	assert.Equal(t, location.Revision("HEAD"), head)
+	id := ResolvedCommit(location.RepoPath, location.Revision("HEAD"))
+	assert.Nil(t, id.Failure)
+	assert.Equal(t, *id.Success.(*string), head)
+}
+
+func TestDiscoverGitDirWorkTree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	pwd, err := os.Getwd()
+	assert.Nil(t, err)
+	defer os.Chdir(pwd)
+	defer os.Setenv(gitDirEnv, os.Getenv(gitDirEnv))
+	defer os.Setenv(gitWorkTreeEnv, os.Getenv(gitWorkTreeEnv))
+	dir, err := ioutil.TempDir("", "git-comment-repo")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	dir = realPath(dir)
+	main, other := filepath.Join(dir, "main"), filepath.Join(dir, "other")
+	command := exec.Command("git", "init", "-q", main)
+	assert.Nil(t, command.Run())
+	assert.Nil(t, os.Mkdir(other, 0700))
+	assert.Nil(t, os.Chdir(other))
+	os.Setenv(gitDirEnv, filepath.Join("..", "main", ".git"))
+	os.Unsetenv(gitWorkTreeEnv)
+	command = exec.Command("git", "rev-parse", "--show-toplevel")
+	output, err := command.Output()
+	assert.Nil(t, err)
+	assert.Equal(t, strings.TrimSpace(string(output)), other)
+	location, err := DiscoverRepository(other)
+	assert.Nil(t, err)
+	assert.Equal(t, location.GitDir, filepath.Join(main, ".git"))
+	assert.Equal(t, realPath(location.WorkTree), other)
+	assert.Equal(t, os.Getenv(gitWorkTreeEnv), location.WorkTree)
+}
+
+func TestStartDirectory(t *testing.T) {
+	pwd, err := os.Getwd()
+	assert.Nil(t, err)
+	defer os.Chdir(pwd)
+	dir, err := ioutil.TempDir("", "git-comment-repo")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "lib"), 0700))
+	start, args, err := StartDirectory([]string{"-C", dir, "-C", "lib", "find", "-C", "2"})
+	assert.Nil(t, err)
+	assert.Equal(t, realPath(start), realPath(filepath.Join(dir, "lib")))
+	assert.Equal(t, args, []string{"find", "-C", "2"})
+}
+
+func TestStartDirectoryMissingPath(t *testing.T) {
+	_, _, err := StartDirectory([]string{"-C"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,155 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kylef/result.go/src/result"
+	git "gopkg.in/libgit2/git2go.v23"
+	"os"
+	"os/user"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The name, email, and time identifying who made a change
+type Identity struct {
+	Name  string
+	Email string
+	Date  time.Time
+}
+
+type identityRole string
+
+const (
+	authorRole    identityRole = "author"
+	committerRole identityRole = "committer"
+	userRole      identityRole = "user"
+)
+
+const (
+	useConfigOnlyConfig = "user.useConfigOnly"
+	emailEnv            = "EMAIL"
+	invalidDateError    = "Invalid date '%v'"
+)
+
+// Formats of dates given in GIT_AUTHOR_DATE and GIT_COMMITTER_DATE,
+// besides the internal format of seconds and a time zone offset
+var identityDateFormats = []string{
+	time.RFC1123Z,
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+var internalDateRegexp = regexp.MustCompile(`^@?([0-9]+)(?: ([+-])([0-9]{2})([0-9]{2}))?$`)
+
+// The author of a change, as configured through environment variables
+// and git config in the same order as git(1)
+// @return result.Result<*Identity, error>
+func AuthorIdentity(repoPath string) result.Result {
+	return configuredIdentity(repoPath, authorRole)
+}
+
+// The committer of a change, as configured through environment
+// variables and git config in the same order as git(1)
+// @return result.Result<*Identity, error>
+func CommitterIdentity(repoPath string) result.Result {
+	return configuredIdentity(repoPath, committerRole)
+}
+
+func configuredIdentity(repoPath string, role identityRole) result.Result {
+	return WithConfig(repoPath, func(config *git.Config) result.Result {
+		lookup := func(name string) string {
+			value, _ := config.LookupString(name)
+			return value
+		}
+		useConfigOnly, _ := config.LookupBool(useConfigOnlyConfig)
+		return result.NewResult(resolveIdentity(role, os.Getenv, lookup, useConfigOnly, time.Now()))
+	})
+}
+
+// Find the identity of an author or committer from environment
+// variables, then role and user configuration, then the system user
+// unless only configuration may be used
+func resolveIdentity(role identityRole, env, config func(string) string, useConfigOnly bool, now time.Time) (*Identity, error) {
+	prefix := "GIT_" + strings.ToUpper(string(role)) + "_"
+	name := firstValue(env(prefix+"NAME"), config(string(role)+".name"), config(string(userRole)+".name"))
+	email := firstValue(env(prefix+"EMAIL"), config(string(role)+".email"), config(string(userRole)+".email"), env(emailEnv))
+	if !useConfigOnly && (len(name) == 0 || len(email) == 0) {
+		systemName, systemEmail := systemIdentity()
+		name, email = firstValue(name, systemName), firstValue(email, systemEmail)
+	}
+	if len(name) == 0 || len(email) == 0 {
+		return nil, errors.New(identityNotFoundError(role))
+	}
+	date := now
+	if value := env(prefix + "DATE"); len(value) > 0 {
+		parsed, err := parseIdentityDate(value)
+		if err != nil {
+			return nil, err
+		}
+		date = parsed
+	}
+	return &Identity{name, email, date}, nil
+}
+
+func identityNotFoundError(role identityRole) string {
+	if role == authorRole {
+		return authorNotFoundError
+	}
+	return committerNotFoundError
+}
+
+// The name and email of the user running the command, where the email
+// is guessed from the user and host names
+func systemIdentity() (string, string) {
+	current, err := user.Current()
+	if err != nil {
+		return "", ""
+	}
+	name := firstValue(strings.Split(current.Name, ",")[0], current.Username)
+	host, err := os.Hostname()
+	if err != nil || len(current.Username) == 0 {
+		return name, ""
+	}
+	return name, current.Username + "@" + host
+}
+
+// Parse a date in the internal format of git, `<seconds> <offset>`, or
+// in RFC 2822 or ISO 8601 formats
+func parseIdentityDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if match := internalDateRegexp.FindStringSubmatch(value); len(match) > 0 {
+		seconds, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf(invalidDateError, value)
+		}
+		hours, _ := strconv.Atoi(match[3])
+		minutes, _ := strconv.Atoi(match[4])
+		offset := hours*3600 + minutes*60
+		if match[2] == "-" {
+			offset = -offset
+		}
+		zone := time.FixedZone(fmt.Sprintf("%v%v%v", match[2], match[3], match[4]), offset)
+		return time.Unix(seconds, 0).In(zone), nil
+	}
+	for _, format := range identityDateFormats {
+		if date, err := time.ParseInLocation(format, value, time.Local); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf(invalidDateError, value)
+}
+
+func firstValue(values ...string) string {
+	for _, value := range values {
+		if len(value) > 0 {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+package git
+
+import (
+	"github.com/stvp/assert"
+	"testing"
+	"time"
+)
+
+func values(pairs map[string]string) func(string) string {
+	return func(name string) string {
+		return pairs[name]
+	}
+}
+
+func TestResolveIdentityFromEnvironment(t *testing.T) {
+	env := values(map[string]string{
+		"GIT_AUTHOR_NAME":  "Sam Doe",
+		"GIT_AUTHOR_EMAIL": "sam@example.com",
+		"GIT_AUTHOR_DATE":  "@1456000000 +0100",
+	})
+	config := values(map[string]string{"user.name": "Config User", "user.email": "config@example.com"})
+	identity, err := resolveIdentity(authorRole, env, config, true, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, identity.Name, "Sam Doe")
+	assert.Equal(t, identity.Email, "sam@example.com")
+	assert.Equal(t, identity.Date.Unix(), int64(1456000000))
+	assert.Equal(t, identity.Date.Format("-0700"), "+0100")
+}
+
+func TestResolveIdentityRoleConfig(t *testing.T) {
+	config := values(map[string]string{
+		"user.name":       "Config User",
+		"user.email":      "config@example.com",
+		"committer.email": "bot@example.com",
+	})
+	now := time.Unix(1456000000, 0)
+	identity, err := resolveIdentity(committerRole, values(nil), config, true, now)
+	assert.Nil(t, err)
+	assert.Equal(t, identity.Name, "Config User")
+	assert.Equal(t, identity.Email, "bot@example.com")
+	assert.Equal(t, identity.Date, now)
+	identity, err = resolveIdentity(authorRole, values(nil), config, true, now)
+	assert.Nil(t, err)
+	assert.Equal(t, identity.Email, "config@example.com")
+}
+
+func TestResolveIdentityEmailEnvironment(t *testing.T) {
+	env := values(map[string]string{"EMAIL": "env@example.com"})
+	config := values(map[string]string{"user.name": "Config User"})
+	identity, err := resolveIdentity(authorRole, env, config, true, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, identity.Email, "env@example.com")
+}
+
+func TestResolveIdentityUseConfigOnly(t *testing.T) {
+	config := values(map[string]string{"user.name": "Config User"})
+	_, err := resolveIdentity(authorRole, values(nil), config, true, time.Now())
+	assert.NotNil(t, err)
+	assert.Equal(t, err.Error(), authorNotFoundError)
+}
+
+func TestResolveIdentityInvalidDate(t *testing.T) {
+	env := values(map[string]string{"GIT_COMMITTER_DATE": "yesterday-ish"})
+	config := values(map[string]string{"user.name": "Config User", "user.email": "config@example.com"})
+	_, err := resolveIdentity(committerRole, env, config, true, time.Now())
+	assert.NotNil(t, err)
+}
+
+func TestParseIdentityDateInternal(t *testing.T) {
+	date, err := parseIdentityDate("1456000000 -0430")
+	assert.Nil(t, err)
+	assert.Equal(t, date.Unix(), int64(1456000000))
+	assert.Equal(t, date.Format("-0700"), "-0430")
+}
+
+func TestParseIdentityDateRFC2822(t *testing.T) {
+	date, err := parseIdentityDate("Sun, 21 Feb 2016 09:26:40 +0100")
+	assert.Nil(t, err)
+	assert.Equal(t, date.Unix(), int64(1456043200))
+}
+
+func TestParseIdentityDateISO8601(t *testing.T) {
+	date, err := parseIdentityDate("2016-02-21T09:26:40+01:00")
+	assert.Nil(t, err)
+	assert.Equal(t, date.Unix(), int64(1456043200))
+}
@@ -0,0 +1,227 @@
+package git
+
+import (
+	"bytes"
+	"github.com/kylef/result.go/src/result"
+	git "gopkg.in/libgit2/git2go.v23"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	includeIfGlob       = `^includeif\..+\.path$`
+	includeIfPrefix     = "includeif."
+	includePathSuffix   = ".path"
+	gitDirCondition     = "gitdir:"
+	gitDirFoldCondition = "gitdir/i:"
+	onBranchCondition   = "onbranch:"
+	branchRefPrefix     = "refs/heads/"
+	localConfigFile     = "config"
+	homePrefix          = "~/"
+	relativePrefix      = "./"
+	// Spacing between the levels of configuration files, leaving room
+	// for the files included by each one
+	includeLevelScale = 100
+)
+
+// A configuration file included by another when a condition holds
+type configInclude struct {
+	level git.ConfigLevel
+	path  string
+}
+
+// The configuration of a repository, along with the files named by
+// `includeIf` sections whose conditions hold, which libgit2 does not
+// read itself. Included files take precedence over the file including
+// them, but not over files of a higher level.
+// @return result.Result<*git.Config, error>
+func repositoryConfig(repo *git.Repository) result.Result {
+	return result.NewResult(repo.Config()).FlatMap(func(value interface{}) result.Result {
+		config := value.(*git.Config)
+		levels := configLevelPaths(repo)
+		includes, err := conditionalIncludes(repo, config, levels)
+		if err != nil {
+			return result.NewFailure(err)
+		} else if len(includes) == 0 {
+			return result.NewSuccess(config)
+		}
+		config.Free()
+		return includingConfig(levels, includes)
+	})
+}
+
+// The configuration files read for a repository at each level
+func configLevelPaths(repo *git.Repository) map[git.ConfigLevel]string {
+	levels := make(map[git.ConfigLevel]string)
+	addLevel := func(level git.ConfigLevel, find func() (string, error)) {
+		if path, err := find(); err == nil {
+			levels[level] = path
+		}
+	}
+	addLevel(git.ConfigLevelSystem, git.ConfigFindSystem)
+	addLevel(git.ConfigLevelXDG, git.ConfigFindXDG)
+	addLevel(git.ConfigLevelGlobal, git.ConfigFindGlobal)
+	levels[git.ConfigLevelLocal] = filepath.Join(repo.Path(), localConfigFile)
+	return levels
+}
+
+// Find the files named by `includeIf` sections whose conditions hold
+func conditionalIncludes(repo *git.Repository, config *git.Config, levels map[git.ConfigLevel]string) ([]*configInclude, error) {
+	iterator, err := config.NewIteratorGlob(includeIfGlob)
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Free()
+	gitDir := strings.TrimSuffix(repo.Path(), "/")
+	gitDirs := []string{gitDir}
+	if resolved := realPath(gitDir); resolved != gitDir {
+		gitDirs = append(gitDirs, resolved)
+	}
+	branch := currentBranch(repo)
+	includes := make([]*configInclude, 0)
+	for {
+		entry, err := iterator.Next()
+		if git.IsErrorCode(err, git.ErrIterOver) {
+			return includes, nil
+		} else if err != nil {
+			return nil, err
+		}
+		baseDir := filepath.Dir(levels[entry.Level])
+		condition := strings.TrimSuffix(strings.TrimPrefix(entry.Name, includeIfPrefix), includePathSuffix)
+		if includeConditionHolds(condition, gitDirs, branch, baseDir) {
+			includes = append(includes, &configInclude{entry.Level, includePath(entry.Value, baseDir)})
+		}
+	}
+}
+
+// A configuration reading the files of each level and the files they
+// include
+// @return result.Result<*git.Config, error>
+func includingConfig(levels map[git.ConfigLevel]string, includes []*configInclude) result.Result {
+	return result.NewResult(git.NewConfig()).FlatMap(func(value interface{}) result.Result {
+		config := value.(*git.Config)
+		for level, path := range levels {
+			if err := addConfigFile(config, path, level*includeLevelScale); err != nil {
+				return result.NewFailure(err)
+			}
+		}
+		for position, include := range includes {
+			level := include.level*includeLevelScale + git.ConfigLevel(position+1)
+			if err := addConfigFile(config, include.path, level); err != nil {
+				return result.NewFailure(err)
+			}
+		}
+		return result.NewSuccess(config)
+	})
+}
+
+// Add a configuration file if it exists, as git ignores missing files
+func addConfigFile(config *git.Config, path string, level git.ConfigLevel) error {
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+	return config.AddFile(path, level, false)
+}
+
+// The branch checked out, or empty if HEAD is detached
+func currentBranch(repo *git.Repository) string {
+	head, err := repo.References.Lookup("HEAD")
+	if err != nil {
+		return ""
+	}
+	defer head.Free()
+	if target := head.SymbolicTarget(); strings.HasPrefix(target, branchRefPrefix) {
+		return strings.TrimPrefix(target, branchRefPrefix)
+	}
+	return ""
+}
+
+// Whether the condition of an `includeIf` section holds for a
+// repository with git directories and a current branch. Conditions are
+// matched as described in git-config(1), and unsupported conditions do
+// not hold.
+func includeConditionHolds(condition string, gitDirs []string, branch, baseDir string) bool {
+	switch {
+	case strings.HasPrefix(condition, gitDirCondition):
+		return gitDirMatches(strings.TrimPrefix(condition, gitDirCondition), gitDirs, baseDir, false)
+	case strings.HasPrefix(condition, gitDirFoldCondition):
+		return gitDirMatches(strings.TrimPrefix(condition, gitDirFoldCondition), gitDirs, baseDir, true)
+	case strings.HasPrefix(condition, onBranchCondition):
+		pattern := completedPattern(strings.TrimPrefix(condition, onBranchCondition))
+		return len(branch) > 0 && globMatches(pattern, branch, false)
+	}
+	return false
+}
+
+func gitDirMatches(pattern string, gitDirs []string, baseDir string, foldCase bool) bool {
+	if strings.HasPrefix(pattern, homePrefix) {
+		pattern = filepath.Join(os.Getenv("HOME"), pattern[len(homePrefix):]) + trailingSlash(pattern)
+	} else if strings.HasPrefix(pattern, relativePrefix) {
+		pattern = filepath.Join(baseDir, pattern[len(relativePrefix):]) + trailingSlash(pattern)
+	} else if !filepath.IsAbs(pattern) {
+		pattern = "**/" + pattern
+	}
+	pattern = completedPattern(pattern)
+	for _, gitDir := range gitDirs {
+		if globMatches(pattern, gitDir, foldCase) {
+			return true
+		}
+	}
+	return false
+}
+
+// A pattern ending with a slash matches everything within the
+// directory
+func completedPattern(pattern string) string {
+	if strings.HasSuffix(pattern, "/") {
+		return pattern + "**"
+	}
+	return pattern
+}
+
+func trailingSlash(pattern string) string {
+	if strings.HasSuffix(pattern, "/") && len(pattern) > 1 {
+		return "/"
+	}
+	return ""
+}
+
+// Whether a value matches a wildcard pattern, where `*` and `?` match
+// within a path component and `**` matches across components
+func globMatches(pattern, value string, foldCase bool) bool {
+	var expr bytes.Buffer
+	if foldCase {
+		expr.WriteString("(?i)")
+	}
+	expr.WriteString("^")
+	characters := []rune(pattern)
+	for i := 0; i < len(characters); i++ {
+		switch rest := string(characters[i:]); {
+		case strings.HasPrefix(rest, "**/"):
+			expr.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(rest, "**"):
+			expr.WriteString(".*")
+			i++
+		case characters[i] == '*':
+			expr.WriteString("[^/]*")
+		case characters[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(characters[i])))
+		}
+	}
+	expr.WriteString("$")
+	matcher, err := regexp.Compile(expr.String())
+	return err == nil && matcher.MatchString(value)
+}
+
+// The path of an included file, relative to the file including it
+func includePath(path, baseDir string) string {
+	if strings.HasPrefix(path, homePrefix) {
+		return filepath.Join(os.Getenv("HOME"), path[len(homePrefix):])
+	}
+	return resolvePath(baseDir, path)
+}
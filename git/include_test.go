@@ -0,0 +1,50 @@
+package git
+
+import (
+	"github.com/stvp/assert"
+	"os"
+	"testing"
+)
+
+func TestGlobMatches(t *testing.T) {
+	assert.True(t, globMatches("/src/**", "/src/work/project/.git", false))
+	assert.True(t, globMatches("**/project/.git", "/src/work/project/.git", false))
+	assert.True(t, globMatches("/src/*/project/.git", "/src/work/project/.git", false))
+	assert.False(t, globMatches("/src/*/.git", "/src/work/project/.git", false))
+	assert.False(t, globMatches("/SRC/**", "/src/work/.git", false))
+	assert.True(t, globMatches("/SRC/**", "/src/work/.git", true))
+}
+
+func TestIncludeConditionGitDir(t *testing.T) {
+	gitDirs := []string{"/src/work/project/.git"}
+	assert.True(t, includeConditionHolds("gitdir:/src/work/", gitDirs, "", "/etc"))
+	assert.True(t, includeConditionHolds("gitdir:project/.git", gitDirs, "", "/etc"))
+	assert.True(t, includeConditionHolds("gitdir:./work/", gitDirs, "", "/src"))
+	assert.False(t, includeConditionHolds("gitdir:/src/personal/", gitDirs, "", "/etc"))
+	assert.False(t, includeConditionHolds("gitdir:/SRC/WORK/", gitDirs, "", "/etc"))
+	assert.True(t, includeConditionHolds("gitdir/i:/SRC/WORK/", gitDirs, "", "/etc"))
+}
+
+func TestIncludeConditionGitDirHome(t *testing.T) {
+	home := os.Getenv("HOME")
+	defer os.Setenv("HOME", home)
+	os.Setenv("HOME", "/home/sam")
+	gitDirs := []string{"/home/sam/work/project/.git"}
+	assert.True(t, includeConditionHolds("gitdir:~/work/", gitDirs, "", "/etc"))
+	assert.Equal(t, includePath("~/.gitconfig-work", "/etc"), "/home/sam/.gitconfig-work")
+}
+
+func TestIncludeConditionOnBranch(t *testing.T) {
+	assert.True(t, includeConditionHolds("onbranch:release/", nil, "release/2.0", "/etc"))
+	assert.True(t, includeConditionHolds("onbranch:master", nil, "master", "/etc"))
+	assert.False(t, includeConditionHolds("onbranch:master", nil, "", "/etc"))
+}
+
+func TestIncludeConditionUnsupported(t *testing.T) {
+	assert.False(t, includeConditionHolds("hasconfig:remote.*.url:https://example.com/**", nil, "master", "/etc"))
+}
+
+func TestIncludePathRelative(t *testing.T) {
+	assert.Equal(t, includePath("work.inc", "/home/sam/.config/git"), "/home/sam/.config/git/work.inc")
+	assert.Equal(t, includePath("/etc/work.inc", "/home/sam"), "/etc/work.inc")
+}
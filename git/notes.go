@@ -0,0 +1,128 @@
+package git
+
+import (
+	"github.com/kylef/result.go/src/result"
+	git "gopkg.in/libgit2/git2go.v23"
+)
+
+// Read the content of the note attached to an object, or an empty
+// string if there is no note
+// @return result.Result<string, error>
+func ReadNote(repo *git.Repository, notesRef string, oid *git.Oid) result.Result {
+	note, err := repo.Notes.Read(notesRef, oid)
+	if git.IsErrorCode(err, git.ErrNotFound) {
+		return result.NewSuccess("")
+	} else if err != nil {
+		return result.NewFailure(err)
+	}
+	defer note.Free()
+	return result.NewSuccess(note.Message())
+}
+
+// Replace the note attached to an object, which callers must check is
+// theirs to replace
+// @return result.Result<*git.Oid, error>
+func WriteNote(repo *git.Repository, notesRef string, oid *git.Oid, content string, sig *git.Signature) result.Result {
+	return result.NewResult(repo.Notes.Create(notesRef, sig, sig, oid, content, true))
+}
+
+// Iterate over the notes within a notes reference, providing the
+// annotated object and note. A missing notes reference contains no
+// notes.
+// @return result.Result<bool, error>
+func IterateNotes(repo *git.Repository, notesRef string, iteration func(annotated *git.Oid, note *git.Note)) result.Result {
+	iterator, err := repo.NewNoteIterator(notesRef)
+	if git.IsErrorCode(err, git.ErrNotFound) {
+		return result.NewSuccess(true)
+	} else if err != nil {
+		return result.NewFailure(err)
+	}
+	defer iterator.Free()
+	for {
+		_, annotated, err := iterator.Next()
+		if git.IsErrorCode(err, git.ErrIterOver) {
+			break
+		} else if err != nil {
+			return result.NewFailure(err)
+		}
+		note, err := repo.Notes.Read(notesRef, annotated)
+		if err != nil {
+			return result.NewFailure(err)
+		}
+		iteration(annotated, note)
+		note.Free()
+	}
+	return result.NewSuccess(true)
+}
+
+// Remove the note attached to an object, which callers must check is
+// theirs to remove
+// @return result.Result<bool, error>
+func RemoveNote(repo *git.Repository, notesRef string, oid *git.Oid, sig *git.Signature) result.Result {
+	if err := repo.Notes.Remove(notesRef, sig, sig, oid); err != nil {
+		return result.NewFailure(err)
+	}
+	return result.NewSuccess(true)
+}
+
+// Find the author of the notes commit which first added each note
+// within the history of a notes reference, by the identifier of the
+// note blob. The author of the commit at the tip of the reference is
+// only the author of the most recent change to the notes.
+// @return result.Result<map[string]*git.Signature, error>
+func NoteAuthors(repo *git.Repository, notesRef string) result.Result {
+	authors := make(map[string]*git.Signature)
+	walk, err := repo.Walk()
+	if err != nil {
+		return result.NewFailure(err)
+	}
+	defer walk.Free()
+	walk.Sorting(git.SortTopological | git.SortReverse)
+	if err := walk.PushRef(notesRef); git.IsErrorCode(err, git.ErrNotFound) {
+		return result.NewSuccess(authors)
+	} else if err != nil {
+		return result.NewFailure(err)
+	}
+	var failure error
+	err = walk.Iterate(func(commit *git.Commit) bool {
+		failure = addedNotes(repo, commit, func(blob string) {
+			if _, ok := authors[blob]; !ok {
+				authors[blob] = commit.Author()
+			}
+		})
+		return failure == nil
+	})
+	if err == nil {
+		err = failure
+	}
+	if err != nil {
+		return result.NewFailure(err)
+	}
+	return result.NewSuccess(authors)
+}
+
+// Provide the identifiers of the note blobs which a notes commit added
+// or changed relative to its first parent
+func addedNotes(repo *git.Repository, commit *git.Commit, added func(blob string)) error {
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+	var parentTree *git.Tree
+	if parent := commit.Parent(0); parent != nil {
+		if parentTree, err = parent.Tree(); err != nil {
+			return err
+		}
+	}
+	diff, err := repo.DiffTreeToTree(parentTree, tree, nil)
+	if err != nil {
+		return err
+	}
+	defer diff.Free()
+	return diff.ForEach(func(delta git.DiffDelta, progress float64) (git.DiffForEachHunkCallback, error) {
+		if delta.Status == git.DeltaAdded || delta.Status == git.DeltaModified {
+			added(delta.NewFile.Oid.String())
+		}
+		return nil, nil
+	}, git.DiffDetailFiles)
+}
@@ -1,11 +1,25 @@
 package git
 
 import (
+	"bytes"
+	"errors"
 	"github.com/kylef/result.go/src/result"
 	git "gopkg.in/libgit2/git2go.v23"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
 )
 
-const defaultPushMessage = ""
+const (
+	defaultPushMessage = ""
+	remoteRefBase      = "refs/remotes"
+	remoteCommentDir   = "comments"
+	// Reported by git(1) when a remote does not advertise atomic pushes
+	atomicUnsupported = "does not support --atomic push"
+	// Flag of a rejected reference in the porcelain output of git-push(1)
+	pushRejectedFlag = "!"
+)
 
 // Lookup a remote by name, performing a block if found
 // @return result.Result<*git.Remote, error>
@@ -17,14 +31,125 @@ func WithRemote(repoPath, remoteName string, ifSuccess func(*git.Remote) result.
 	})
 }
 
-// Push given refspecs to the remote
+// Base reference path for comments fetched from a remote
+func RemoteCommentRefBase(remoteName string) string {
+	return path.Join(remoteRefBase, remoteName, remoteCommentDir)
+}
+
+// Fetch given refspecs from the remote, removing remote-tracking
+// references which no longer exist on the remote. Credentials are
+// provided by ssh-agent, SSH key files, or the git credential helpers.
 // @return result.Result<bool, error>
+func Fetch(repoPath, remoteName string, refspecs []string) result.Result {
+	return WithRemote(repoPath, remoteName, func(remote *git.Remote) result.Result {
+		session := newCredentialSession(repoPath)
+		options := &git.FetchOptions{RemoteCallbacks: session.callbacks(), Prune: git.FetchPruneOn, UpdateFetchhead: true}
+		return BoolResult(true, session.finish(remote.Fetch(refspecs, options, "")))
+	})
+}
+
+// List the references on the remote beginning with a prefix, mapping
+// reference names to object identifiers
+// @return result.Result<map[string]string, error>
+func ListRemoteRefs(repoPath, remoteName, prefix string) result.Result {
+	return WithRemote(repoPath, remoteName, func(remote *git.Remote) result.Result {
+		session := newCredentialSession(repoPath)
+		callbacks := session.callbacks()
+		if err := session.finish(remote.ConnectFetch(&callbacks)); err != nil {
+			return result.NewFailure(err)
+		}
+		heads, err := remote.Ls(prefix)
+		if err != nil {
+			return result.NewFailure(err)
+		}
+		refs := make(map[string]string)
+		for _, head := range heads {
+			if strings.HasPrefix(head.Name, prefix) {
+				refs[head.Name] = head.Id.String()
+			}
+		}
+		return result.NewSuccess(refs)
+	})
+}
+
+// Push given refspecs to the remote in a single request, using the same
+// credentials as `Fetch`. The remote accepts or rejects each reference
+// update separately, so some updates may be applied when others are
+// rejected. Fails only if the push itself fails, otherwise provides the
+// reasons for rejected updates by remote reference name.
+// @return result.Result<map[string]string, error>
 func Push(repoPath, remoteName string, refspecs []string, sig *git.Signature) result.Result {
 	return WithRemote(repoPath, remoteName, func(remote *git.Remote) result.Result {
-		return BoolResult(true, remote.Push(refspecs, &git.PushOptions{}))
+		rejected := make(map[string]string)
+		session := newCredentialSession(repoPath)
+		callbacks := session.callbacks()
+		callbacks.PushUpdateReferenceCallback = func(refname, status string) git.ErrorCode {
+			if len(status) > 0 {
+				rejected[refname] = status
+			}
+			return git.ErrOk
+		}
+		if err := session.finish(remote.Push(refspecs, &git.PushOptions{RemoteCallbacks: callbacks})); err != nil {
+			return result.NewFailure(err)
+		}
+		return result.NewSuccess(rejected)
 	})
 }
 
+// Push given refspecs to the remote atomically, so either every
+// reference update is applied or none are. libgit2 cannot request atomic
+// pushes, so git(1) pushes using its own credential helpers and SSH
+// configuration. Remotes which do not advertise atomic pushes, or a
+// missing git(1), fall back to `Push`, where each update is accepted or
+// rejected separately. Provides the reasons for rejected updates by
+// remote reference name.
+// @return result.Result<map[string]string, error>
+func AtomicPush(repoPath, remoteName string, refspecs []string, sig *git.Signature) result.Result {
+	var stdout, stderr bytes.Buffer
+	args := append([]string{"push", "--atomic", "--porcelain", remoteName}, refspecs...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = repoPath
+	cmd.Env = os.Environ()
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if _, ok := err.(*exec.ExitError); !ok && err != nil {
+		return Push(repoPath, remoteName, refspecs, sig)
+	} else if strings.Contains(stderr.String(), atomicUnsupported) {
+		return Push(repoPath, remoteName, refspecs, sig)
+	}
+	rejected, updates := parsePushStatus(stdout.String())
+	if err != nil && updates == 0 {
+		return result.NewFailure(errors.New(strings.TrimSpace(stderr.String())))
+	}
+	return result.NewSuccess(rejected)
+}
+
+// Parse the porcelain output of git-push(1), providing the reasons for
+// rejected updates by remote reference name and the number of updates
+// reported
+func parsePushStatus(output string) (map[string]string, int) {
+	rejected := make(map[string]string)
+	updates := 0
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		updates++
+		if fields[0] != pushRejectedFlag {
+			continue
+		}
+		refname := fields[1][strings.LastIndex(fields[1], ":")+1:]
+		reason := fields[2]
+		if start, end := strings.Index(reason, "("), strings.LastIndex(reason, ")"); start >= 0 && end > start {
+			reason = reason[start+1 : end]
+		}
+		rejected[refname] = reason
+	}
+	return rejected, updates
+}
+
 // Add a push refspec to a remote. Return true if added.
 // @return result.Result<bool, error>
 func AddPush(repo *git.Repository, remote *git.Remote, pushRef string) result.Result {
@@ -2,6 +2,11 @@ package git
 
 import (
 	"github.com/stvp/assert"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -12,3 +17,63 @@ func TestContains(t *testing.T) {
 	assert.False(t, contains([]string{"a", "b", "c"}, "ab"))
 	assert.False(t, contains([]string{}, "d"))
 }
+
+func TestParsePushStatus(t *testing.T) {
+	output := "To ../remote.git\n" +
+		"!\trefs/heads/a:refs/heads/a\t[remote rejected] (atomic push failure)\n" +
+		"*\trefs/heads/b:refs/heads/b\t[new branch]\n" +
+		"!\t:refs/heads/c\t[remote rejected] (hook declined)\n" +
+		"Done\n"
+	rejected, updates := parsePushStatus(output)
+	assert.Equal(t, updates, 3)
+	assert.Equal(t, rejected, map[string]string{"refs/heads/a": "atomic push failure", "refs/heads/c": "hook declined"})
+}
+
+// A repository with branches a and b, and a bare remote named origin
+// whose update hook rejects branch b
+func newPushRemoteTest(t *testing.T) (string, func(string, ...string) string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "git-comment-repo")
+	assert.Nil(t, err)
+	run := func(dir string, args ...string) string {
+		command := exec.Command("git", args...)
+		command.Dir = dir
+		command.Env = append(os.Environ(), "GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		output, err := command.Output()
+		assert.Nil(t, err)
+		return strings.TrimSpace(string(output))
+	}
+	local, remote := filepath.Join(dir, "local"), filepath.Join(dir, "remote.git")
+	run(dir, "init", "-q", "--bare", remote)
+	hook := "#!/bin/sh\ntest \"$1\" != refs/heads/b\n"
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(remote, "hooks", "update"), []byte(hook), 0755))
+	run(dir, "init", "-q", local)
+	run(local, "commit", "-q", "--allow-empty", "-m", "First")
+	run(local, "branch", "a")
+	run(local, "branch", "b")
+	run(local, "remote", "add", "origin", remote)
+	return dir, run
+}
+
+func TestAtomicPushRejected(t *testing.T) {
+	dir, run := newPushRemoteTest(t)
+	defer os.RemoveAll(dir)
+	refspecs := []string{"refs/heads/a:refs/heads/a", "refs/heads/b:refs/heads/b"}
+	rejected := AtomicPush(filepath.Join(dir, "local"), "origin", refspecs, nil)
+	assert.Nil(t, rejected.Failure)
+	assert.Equal(t, len(rejected.Success.(map[string]string)), 2)
+	assert.Equal(t, run(filepath.Join(dir, "remote.git"), "for-each-ref"), "")
+}
+
+func TestAtomicPushUnsupported(t *testing.T) {
+	dir, run := newPushRemoteTest(t)
+	defer os.RemoveAll(dir)
+	run(filepath.Join(dir, "remote.git"), "config", "receive.advertiseAtomic", "false")
+	rejected := AtomicPush(filepath.Join(dir, "local"), "origin", []string{"refs/heads/a:refs/heads/a"}, nil)
+	assert.Nil(t, rejected.Failure)
+	assert.Equal(t, len(rejected.Success.(map[string]string)), 0)
+	assert.Equal(t, run(filepath.Join(dir, "remote.git"), "for-each-ref", "--format=%(refname)"), "refs/heads/a")
+}
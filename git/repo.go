@@ -58,6 +58,12 @@ func CommentRefIterator(repo *git.Repository, iteration func(ref *git.Reference)
 	return IterateRefs(repo, path.Join(CommentRefBase, glob), iteration)
 }
 
+// Reference iterator for all comments fetched from a remote
+// @return result.Result<*git.ReferenceIterator, error>
+func RemoteCommentRefIterator(repo *git.Repository, remoteName string, iteration func(ref *git.Reference)) result.Result {
+	return IterateRefs(repo, path.Join(RemoteCommentRefBase(remoteName), glob), iteration)
+}
+
 func IterateRefs(repo *git.Repository, refPathGlob string, iteration func(ref *git.Reference)) result.Result {
 	iterator := result.NewResult(repo.NewReferenceIteratorGlob(refPathGlob))
 	return iterator.FlatMap(func(i interface{}) result.Result {
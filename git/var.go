@@ -2,60 +2,52 @@ package git
 
 import (
 	"os"
-	"os/exec"
-	"strings"
 )
 
 type variable string
 
 const (
-	gitEditor    variable = "GIT_EDITOR"
-	gitPager     variable = "GIT_PAGER"
-	gitAuthor    variable = "GIT_AUTHOR_IDENT"
-	gitCommitter variable = "GIT_COMMITTER_IDENT"
+	gitEditor variable = "GIT_EDITOR"
+	gitPager  variable = "GIT_PAGER"
+	visual    variable = "VISUAL"
+	editor    variable = "EDITOR"
+	pager     variable = "PAGER"
 )
 
 const (
 	defaultEditor          = "vi"
 	defaultPager           = "less"
+	editorConfig           = "core.editor"
+	pagerConfig            = "core.pager"
 	authorNotFoundError    = "No name or email found in git config for commenting"
 	committerNotFoundError = "No name or email found in git config for creating a comment"
 )
 
-// The editor to use for editing comments interactively, as
-// configured through git-var(1)
+// The editor to use for editing comments interactively, found in the
+// same order as git-var(1)
 func ConfiguredEditor(repoPath string) string {
-	return gitVariable(repoPath, gitEditor, defaultEditor)
+	return configuredProgram(repoPath, gitEditor, editorConfig, []variable{visual, editor}, defaultEditor)
 }
 
-// The text viewer to use for viewing text interactively, as
-// configured through git-var(1)
+// The text viewer to use for viewing text interactively, found in the
+// same order as git-var(1)
 func ConfiguredPager(repoPath string) string {
-	return gitVariable(repoPath, gitPager, defaultPager)
+	return configuredProgram(repoPath, gitPager, pagerConfig, []variable{pager}, defaultPager)
 }
 
-// The author of a piece of code as configured through git-var(1)
-func ConfiguredAuthor(repoPath string) string {
-	return gitVariable(repoPath, gitAuthor, "")
-}
-
-// The committer of a piece of code as configured through git-var(1)
-func ConfiguredCommitter(repoPath string) string {
-	return gitVariable(repoPath, gitCommitter, "")
-}
-
-func gitVariable(repoPath string, name variable, fallback string) string {
+// A program named by a git variable, then configuration, then other
+// environment variables in turn
+func configuredProgram(repoPath string, name variable, config string, others []variable, fallback string) string {
 	if env := os.Getenv(string(name)); len(env) > 0 {
 		return env
 	}
-	if err := os.Chdir(repoPath); err != nil {
-		return fallback
+	if value := ConfiguredString(repoPath, config, ""); len(value) > 0 {
+		return value
 	}
-	cmd := exec.Command("git", "var", string(name))
-	cmd.Env = os.Environ()
-	output, err := cmd.Output()
-	if err != nil {
-		return fallback
+	for _, other := range others {
+		if env := os.Getenv(string(other)); len(env) > 0 {
+			return env
+		}
 	}
-	return strings.TrimSpace(string(output))
+	return fallback
 }
@@ -0,0 +1,270 @@
+package libgitcomment
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	gg "git"
+	"github.com/kylef/result.go/src/result"
+	git "gopkg.in/libgit2/git2go.v23"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AppraiseService  = "appraise"
+	AppraiseNotesRef = "refs/notes/devtools/discuss"
+)
+
+type appraiseRange struct {
+	StartLine   uint32 `json:"startLine"`
+	StartColumn uint32 `json:"startColumn,omitempty"`
+	EndLine     uint32 `json:"endLine,omitempty"`
+	EndColumn   uint32 `json:"endColumn,omitempty"`
+}
+
+type appraiseLocation struct {
+	Commit string         `json:"commit,omitempty"`
+	Path   string         `json:"path,omitempty"`
+	Range  *appraiseRange `json:"range,omitempty"`
+}
+
+// A comment as stored by git-appraise, one per line of a note in
+// the discussion notes reference. Comments are identified by the
+// SHA1 hash of their JSON representation.
+type appraiseComment struct {
+	Timestamp   string            `json:"timestamp,omitempty"`
+	Author      string            `json:"author,omitempty"`
+	Original    string            `json:"original,omitempty"`
+	Parent      string            `json:"parent,omitempty"`
+	Location    *appraiseLocation `json:"location,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Resolved    *bool             `json:"resolved,omitempty"`
+	Version     int               `json:"v,omitempty"`
+}
+
+type appraiseCommentSlice []*appraiseComment
+
+func (cs appraiseCommentSlice) Len() int {
+	return len(cs)
+}
+
+func (cs appraiseCommentSlice) Less(i, j int) bool {
+	return cs[i].time().Before(cs[j].time())
+}
+
+func (cs appraiseCommentSlice) Swap(i, j int) {
+	cs[i], cs[j] = cs[j], cs[i]
+}
+
+// Import the comments stored by git-appraise within the repository
+// @return result.Result<*ImportSummary, error>
+func ImportAppraiseComments(repoPath string) result.Result {
+	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
+		comments := make([]*ImportedComment, 0)
+		return gg.IterateNotes(repo, AppraiseNotesRef, func(annotated *git.Oid, note *git.Note) {
+			comments = append(comments, ParseAppraiseNote(annotated.String(), note.Message())...)
+		}).FlatMap(func(value interface{}) result.Result {
+			return ImportComments(repoPath, comments)
+		})
+	})
+}
+
+// Parse the comments within a git-appraise discussion note attached to
+// a commit. Edited comments are imported with the content of the most
+// recent edit. Lines which are not valid comments have no comment, and
+// follow the valid comments.
+func ParseAppraiseNote(commit, note string) []*ImportedComment {
+	comments := make(appraiseCommentSlice, 0)
+	malformed := make([]*ImportedComment, 0)
+	for _, line := range strings.Split(note, "\n") {
+		comment := &appraiseComment{}
+		if err := json.Unmarshal([]byte(line), comment); err == nil {
+			comments = append(comments, comment)
+		} else if len(strings.TrimSpace(line)) > 0 {
+			malformed = append(malformed, &ImportedComment{nil, AppraiseService, appraiseHash([]byte(line)), ""})
+		}
+	}
+	sort.Stable(comments)
+	imported := make([]*ImportedComment, 0, len(comments))
+	byHash := make(map[string]*ImportedComment)
+	for _, comment := range comments {
+		hash := comment.hash()
+		if len(comment.Original) > 0 {
+			if original, ok := byHash[comment.Original]; ok && original.Comment != nil {
+				comment.applyEdit(original.Comment)
+			}
+			continue
+		}
+		c := comment.importedComment(commit, hash)
+		imported = append(imported, c)
+		byHash[hash] = c
+	}
+	return append(OrderReplies(imported), malformed...)
+}
+
+// Add comments which were not imported from git-appraise to the
+// git-appraise discussion notes of their commits
+// @return result.Result<CommentSlice, error>
+func ExportAppraiseComments(repoPath string) result.Result {
+	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
+		return configuredCommitter(repoPath).Analysis(func(committer interface{}) result.Result {
+			return AllComments(repo).FlatMap(func(comments interface{}) result.Result {
+				return exportAppraiseComments(repo, comments.(CommentSlice), committer.(*Person).Signature())
+			})
+		}, func(err error) result.Result {
+			return result.NewFailure(errors.New(noCommitterError))
+		})
+	})
+}
+
+func exportAppraiseComments(repo *git.Repository, comments CommentSlice, sig *git.Signature) result.Result {
+	parents := comments.ByIdentity()
+	hashes := make(map[string]string)
+	for _, comment := range comments {
+		if id, ok := comment.ExternalID(AppraiseService); ok {
+			hashes[comment.Identity()] = id
+		}
+	}
+	exported := make(CommentSlice, 0)
+	notes := make(map[string][]string)
+	for _, comment := range comments {
+		if _, ok := comment.ExternalID(AppraiseService); ok || comment.Deleted {
+			continue
+		}
+		var parent string
+		if comment.Parent != nil {
+			if parentComment, ok := parents[*comment.Parent]; ok {
+				parent = hashes[parentComment.Identity()]
+			}
+		}
+		line, err := json.Marshal(newAppraiseComment(comment, parent))
+		if err != nil {
+			return result.NewFailure(err)
+		}
+		hashes[comment.Identity()] = appraiseHash(line)
+		notes[*comment.Commit] = append(notes[*comment.Commit], string(line))
+		exported = append(exported, comment)
+	}
+	for commit, lines := range notes {
+		if err := appendAppraiseNote(repo, commit, lines, sig).Failure; err != nil {
+			return result.NewFailure(err)
+		}
+	}
+	for _, comment := range exported {
+		comment.SetExternalID(AppraiseService, hashes[comment.Identity()])
+		if err := writeCommentToDisk(repo, comment).Failure; err != nil {
+			return result.NewFailure(err)
+		}
+	}
+	return result.NewSuccess(exported)
+}
+
+// Add lines to the note of a commit, keeping the lines sorted and
+// unique in the same way as the `cat_sort_uniq` notes merge strategy
+// used by git-appraise
+// @return result.Result<*git.Oid, error>
+func appendAppraiseNote(repo *git.Repository, commit string, lines []string, sig *git.Signature) result.Result {
+	return result.NewResult(git.NewOid(commit)).FlatMap(func(oid interface{}) result.Result {
+		return gg.ReadNote(repo, AppraiseNotesRef, oid.(*git.Oid)).FlatMap(func(content interface{}) result.Result {
+			note := mergeAppraiseNote(content.(string), lines)
+			return gg.WriteNote(repo, AppraiseNotesRef, oid.(*git.Oid), note, sig)
+		})
+	})
+}
+
+func mergeAppraiseNote(content string, lines []string) string {
+	unique := make(map[string]bool)
+	for _, line := range append(strings.Split(content, "\n"), lines...) {
+		if len(line) > 0 {
+			unique[line] = true
+		}
+	}
+	merged := make([]string, 0, len(unique))
+	for line := range unique {
+		merged = append(merged, line)
+	}
+	sort.Strings(merged)
+	return strings.Join(merged, "\n") + "\n"
+}
+
+func newAppraiseComment(comment *Comment, parent string) *appraiseComment {
+	location := &appraiseLocation{Commit: *comment.Commit}
+	if ref := comment.FileRef; ref != nil && len(ref.Path) > 0 {
+		location.Path = ref.Path
+		if ref.Line > 0 && ref.LineType == RefLineTypeNew {
+			location.Range = &appraiseRange{StartLine: uint32(ref.Line)}
+		}
+	}
+	return &appraiseComment{
+		Timestamp:   strconv.FormatInt(comment.Author.Date.Unix(), 10),
+		Author:      comment.Author.Email,
+		Parent:      parent,
+		Location:    location,
+		Description: comment.Content,
+		Resolved:    comment.Resolved,
+	}
+}
+
+func (c *appraiseComment) importedComment(commit, hash string) *ImportedComment {
+	if c.Location != nil && len(c.Location.Commit) > 0 {
+		commit = c.Location.Commit
+	}
+	imported := &ImportedComment{nil, AppraiseService, hash, c.Parent}
+	value, err := NewComment(c.Description, commit, c.fileRef(), c.person()).Dematerialize()
+	if err != nil {
+		return imported
+	}
+	imported.Comment = value.(*Comment)
+	imported.Comment.Resolved = c.Resolved
+	return imported
+}
+
+// Replace the content of a comment with an edit
+func (c *appraiseComment) applyEdit(comment *Comment) {
+	if len(c.Description) > 0 {
+		comment.Amend(c.Description, c.person())
+	}
+	if c.Resolved != nil {
+		comment.Resolved = c.Resolved
+	}
+}
+
+func (c *appraiseComment) fileRef() *FileRef {
+	if c.Location == nil || len(c.Location.Path) == 0 {
+		return &FileRef{}
+	}
+	ref := &FileRef{Path: c.Location.Path}
+	if c.Location.Range != nil {
+		ref.Line = int(c.Location.Range.StartLine)
+	}
+	return ref
+}
+
+func (c *appraiseComment) person() *Person {
+	date := c.time()
+	return &Person{c.Author, c.Author, date, date.Format("-0700")}
+}
+
+func (c *appraiseComment) time() time.Time {
+	timestamp, err := strconv.ParseInt(c.Timestamp, 10, 64)
+	if err != nil {
+		return time.Unix(0, 0)
+	}
+	return time.Unix(timestamp, 0)
+}
+
+func (c *appraiseComment) hash() string {
+	content, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return appraiseHash(content)
+}
+
+func appraiseHash(content []byte) string {
+	return fmt.Sprintf("%x", sha1.Sum(content))
+}
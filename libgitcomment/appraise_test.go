@@ -0,0 +1,106 @@
+package libgitcomment
+
+import (
+	"github.com/stvp/assert"
+	"os"
+	"testing"
+)
+
+const appraiseNote = `{"timestamp":"1450315153","author":"ojarjur@google.com","location":{"commit":"a8e5a3d1c7f2b4e6d9a0c3b5f7e1d2c4b6a8e0f3","path":"review/review.go","range":{"startLine":42}},"description":"Please add a comment.","v":0}
+not a comment
+{"timestamp":"1450315200","author":"jane@example.com","parent":"PARENT","description":"Done.","resolved":true}
+{"timestamp":"1450315100","author":"jane@example.com","description":"LGTM","resolved":true}
+`
+
+func parsedAppraiseNote() []*ImportedComment {
+	return ParseAppraiseNote("0155eb4229851634a0f03eb265b69f5a2d56f341", appraiseNote)
+}
+
+func TestParseAppraiseNoteCount(t *testing.T) {
+	assert.Equal(t, len(parsedAppraiseNote()), 4)
+}
+
+func TestParseAppraiseNoteMalformed(t *testing.T) {
+	comments := parsedAppraiseNote()
+	assert.Nil(t, comments[3].Comment)
+	assert.Equal(t, comments[3].ID, appraiseHash([]byte("not a comment")))
+	empty := `{"timestamp":"1450315300","author":"jane@example.com","description":""}`
+	comments = ParseAppraiseNote("0155eb4229851634a0f03eb265b69f5a2d56f341", empty+"\n")
+	assert.Equal(t, len(comments), 1)
+	assert.Nil(t, comments[0].Comment)
+}
+
+func TestImportAppraiseCommentsCountsMalformedComments(t *testing.T) {
+	dir, run := notesRepository(t)
+	defer os.RemoveAll(dir)
+	run("Test", "notes", "--ref", AppraiseNotesRef, "add", "-m", appraiseNote, "HEAD")
+	summary, err := ImportAppraiseComments(dir).Dematerialize()
+	assert.Nil(t, err)
+	assert.Equal(t, len(summary.(*ImportSummary).Invalid), 1)
+	assert.Equal(t, len(summary.(*ImportSummary).Added)+len(summary.(*ImportSummary).Orphaned), 3)
+}
+
+func TestParseAppraiseNoteOrder(t *testing.T) {
+	comments := parsedAppraiseNote()
+	assert.Equal(t, comments[0].Comment.Content, "LGTM")
+	assert.Equal(t, comments[1].Comment.Content, "Please add a comment.")
+	assert.Equal(t, comments[2].Comment.Content, "Done.")
+	assert.Equal(t, comments[2].InReplyTo, "PARENT")
+}
+
+func TestParseAppraiseNoteLocation(t *testing.T) {
+	comments := parsedAppraiseNote()
+	assert.Equal(t, *comments[1].Comment.Commit, "a8e5a3d1c7f2b4e6d9a0c3b5f7e1d2c4b6a8e0f3")
+	assert.Equal(t, *comments[1].Comment.FileRef, FileRef{"review/review.go", 42, RefLineTypeNew})
+	assert.Equal(t, *comments[0].Comment.Commit, "0155eb4229851634a0f03eb265b69f5a2d56f341")
+	assert.Equal(t, *comments[0].Comment.FileRef, FileRef{})
+}
+
+func TestParseAppraiseNoteAuthor(t *testing.T) {
+	comment := parsedAppraiseNote()[1].Comment
+	assert.Equal(t, comment.Author.Email, "ojarjur@google.com")
+	assert.Equal(t, comment.Author.Date.Unix(), int64(1450315153))
+}
+
+func TestParseAppraiseNoteResolved(t *testing.T) {
+	comments := parsedAppraiseNote()
+	assert.True(t, *comments[0].Comment.Resolved)
+	assert.Nil(t, comments[1].Comment.Resolved)
+}
+
+func TestParseAppraiseNoteHash(t *testing.T) {
+	line := `{"timestamp":"1450315100","author":"jane@example.com","description":"LGTM","resolved":true}`
+	comments := ParseAppraiseNote("0155eb4229851634a0f03eb265b69f5a2d56f341", line)
+	assert.Equal(t, comments[0].ID, appraiseHash([]byte(line)))
+}
+
+func TestParseAppraiseNoteEdit(t *testing.T) {
+	original := `{"timestamp":"1450315100","author":"jane@example.com","description":"LGTM"}`
+	edit := `{"timestamp":"1450315300","author":"jane@example.com","original":"` + appraiseHash([]byte(original)) + `","description":"LGTM!","resolved":false}`
+	comments := ParseAppraiseNote("0155eb4229851634a0f03eb265b69f5a2d56f341", edit+"\n"+original)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].Comment.Content, "LGTM!")
+	assert.Equal(t, comments[0].Comment.Amender.Date.Unix(), int64(1450315300))
+	assert.False(t, *comments[0].Comment.Resolved)
+}
+
+func TestNewAppraiseComment(t *testing.T) {
+	c, _ := NewComment("Rename this", "abc", &FileRef{"main.go", 12, RefLineTypeNew}, parsedAppraiseNote()[0].Comment.Author).Dematerialize()
+	comment := newAppraiseComment(c.(*Comment), "PARENT")
+	assert.Equal(t, comment.Timestamp, "1450315100")
+	assert.Equal(t, comment.Author, "jane@example.com")
+	assert.Equal(t, comment.Parent, "PARENT")
+	assert.Equal(t, *comment.Location, appraiseLocation{"abc", "main.go", &appraiseRange{StartLine: 12}})
+}
+
+func TestNewAppraiseCommentOldLine(t *testing.T) {
+	c, _ := NewComment("Why?", "abc", &FileRef{"main.go", 12, RefLineTypeOld}, parsedAppraiseNote()[0].Comment.Author).Dematerialize()
+	comment := newAppraiseComment(c.(*Comment), "")
+	assert.Equal(t, comment.Location.Path, "main.go")
+	assert.Nil(t, comment.Location.Range)
+}
+
+func TestMergeAppraiseNote(t *testing.T) {
+	merged := mergeAppraiseNote("b\na\n", []string{"c", "a"})
+	assert.Equal(t, merged, "a\nb\nc\n")
+}
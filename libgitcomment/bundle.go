@@ -0,0 +1,187 @@
+package libgitcomment
+
+import (
+	"fmt"
+	gg "git"
+	"github.com/blang/semver"
+	"github.com/kylef/result.go/src/result"
+	git "gopkg.in/libgit2/git2go.v23"
+	"io"
+	"path"
+)
+
+const (
+	bundledMessageFormat = "Unbundled comment ref [%v]"
+	missingBlobError     = "Invalid bundle: missing comment %v"
+	invalidBundledError  = "Invalid bundle: %v: %v"
+	bundleVersionError   = "The bundle was created by a newer version of git-comment (%v). Please upgrade."
+)
+
+// Outcome of unbundling comments
+type UnbundleSummary struct {
+	// Comments added to the repository
+	Added CommentSlice
+	// Comments which already exist or are superseded by a local version
+	Skipped CommentSlice
+	// Comments on commits which do not exist in the repository
+	Orphaned CommentSlice
+}
+
+// Write comments on the commits in a revision range, or all comments if
+// no range is provided, to a bundle which can be unbundled into another
+// repository. Deleted comments are included so deletions are
+// transferred.
+// @return result.Result<CommentSlice, error>
+func CreateCommentBundle(repoPath, revisions string, output io.Writer) result.Result {
+	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
+		comments := AllComments(repo)
+		if len(revisions) > 0 {
+			comments = CommentsOnCommittish(repoPath, revisions)
+		}
+		return comments.FlatMap(func(value interface{}) result.Result {
+			bundle := gg.NewBundle()
+			bundled := value.(CommentSlice)
+			for _, comment := range bundled {
+				blob, err := gg.LookupBlob(repo, *comment.ID, commentNotFoundError).Dematerialize()
+				if err != nil {
+					return result.NewFailure(err)
+				}
+				refPath, err := commentRefPath(comment).Dematerialize()
+				if err != nil {
+					return result.NewFailure(err)
+				}
+				id := bundle.AddBlob(blob.(*git.Blob).Contents())
+				bundle.Refs = append(bundle.Refs, &gg.BundleRef{Name: refPath.(string), ID: id})
+			}
+			if version, err := readVersion(repo).Dematerialize(); err == nil {
+				id := bundle.AddBlob([]byte(version.(string)))
+				bundle.Refs = append(bundle.Refs, &gg.BundleRef{Name: path.Join(gg.CommentRefBase, versionRef), ID: id})
+			}
+			if err := bundle.Write(output); err != nil {
+				return result.NewFailure(err)
+			}
+			return result.NewSuccess(bundled)
+		})
+	})
+}
+
+// Add the comments within a bundle to the repository. Comments on
+// commits which do not exist are not added. Comments are skipped if they
+// already exist or a newer or deleted version exists locally, otherwise
+// they replace any local versions.
+// @return result.Result<*UnbundleSummary, error>
+func UnbundleComments(repoPath string, input io.Reader) result.Result {
+	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
+		bundle, err := gg.ReadBundle(input)
+		if err != nil {
+			return result.NewFailure(err)
+		}
+		currentVersion := readVersion(repo).Recover("").(string)
+		return bundledComments(bundle, currentVersion).FlatMap(func(bundled interface{}) result.Result {
+			return AllComments(repo).FlatMap(func(local interface{}) result.Result {
+				hasCommit := func(hash string) bool {
+					return gg.ResolveSingleCommitHash(repo, hash).Failure == nil
+				}
+				summary, superseded := planUnbundle(local.(CommentSlice), bundled.(CommentSlice), hasCommit)
+				return writeUnbundled(repo, bundle, summary, superseded)
+			})
+		})
+	})
+}
+
+// Find the comments within a bundle, checking that each is well-formed
+// and referenced at the expected path, and that the bundle was not
+// created by a newer version of git-comment
+// @return result.Result<CommentSlice, error>
+func bundledComments(bundle *gg.Bundle, currentVersion string) result.Result {
+	comments := make(CommentSlice, 0, len(bundle.Refs))
+	for _, ref := range bundle.Refs {
+		content, ok := bundle.Blobs[ref.ID]
+		if ref.Name == path.Join(gg.CommentRefBase, versionRef) {
+			if ok && isNewerVersion(string(content), currentVersion) {
+				return result.NewFailure(fmt.Errorf(bundleVersionError, string(content)))
+			}
+			continue
+		} else if !ok {
+			return result.NewFailure(fmt.Errorf(missingBlobError, ref.ID))
+		}
+		commit, err := validateRefPath(&RefUpdate{ref.Name, zeroID, ref.ID})
+		if err != nil {
+			return result.NewFailure(fmt.Errorf(invalidBundledError, ref.Name, err))
+		}
+		comment, err := validateRefComment(commit, string(content))
+		if err != nil {
+			return result.NewFailure(fmt.Errorf(invalidBundledError, ref.Name, err))
+		}
+		comment.ID = &ref.ID
+		comments = append(comments, comment)
+	}
+	return result.NewSuccess(comments)
+}
+
+// Whether a version is newer than the current version
+func isNewerVersion(version, current string) bool {
+	bundled, err := semver.Make(version)
+	if err != nil {
+		return false
+	}
+	installed, err := semver.Make(current)
+	return err == nil && bundled.GT(installed)
+}
+
+// Decide which bundled comments to add, returning the local versions
+// which are superseded by added comments
+func planUnbundle(local, bundled CommentSlice, hasCommit func(hash string) bool) (*UnbundleSummary, CommentSlice) {
+	summary := &UnbundleSummary{CommentSlice{}, CommentSlice{}, CommentSlice{}}
+	superseded := make(CommentSlice, 0)
+	localVersions := groupVersions(local)
+	for _, comment := range bundled {
+		versions := localVersions[comment.Identity()]
+		if !hasCommit(*comment.Commit) {
+			summary.Orphaned = append(summary.Orphaned, comment)
+		} else if latestVersion(append(CommentSlice{comment}, versions...)) != comment || containsVersion(versions, comment) {
+			summary.Skipped = append(summary.Skipped, comment)
+		} else {
+			summary.Added = append(removeVersions(summary.Added, versions), comment)
+			superseded = append(superseded, versions...)
+			localVersions[comment.Identity()] = CommentSlice{comment}
+		}
+	}
+	return summary, superseded
+}
+
+func removeVersions(comments, versions CommentSlice) CommentSlice {
+	remaining := make(CommentSlice, 0, len(comments))
+	for _, comment := range comments {
+		if !containsVersion(versions, comment) {
+			remaining = append(remaining, comment)
+		}
+	}
+	return remaining
+}
+
+// Write the blobs and references of added comments, and remove local
+// versions which were superseded
+// @return result.Result<*UnbundleSummary, error>
+func writeUnbundled(repo *git.Repository, bundle *gg.Bundle, summary *UnbundleSummary, superseded CommentSlice) result.Result {
+	for _, comment := range summary.Added {
+		refPath, err := commentRefPath(comment).Dematerialize()
+		if err != nil {
+			return result.NewFailure(err)
+		}
+		oid, err := repo.CreateBlobFromBuffer(bundle.Blobs[*comment.ID])
+		if err != nil {
+			return result.NewFailure(err)
+		}
+		message := fmt.Sprintf(bundledMessageFormat, (*comment.ID)[:7])
+		if _, err := repo.References.Create(refPath.(string), oid, true, message); err != nil {
+			return result.NewFailure(err)
+		}
+	}
+	for _, comment := range superseded {
+		if err := deleteReference(repo, comment, *comment.ID); err != nil {
+			return result.NewFailure(err)
+		}
+	}
+	return result.NewSuccess(summary)
+}
@@ -0,0 +1,65 @@
+package libgitcomment
+
+import (
+	"github.com/stvp/assert"
+	"testing"
+)
+
+func hasSyncedCommit(hash string) bool {
+	return hash == syncedCommit
+}
+
+func TestPlanUnbundleAddsNewComments(t *testing.T) {
+	bundled := syncedComment("a1", 1437498000)
+	summary, superseded := planUnbundle(CommentSlice{}, CommentSlice{bundled}, hasSyncedCommit)
+	assert.Equal(t, summary.Added, CommentSlice{bundled})
+	assert.Equal(t, len(summary.Skipped)+len(summary.Orphaned)+len(superseded), 0)
+}
+
+func TestPlanUnbundleOrphaned(t *testing.T) {
+	bundled := syncedComment("a1", 1437498000)
+	bundled.Commit = &otherSyncedCommit
+	summary, _ := planUnbundle(CommentSlice{}, CommentSlice{bundled}, hasSyncedCommit)
+	assert.Equal(t, summary.Orphaned, CommentSlice{bundled})
+	assert.Equal(t, len(summary.Added), 0)
+}
+
+func TestPlanUnbundleSkipsExisting(t *testing.T) {
+	local := syncedComment("a1", 1437498000)
+	bundled := syncedComment("a1", 1437498000)
+	summary, _ := planUnbundle(CommentSlice{local}, CommentSlice{bundled}, hasSyncedCommit)
+	assert.Equal(t, summary.Skipped, CommentSlice{bundled})
+}
+
+func TestPlanUnbundleSkipsOlderVersions(t *testing.T) {
+	local := syncedComment("b2", 1437498360)
+	bundled := syncedComment("a1", 1437498000)
+	summary, superseded := planUnbundle(CommentSlice{local}, CommentSlice{bundled}, hasSyncedCommit)
+	assert.Equal(t, summary.Skipped, CommentSlice{bundled})
+	assert.Equal(t, len(superseded), 0)
+}
+
+func TestPlanUnbundleReplacesOlderVersions(t *testing.T) {
+	local := syncedComment("a1", 1437498000)
+	bundled := syncedComment("b2", 1437498360)
+	bundled.Deleted = true
+	summary, superseded := planUnbundle(CommentSlice{local}, CommentSlice{bundled}, hasSyncedCommit)
+	assert.Equal(t, summary.Added, CommentSlice{bundled})
+	assert.Equal(t, superseded, CommentSlice{local})
+}
+
+func TestPlanUnbundleKeepsSeparateComments(t *testing.T) {
+	local := separateComment("a1")
+	first := separateComment("b2")
+	second := separateComment("c3")
+	summary, superseded := planUnbundle(CommentSlice{local}, CommentSlice{first, second}, hasSyncedCommit)
+	assert.Equal(t, summary.Added, CommentSlice{first, second})
+	assert.Equal(t, len(summary.Skipped), 0)
+	assert.Equal(t, len(superseded), 0)
+}
+
+func TestIsNewerVersion(t *testing.T) {
+	assert.True(t, isNewerVersion("0.2.0", "0.1.0"))
+	assert.False(t, isNewerVersion("0.1.0", "0.1.0"))
+	assert.False(t, isNewerVersion("0.1.0", ""))
+}
@@ -2,19 +2,28 @@ package libgitcomment
 
 import (
 	"errors"
+	"fmt"
 	"github.com/kylef/result.go/src/result"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type Comment struct {
-	Author  *Person
-	Content string
-	Amender *Person
-	Commit  *string
-	ID      *string
-	Deleted bool
-	FileRef *FileRef
+	Author      *Person
+	Content     string
+	Amender     *Person
+	Commit      *string
+	ID          *string
+	Deleted     bool
+	FileRef     *FileRef
+	Parent      *string
+	Origin      *string
+	ExternalIDs map[string]string
+	Resolved    *bool
+	Language    *string
+	Signature   *string
 }
 
 const timeFormat string = time.RFC822Z
@@ -22,13 +31,21 @@ const timeFormat string = time.RFC822Z
 type CommentSlice []*Comment
 
 const (
-	authorKey  = "author"
-	commitKey  = "commit"
-	amenderKey = "amender"
-	fileRefKey = "file"
-	deletedKey = "deleted"
+	authorKey   = "author"
+	commitKey   = "commit"
+	amenderKey  = "amender"
+	fileRefKey  = "file"
+	deletedKey  = "deleted"
+	parentKey   = "parent"
+	originKey   = "origin"
+	externalKey = "external"
+	resolvedKey = "resolved"
+	languageKey = "language"
+	gpgsigKey   = "gpgsig"
 )
 
+const externalIDSeparator = ":"
+
 func (cs CommentSlice) Len() int {
 	return len(cs)
 }
@@ -41,6 +58,19 @@ func (cs CommentSlice) Swap(i, j int) {
 	cs[i], cs[j] = cs[j], cs[i]
 }
 
+// Index comments by identity for finding the parents of replies, and by
+// identifier for replies made before comments recorded their origin
+func (cs CommentSlice) ByIdentity() map[string]*Comment {
+	byIdentity := make(map[string]*Comment)
+	for _, comment := range cs {
+		byIdentity[*comment.ID] = comment
+	}
+	for _, comment := range cs {
+		byIdentity[comment.Identity()] = comment
+	}
+	return byIdentity
+}
+
 // Creates a new comment using provided content and author
 func NewComment(message string, commit string, fileRef *FileRef, author *Person) result.Result {
 	const missingContentMessage = "No message content provided"
@@ -58,6 +88,12 @@ func NewComment(message string, commit string, fileRef *FileRef, author *Person)
 		nil,
 		false,
 		fileRef,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	})
 }
 
@@ -70,18 +106,83 @@ func DeserializeComment(content string) result.Result {
 	comment.Author = blob.GetPerson(authorKey)
 	comment.Amender = blob.GetPerson(amenderKey)
 	comment.FileRef = blob.GetFileRef(fileRefKey)
+	comment.Deleted = blob.Get(deletedKey) != nil
+	comment.Parent = blob.Get(parentKey)
+	comment.Origin = blob.Get(originKey)
+	comment.ExternalIDs = deserializeExternalIDs(blob.Get(externalKey))
+	comment.Resolved = deserializeResolved(blob.Get(resolvedKey))
+	comment.Language = blob.Get(languageKey)
+	comment.Signature = blob.Get(gpgsigKey)
 	return result.NewSuccess(comment)
 }
 
+// Identifier shared by every version of the comment, which is the
+// identifier of its first version. Versions after the first record it
+// as their origin.
+func (c *Comment) Identity() string {
+	if c.Origin != nil {
+		return *c.Origin
+	}
+	return *c.ID
+}
+
 // First line of the comment content
 func (c *Comment) Title() string {
 	return strings.Split(c.Content, "\n")[0]
 }
 
-// Update the message content of the comment
+// Update the message content of the comment, removing any signature
+// of the previous content
 func (c *Comment) Amend(message string, amender *Person) {
 	c.Content = message
 	c.Amender = amender
+	c.Signature = nil
+}
+
+// Content covered by the signature of the comment, which is the
+// serialized comment without the signature. External identifiers and
+// the origin are excluded, so a signed comment can be synchronized with
+// other systems and rewritten without signing it again.
+func (c *Comment) SignedContent() string {
+	unsigned := *c
+	unsigned.Signature = nil
+	unsigned.ExternalIDs = nil
+	unsigned.Origin = nil
+	return unsigned.Serialize()
+}
+
+// Add a signature over the content of the comment
+// @return result.Result<*Comment, error>
+func (c *Comment) Sign(signer Signer) result.Result {
+	return signer.Sign(c.SignedContent()).FlatMap(func(signature interface{}) result.Result {
+		value := signature.(string)
+		c.Signature = &value
+		return result.NewSuccess(c)
+	})
+}
+
+// Check the signature of the comment
+// @return result.Result<*Verification, error>
+func (c *Comment) Verify(signer Signer) result.Result {
+	if c.Signature == nil {
+		return result.NewSuccess(&Verification{Status: SignatureNone})
+	}
+	return signer.Verify(c.SignedContent(), *c.Signature)
+}
+
+// Identifier of the comment within an external service, if
+// the comment was imported from or exported to it
+func (c *Comment) ExternalID(service string) (string, bool) {
+	id, ok := c.ExternalIDs[service]
+	return id, ok
+}
+
+// Record the identifier of the comment within an external service
+func (c *Comment) SetExternalID(service, identifier string) {
+	if c.ExternalIDs == nil {
+		c.ExternalIDs = make(map[string]string)
+	}
+	c.ExternalIDs[service] = identifier
 }
 
 // Generate content of git object for comment
@@ -94,6 +195,14 @@ func (c *Comment) Amend(message string, amender *Person) {
 //   created 1243040974 -0900
 //   amender Delisa Mason <name@example.com>
 //   amended 1243040974 -0900
+//   origin 5f4d8b3b1bd1b05b1d7dbb7d3a2c7bb1e6f0c9a2
+//   parent 23caf9710a71e3736597415c57bdcf5eebae6bcb
+//   external github:1734
+//   resolved true
+//   language fr
+//   gpgsig -----BEGIN PGP SIGNATURE-----
+//    <signature lines>
+//    -----END PGP SIGNATURE-----
 //
 //   Too many levels of indentation here.
 // ```
@@ -104,6 +213,24 @@ func (c *Comment) Serialize() string {
 	blob.Set(fileRefKey, c.FileRef.Serialize())
 	blob.Set(authorKey, c.Author.Serialize())
 	blob.Set(amenderKey, c.Amender.Serialize())
+	if c.Origin != nil {
+		blob.Set(originKey, *c.Origin)
+	}
+	if c.Parent != nil {
+		blob.Set(parentKey, *c.Parent)
+	}
+	if len(c.ExternalIDs) > 0 {
+		blob.Set(externalKey, serializeExternalIDs(c.ExternalIDs))
+	}
+	if c.Resolved != nil {
+		blob.Set(resolvedKey, strconv.FormatBool(*c.Resolved))
+	}
+	if c.Language != nil {
+		blob.Set(languageKey, *c.Language)
+	}
+	if c.Signature != nil {
+		blob.Set(gpgsigKey, *c.Signature)
+	}
 	if c.Deleted {
 		blob.Set(deletedKey, "true")
 	} else {
@@ -111,3 +238,39 @@ func (c *Comment) Serialize() string {
 	}
 	return blob.Serialize()
 }
+
+// Serialize external identifiers as a sorted list of
+// `service:identifier` pairs so the content is stable
+func serializeExternalIDs(ids map[string]string) string {
+	pairs := make([]string, 0, len(ids))
+	for service, id := range ids {
+		pairs = append(pairs, fmt.Sprintf("%v%v%v", service, externalIDSeparator, id))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, itemSeparator)
+}
+
+func deserializeExternalIDs(content *string) map[string]string {
+	if content == nil {
+		return nil
+	}
+	ids := make(map[string]string)
+	for _, pair := range strings.Fields(*content) {
+		parts := strings.SplitN(pair, externalIDSeparator, 2)
+		if len(parts) == 2 {
+			ids[parts[0]] = parts[1]
+		}
+	}
+	return ids
+}
+
+func deserializeResolved(content *string) *bool {
+	if content == nil {
+		return nil
+	}
+	resolved, err := strconv.ParseBool(*content)
+	if err != nil {
+		return nil
+	}
+	return &resolved
+}
@@ -139,3 +139,115 @@ func TestDeserializeComment(t *testing.T) {
 	assert.Equal(t, *comment.Amender, *newComment.Amender)
 	assert.Equal(t, comment.Content, newComment.Content)
 }
+
+func TestDeserializeDeletedComment(t *testing.T) {
+	author := &Person{"Morpheus", "redpill@example.com", time.Unix(1437498360, 0), "-0600"}
+	c, _ := NewComment("Pick one", "afdafdafd", new(FileRef), author).Dematerialize()
+	comment := c.(*Comment)
+	comment.Deleted = true
+	newC, err := DeserializeComment(comment.Serialize()).Dematerialize()
+	assert.Nil(t, err)
+	assert.True(t, newC.(*Comment).Deleted)
+}
+
+func TestSerializeCommentExternalIDs(t *testing.T) {
+	author := &Person{"Selina Kyle", "cat@example.com", time.Unix(1437498360, 0), "+1100"}
+	c, _ := NewComment("Needs a test", "acdacdacd", new(FileRef), author).Dematerialize()
+	comment := c.(*Comment)
+	parent := "23caf9710a71e3736597415c57bdcf5eebae6bcb"
+	comment.Parent = &parent
+	comment.SetExternalID("gitlab", "88")
+	comment.SetExternalID("github", "1734")
+	lines := strings.Split(comment.Serialize(), "\n")
+	assert.Equal(t, lines[4], "parent 23caf9710a71e3736597415c57bdcf5eebae6bcb")
+	assert.Equal(t, lines[5], "external github:1734 gitlab:88")
+}
+
+func TestDeserializeCommentExternalIDs(t *testing.T) {
+	author := &Person{"Selina Kyle", "cat@example.com", time.Unix(1437498360, 0), "+1100"}
+	c, _ := NewComment("Needs a test", "acdacdacd", new(FileRef), author).Dematerialize()
+	comment := c.(*Comment)
+	parent := "23caf9710a71e3736597415c57bdcf5eebae6bcb"
+	comment.Parent = &parent
+	comment.SetExternalID("github", "1734")
+	newC, err := DeserializeComment(comment.Serialize()).Dematerialize()
+	newComment := newC.(*Comment)
+	assert.Nil(t, err)
+	assert.Equal(t, *newComment.Parent, parent)
+	id, ok := newComment.ExternalID("github")
+	assert.True(t, ok)
+	assert.Equal(t, id, "1734")
+	_, ok = newComment.ExternalID("gitlab")
+	assert.False(t, ok)
+}
+
+func TestSerializeCommentResolved(t *testing.T) {
+	author := &Person{"Selina Kyle", "cat@example.com", time.Unix(1437498360, 0), "+1100"}
+	c, _ := NewComment("Needs a test", "acdacdacd", new(FileRef), author).Dematerialize()
+	comment := c.(*Comment)
+	resolved := false
+	comment.Resolved = &resolved
+	newC, err := DeserializeComment(comment.Serialize()).Dematerialize()
+	assert.Nil(t, err)
+	assert.False(t, *newC.(*Comment).Resolved)
+}
+
+func TestDeserializeCommentUnresolved(t *testing.T) {
+	author := &Person{"Selina Kyle", "cat@example.com", time.Unix(1437498360, 0), "+1100"}
+	c, _ := NewComment("Needs a test", "acdacdacd", new(FileRef), author).Dematerialize()
+	newC, err := DeserializeComment(c.(*Comment).Serialize()).Dematerialize()
+	assert.Nil(t, err)
+	assert.Nil(t, newC.(*Comment).Resolved)
+}
+
+func TestSerializeCommentOrigin(t *testing.T) {
+	author := &Person{"Selina Kyle", "cat@example.com", time.Unix(1437498360, 0), "+1100"}
+	c, _ := NewComment("Needs a test", "acdacdacd", new(FileRef), author).Dematerialize()
+	comment := c.(*Comment)
+	origin := "5f4d8b3b1bd1b05b1d7dbb7d3a2c7bb1e6f0c9a2"
+	comment.Origin = &origin
+	lines := strings.Split(comment.Serialize(), "\n")
+	assert.Equal(t, lines[4], "origin 5f4d8b3b1bd1b05b1d7dbb7d3a2c7bb1e6f0c9a2")
+	newC, err := DeserializeComment(comment.Serialize()).Dematerialize()
+	assert.Nil(t, err)
+	assert.Equal(t, *newC.(*Comment).Origin, origin)
+}
+
+func TestCommentIdentity(t *testing.T) {
+	id := "a1"
+	origin := "a0"
+	comment := &Comment{ID: &id}
+	assert.Equal(t, comment.Identity(), "a1")
+	comment.Origin = &origin
+	assert.Equal(t, comment.Identity(), "a0")
+}
+
+func TestSignedContentExcludesOrigin(t *testing.T) {
+	author := &Person{"Selina Kyle", "cat@example.com", time.Unix(1437498360, 0), "+1100"}
+	c, _ := NewComment("Needs a test", "acdacdacd", new(FileRef), author).Dematerialize()
+	comment := c.(*Comment)
+	signed := comment.SignedContent()
+	origin := "a0"
+	comment.Origin = &origin
+	assert.Equal(t, comment.SignedContent(), signed)
+}
+
+func TestCommentSliceByIdentity(t *testing.T) {
+	origin, id, legacyID := "a1", "b2", "c3"
+	amended := &Comment{ID: &id, Origin: &origin}
+	legacy := &Comment{ID: &legacyID}
+	byIdentity := CommentSlice{amended, legacy}.ByIdentity()
+	assert.Equal(t, byIdentity["a1"], amended)
+	assert.Equal(t, byIdentity["b2"], amended)
+	assert.Equal(t, byIdentity["c3"], legacy)
+}
+
+func TestSerializeCommentLanguage(t *testing.T) {
+	c, _ := NewComment("Vérifier les erreurs", "abc", new(FileRef), &Person{"Selina Kyle", "cat@example.com", time.Unix(1437498360, 0), "+1100"}).Dematerialize()
+	comment := c.(*Comment)
+	language := "fr"
+	comment.Language = &language
+	deserialized, err := DeserializeComment(comment.Serialize()).Dematerialize()
+	assert.Nil(t, err)
+	assert.Equal(t, *deserialized.(*Comment).Language, "fr")
+}
@@ -2,5 +2,9 @@ package libgitcomment
 
 const (
 	commentNotFoundError = "Comment not found"
+	invalidCommitError   = "Comment %v does not have a valid commit"
 	noCommitterError     = "No committer configured"
+	rejectedPushError    = "Remote rejected comment %v: %v"
+	partialDeleteError   = "Deleted %d of %d remote comment references\n%v"
+	rejectedSyncError    = "Remote rejected %d of %d comment reference updates\n%v"
 )
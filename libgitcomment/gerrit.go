@@ -0,0 +1,290 @@
+package libgitcomment
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	gg "git"
+	"github.com/kylef/result.go/src/result"
+	git "gopkg.in/libgit2/git2go.v23"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	GerritService       = "gerrit"
+	GerritPatchSetLevel = "/PATCHSET_LEVEL"
+	gerritCommitMessage = "/COMMIT_MSG"
+	gerritSideParent    = "PARENT"
+	gerritTimeFormat    = "2006-01-02 15:04:05.999999999"
+	gerritEmailFormat   = "%d@users.noreply.gerrit"
+	gerritJSONPrefix    = ")]}'"
+)
+
+type gerritAccount struct {
+	AccountID int64  `json:"_account_id"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	Username  string `json:"username"`
+}
+
+type gerritRange struct {
+	StartLine int `json:"start_line"`
+	EndLine   int `json:"end_line"`
+}
+
+// A comment as returned by the Gerrit REST API
+type gerritComment struct {
+	ID        string        `json:"id"`
+	InReplyTo string        `json:"in_reply_to"`
+	Line      int           `json:"line"`
+	Range     *gerritRange  `json:"range"`
+	Side      string        `json:"side"`
+	Message   string        `json:"message"`
+	Updated   string        `json:"updated"`
+	CommitID  string        `json:"commit_id"`
+	Author    gerritAccount `json:"author"`
+	path      string
+}
+
+// A comment to be published within a Gerrit review
+type GerritCommentInput struct {
+	Line      int    `json:"line,omitempty"`
+	Side      string `json:"side,omitempty"`
+	Message   string `json:"message"`
+	InReplyTo string `json:"in_reply_to,omitempty"`
+}
+
+// The body of a request to publish a review to a Gerrit change via
+// `POST /changes/{change-id}/revisions/{revision-id}/review`
+type GerritReviewInput struct {
+	Comments map[string][]*GerritCommentInput `json:"comments"`
+}
+
+type gerritCommentSlice []*gerritComment
+
+func (cs gerritCommentSlice) Len() int {
+	return len(cs)
+}
+
+func (cs gerritCommentSlice) Less(i, j int) bool {
+	return cs[i].Updated < cs[j].Updated
+}
+
+func (cs gerritCommentSlice) Swap(i, j int) {
+	cs[i], cs[j] = cs[j], cs[i]
+}
+
+// Import comments from the JSON output of
+// `GET /changes/{change-id}/revisions/{revision-id}/comments`. Comments
+// are added to the commit specified in the comment or else to the
+// provided patch set commit. Comments published from a Gerrit export
+// are not added again, but record their Gerrit identifier instead.
+// @return result.Result<*ImportSummary, error>
+func ImportGerritComments(repoPath string, content io.Reader, commit string) result.Result {
+	return ParseGerritComments(content, commit).FlatMap(func(comments interface{}) result.Result {
+		return linkExportedGerritComments(repoPath, comments.([]*ImportedComment))
+	}).FlatMap(func(comments interface{}) result.Result {
+		return ImportComments(repoPath, comments.([]*ImportedComment))
+	})
+}
+
+// Record the Gerrit identifiers of comments which were exported and
+// then published to Gerrit, so they are neither imported nor exported
+// again
+// @return result.Result<[]*ImportedComment, error>
+func linkExportedGerritComments(repoPath string, comments []*ImportedComment) result.Result {
+	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
+		return AllComments(repo).FlatMap(func(local interface{}) result.Result {
+			resolve := func(commit string) (string, bool) {
+				hash := gg.ResolveSingleCommitHash(repo, commit)
+				if hash.Failure != nil {
+					return "", false
+				}
+				return *(hash.Success.(*string)), true
+			}
+			for _, comment := range linkGerritComments(local.(CommentSlice), comments, resolve) {
+				if err := writeCommentToDisk(repo, comment).Failure; err != nil {
+					return result.NewFailure(err)
+				}
+			}
+			return result.NewSuccess(comments)
+		})
+	})
+}
+
+// Set the Gerrit identifiers of local comments which were published
+// to Gerrit. Identifiers which are already recorded by a comment are
+// not linked again, and each identifier is linked to one comment.
+// @return the comments which were linked
+func linkGerritComments(local CommentSlice, comments []*ImportedComment, resolve func(string) (string, bool)) CommentSlice {
+	linkedIDs := make(map[string]bool)
+	for _, comment := range local {
+		if id, ok := comment.ExternalID(GerritService); ok {
+			linkedIDs[id] = true
+		}
+	}
+	linked := make(CommentSlice, 0)
+	for _, imported := range comments {
+		if linkedIDs[imported.ID] || imported.Comment == nil || imported.Comment.Commit == nil {
+			continue
+		}
+		hash, ok := resolve(*imported.Comment.Commit)
+		if !ok {
+			continue
+		}
+		comment := exportedGerritComment(local, imported, hash)
+		if comment == nil {
+			continue
+		}
+		comment.SetExternalID(GerritService, imported.ID)
+		linkedIDs[imported.ID] = true
+		linked = append(linked, comment)
+	}
+	return linked
+}
+
+// Find the local comment which a Gerrit comment was published from,
+// being a comment without a Gerrit identifier on the same commit, file
+// and line, with the same content
+func exportedGerritComment(local CommentSlice, imported *ImportedComment, commit string) *Comment {
+	path, input := newGerritCommentInput(imported.Comment)
+	for _, comment := range local {
+		if _, ok := comment.ExternalID(GerritService); ok || comment.Deleted || *comment.Commit != commit {
+			continue
+		}
+		localPath, localInput := newGerritCommentInput(comment)
+		if localPath == path && localInput.Line == input.Line && localInput.Side == input.Side &&
+			strings.TrimSpace(comment.Content) == strings.TrimSpace(input.Message) {
+			return comment
+		}
+	}
+	return nil
+}
+
+// Parse comments from Gerrit REST API JSON, which maps file paths to
+// lists of comments. Comments on the commit message or the patch set
+// as a whole are converted into comments without a file reference.
+// Comments which are not valid, such as comments without a message,
+// have no comment.
+// @return result.Result<[]*ImportedComment, error>
+func ParseGerritComments(content io.Reader, commit string) result.Result {
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return result.NewFailure(err)
+	}
+	data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte(gerritJSONPrefix))
+	files := make(map[string]gerritCommentSlice)
+	if err := json.Unmarshal(data, &files); err != nil {
+		return result.NewFailure(err)
+	}
+	comments := make(gerritCommentSlice, 0)
+	for path, list := range files {
+		for _, comment := range list {
+			comment.path = path
+			comments = append(comments, comment)
+		}
+	}
+	sort.Stable(comments)
+	imported := make([]*ImportedComment, 0, len(comments))
+	for _, comment := range comments {
+		imported = append(imported, comment.importedComment(commit))
+	}
+	return result.NewSuccess(OrderReplies(imported))
+}
+
+// Create a Gerrit review containing the comments on a commit which
+// have not been published to or imported from Gerrit
+// @return result.Result<*GerritReviewInput, error>
+func ExportGerritReview(repoPath, revision string) result.Result {
+	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
+		return gg.ResolveSingleCommitHash(repo, revision).FlatMap(func(hash interface{}) result.Result {
+			return gg.LookupCommit(repo, *(hash.(*string)))
+		}).FlatMap(func(commit interface{}) result.Result {
+			return commentsOnCommit(repo, commit.(*git.Commit))
+		}).FlatMap(func(values interface{}) result.Result {
+			comments := make(CommentSlice, 0)
+			for _, comment := range values.([]interface{}) {
+				comments = append(comments, comment.(*Comment))
+			}
+			return result.NewSuccess(gerritReview(comments))
+		})
+	})
+}
+
+func gerritReview(comments CommentSlice) *GerritReviewInput {
+	sort.Stable(comments)
+	parents := comments.ByIdentity()
+	review := &GerritReviewInput{make(map[string][]*GerritCommentInput)}
+	for _, comment := range comments {
+		if _, ok := comment.ExternalID(GerritService); ok || comment.Deleted {
+			continue
+		}
+		path, input := newGerritCommentInput(comment)
+		if comment.Parent != nil {
+			if parent, ok := parents[*comment.Parent]; ok {
+				input.InReplyTo, _ = parent.ExternalID(GerritService)
+			}
+		}
+		review.Comments[path] = append(review.Comments[path], input)
+	}
+	return review
+}
+
+func newGerritCommentInput(comment *Comment) (string, *GerritCommentInput) {
+	ref := comment.FileRef
+	input := &GerritCommentInput{Message: comment.Content}
+	if ref == nil || len(ref.Path) == 0 {
+		return GerritPatchSetLevel, input
+	}
+	input.Line = ref.Line
+	if ref.LineType == RefLineTypeOld {
+		input.Side = gerritSideParent
+	}
+	return ref.Path, input
+}
+
+func (c *gerritComment) importedComment(commit string) *ImportedComment {
+	if len(c.CommitID) > 0 {
+		commit = c.CommitID
+	}
+	updated, err := time.Parse(gerritTimeFormat, c.Updated)
+	if err != nil {
+		updated = time.Now()
+	}
+	imported := &ImportedComment{nil, GerritService, c.ID, c.InReplyTo}
+	if value, err := NewComment(c.Message, commit, c.fileRef(), gerritPerson(c.Author, updated)).Dematerialize(); err == nil {
+		imported.Comment = value.(*Comment)
+	}
+	return imported
+}
+
+func (c *gerritComment) fileRef() *FileRef {
+	if c.path == GerritPatchSetLevel || c.path == gerritCommitMessage {
+		return &FileRef{}
+	}
+	line := c.Line
+	if line == 0 && c.Range != nil {
+		line = c.Range.StartLine
+	}
+	lineType := RefLineTypeNew
+	if c.Side == gerritSideParent {
+		lineType = RefLineTypeOld
+	}
+	return &FileRef{c.path, line, lineType}
+}
+
+func gerritPerson(account gerritAccount, date time.Time) *Person {
+	name, email := account.Name, account.Email
+	if len(name) == 0 {
+		name = account.Username
+	}
+	if len(email) == 0 {
+		email = fmt.Sprintf(gerritEmailFormat, account.AccountID)
+	}
+	date = date.Truncate(time.Second)
+	return &Person{name, email, date, date.Format("-0700")}
+}
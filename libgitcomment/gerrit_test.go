@@ -0,0 +1,182 @@
+package libgitcomment
+
+import (
+	"github.com/stvp/assert"
+	"os"
+	"strings"
+	"testing"
+)
+
+const gerritCommentsJSON = `)]}'
+{
+  "src/main/RefControl.java": [
+    {
+      "id": "TveXwFiA",
+      "in_reply_to": "TvcXrmjM",
+      "line": 23,
+      "message": "Done",
+      "updated": "2013-02-26 15:20:00.000000000",
+      "author": {"_account_id": 1000097, "name": "Jane Roe", "email": "jane.roe@example.com"}
+    },
+    {
+      "id": "TvcXrmjM",
+      "line": 23,
+      "message": "[nit] trailing whitespace",
+      "updated": "2013-02-26 15:40:43.986000000",
+      "author": {"_account_id": 1000096, "name": "John Doe", "email": "john.doe@example.com"}
+    },
+    {
+      "id": "TfYX-Iuo",
+      "range": {"start_line": 48, "start_character": 0, "end_line": 49, "end_character": 10},
+      "side": "PARENT",
+      "message": "Why was this removed?",
+      "updated": "2013-02-26 15:10:00.000000000",
+      "commit_id": "0155eb4229851634a0f03eb265b69f5a2d56f341",
+      "author": {"_account_id": 1000096, "name": "John Doe"}
+    }
+  ],
+  "/PATCHSET_LEVEL": [
+    {
+      "id": "Ab1Xrqz2",
+      "message": "Looks good overall",
+      "updated": "2013-02-26 16:00:00.000000000",
+      "author": {"_account_id": 1000096, "name": "John Doe", "email": "john.doe@example.com"}
+    }
+  ]
+}`
+
+func parsedGerritComments(t *testing.T) []*ImportedComment {
+	c, err := ParseGerritComments(strings.NewReader(gerritCommentsJSON), "abcdef1234").Dematerialize()
+	assert.Nil(t, err)
+	return c.([]*ImportedComment)
+}
+
+func TestParseGerritCommentsOrder(t *testing.T) {
+	comments := parsedGerritComments(t)
+	assert.Equal(t, len(comments), 4)
+	assert.Equal(t, comments[0].ID, "TfYX-Iuo")
+	assert.Equal(t, comments[1].ID, "TvcXrmjM")
+	assert.Equal(t, comments[2].ID, "TveXwFiA")
+	assert.Equal(t, comments[2].InReplyTo, "TvcXrmjM")
+	assert.Equal(t, comments[3].ID, "Ab1Xrqz2")
+}
+
+const gerritEmptyCommentJSON = `{
+  "/PATCHSET_LEVEL": [
+    {"id": "Cd3Xrqz4", "message": "", "updated": "2013-02-26 16:30:00.000000000", "author": {"_account_id": 1000096}}
+  ]
+}`
+
+func TestParseGerritCommentsWithoutMessage(t *testing.T) {
+	c, err := ParseGerritComments(strings.NewReader(gerritEmptyCommentJSON), "abcdef1234").Dematerialize()
+	assert.Nil(t, err)
+	comments := c.([]*ImportedComment)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].ID, "Cd3Xrqz4")
+	assert.Nil(t, comments[0].Comment)
+}
+
+func TestImportGerritCommentsCountsInvalidComments(t *testing.T) {
+	dir, _ := notesRepository(t)
+	defer os.RemoveAll(dir)
+	summary, err := ImportGerritComments(dir, strings.NewReader(gerritEmptyCommentJSON), "abcdef1234").Dematerialize()
+	assert.Nil(t, err)
+	assert.Equal(t, len(summary.(*ImportSummary).Invalid), 1)
+	assert.Equal(t, len(summary.(*ImportSummary).Added), 0)
+}
+
+func TestParseGerritCommentsFileRef(t *testing.T) {
+	comments := parsedGerritComments(t)
+	assert.Equal(t, *comments[0].Comment.FileRef, FileRef{"src/main/RefControl.java", 48, RefLineTypeOld})
+	assert.Equal(t, *comments[1].Comment.FileRef, FileRef{"src/main/RefControl.java", 23, RefLineTypeNew})
+	assert.Equal(t, *comments[3].Comment.FileRef, FileRef{})
+}
+
+func TestParseGerritCommentsCommit(t *testing.T) {
+	comments := parsedGerritComments(t)
+	assert.Equal(t, *comments[0].Comment.Commit, "0155eb4229851634a0f03eb265b69f5a2d56f341")
+	assert.Equal(t, *comments[1].Comment.Commit, "abcdef1234")
+}
+
+func TestParseGerritCommentsAuthor(t *testing.T) {
+	comments := parsedGerritComments(t)
+	assert.Equal(t, comments[1].Comment.Author.Name, "John Doe")
+	assert.Equal(t, comments[1].Comment.Author.Email, "john.doe@example.com")
+	assert.Equal(t, comments[1].Comment.Author.Date.Unix(), int64(1361893243))
+	assert.Equal(t, comments[0].Comment.Author.Email, "1000096@users.noreply.gerrit")
+}
+
+func TestGerritCommentInput(t *testing.T) {
+	c, _ := NewComment("Extract a method", "abc", &FileRef{"a.c", 7, RefLineTypeOld}, nil).Dematerialize()
+	path, input := newGerritCommentInput(c.(*Comment))
+	assert.Equal(t, path, "a.c")
+	assert.Equal(t, *input, GerritCommentInput{7, "PARENT", "Extract a method", ""})
+}
+
+func TestGerritCommentInputPatchSetLevel(t *testing.T) {
+	c, _ := NewComment("Ship it", "abc", new(FileRef), nil).Dematerialize()
+	path, input := newGerritCommentInput(c.(*Comment))
+	assert.Equal(t, path, GerritPatchSetLevel)
+	assert.Equal(t, *input, GerritCommentInput{Message: "Ship it"})
+}
+
+func TestGerritReviewRepliesToAmendedParent(t *testing.T) {
+	parent := syncedComment("b2", 1437498360)
+	parent.SetExternalID(GerritService, "af3b2c1d_0e5f6a7b")
+	reply := separateComment("c3")
+	reply.Parent = &syncedOrigin
+	review := gerritReview(CommentSlice{parent, reply})
+	inputs := review.Comments[GerritPatchSetLevel]
+	assert.Equal(t, len(inputs), 1)
+	assert.Equal(t, inputs[0].InReplyTo, "af3b2c1d_0e5f6a7b")
+}
+
+func TestExportedGerritComment(t *testing.T) {
+	exported := separateComment("b1")
+	imported := &ImportedComment{separateComment("b2"), GerritService, "af3b2c1d_0e5f6a7b", ""}
+	imported.Comment.Content = exported.Content + "\n"
+	assert.Equal(t, exportedGerritComment(CommentSlice{exported}, imported, syncedCommit), exported)
+	assert.Nil(t, exportedGerritComment(CommentSlice{exported}, imported, otherSyncedCommit))
+}
+
+func TestExportedGerritCommentSkipsPublishedComments(t *testing.T) {
+	published := separateComment("b1")
+	published.SetExternalID(GerritService, "0e5f6a7b_af3b2c1d")
+	imported := &ImportedComment{separateComment("b2"), GerritService, "af3b2c1d_0e5f6a7b", ""}
+	imported.Comment.Content = published.Content
+	assert.Nil(t, exportedGerritComment(CommentSlice{published}, imported, syncedCommit))
+}
+
+func resolveSyncedCommit(commit string) (string, bool) {
+	return commit, hasSyncedCommit(commit)
+}
+
+func TestLinkGerritComments(t *testing.T) {
+	first, second := separateComment("b1"), separateComment("b3")
+	second.Content = first.Content
+	imported := &ImportedComment{separateComment("b2"), GerritService, "af3b2c1d_0e5f6a7b", ""}
+	imported.Comment.Content = first.Content
+	linked := linkGerritComments(CommentSlice{first, second}, []*ImportedComment{imported, imported}, resolveSyncedCommit)
+	assert.Equal(t, linked, CommentSlice{first})
+	id, _ := first.ExternalID(GerritService)
+	assert.Equal(t, id, "af3b2c1d_0e5f6a7b")
+	_, ok := second.ExternalID(GerritService)
+	assert.False(t, ok)
+}
+
+func TestLinkGerritCommentsSkipsInvalidComments(t *testing.T) {
+	local := separateComment("b1")
+	invalid := &ImportedComment{nil, GerritService, "af3b2c1d_0e5f6a7b", ""}
+	assert.Equal(t, len(linkGerritComments(CommentSlice{local}, []*ImportedComment{invalid}, resolveSyncedCommit)), 0)
+}
+
+func TestLinkGerritCommentsSkipsLinkedIDs(t *testing.T) {
+	published, identical := separateComment("b1"), separateComment("b3")
+	published.SetExternalID(GerritService, "af3b2c1d_0e5f6a7b")
+	imported := &ImportedComment{separateComment("b2"), GerritService, "af3b2c1d_0e5f6a7b", ""}
+	imported.Comment.Content = identical.Content
+	linked := linkGerritComments(CommentSlice{published, identical}, []*ImportedComment{imported}, resolveSyncedCommit)
+	assert.Equal(t, len(linked), 0)
+	_, ok := identical.ExternalID(GerritService)
+	assert.False(t, ok)
+}
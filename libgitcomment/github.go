@@ -0,0 +1,271 @@
+package libgitcomment
+
+import (
+	"encoding/json"
+	"fmt"
+	gg "git"
+	"github.com/kylef/result.go/src/result"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	GitHubService     = "github"
+	githubEmailFormat = "%v@users.noreply.github.com"
+	githubSideLeft    = "LEFT"
+	githubSideRight   = "RIGHT"
+	githubSubjectFile = "file"
+)
+
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+// A pull request review comment as returned by the GitHub API
+type githubReviewComment struct {
+	ID               int64      `json:"id"`
+	InReplyToID      int64      `json:"in_reply_to_id"`
+	CommitID         string     `json:"commit_id"`
+	OriginalCommitID string     `json:"original_commit_id"`
+	Path             string     `json:"path"`
+	Line             int        `json:"line"`
+	OriginalLine     int        `json:"original_line"`
+	Side             string     `json:"side"`
+	Body             string     `json:"body"`
+	User             githubUser `json:"user"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+type githubCommentSlice []*githubReviewComment
+
+func (cs githubCommentSlice) Len() int {
+	return len(cs)
+}
+
+func (cs githubCommentSlice) Less(i, j int) bool {
+	if cs[i].CreatedAt.Equal(cs[j].CreatedAt) {
+		return cs[i].ID < cs[j].ID
+	}
+	return cs[i].CreatedAt.Before(cs[j].CreatedAt)
+}
+
+func (cs githubCommentSlice) Swap(i, j int) {
+	cs[i], cs[j] = cs[j], cs[i]
+}
+
+// Import pull request review comments from the JSON output of
+// `GET /repos/:owner/:repo/pulls/comments`
+// @return result.Result<*ImportSummary, error>
+func ImportGitHubComments(repoPath string, content io.Reader) result.Result {
+	return ParseGitHubComments(content).FlatMap(func(comments interface{}) result.Result {
+		return ImportComments(repoPath, comments.([]*ImportedComment))
+	})
+}
+
+// Parse pull request review comments from GitHub API JSON. The
+// content may contain several pages of results in sequence. Comments
+// are ordered by creation date so replies follow their parents.
+// Invalid comments, such as those without a body, are included without
+// a comment so they can be reported.
+// @return result.Result<[]*ImportedComment, error>
+func ParseGitHubComments(content io.Reader) result.Result {
+	decoder := json.NewDecoder(content)
+	comments := make(githubCommentSlice, 0)
+	for {
+		var page githubCommentSlice
+		if err := decoder.Decode(&page); err == io.EOF {
+			break
+		} else if err != nil {
+			return result.NewFailure(err)
+		}
+		comments = append(comments, page...)
+	}
+	return result.NewSuccess(importedGitHubComments(comments))
+}
+
+func importedGitHubComments(comments githubCommentSlice) []*ImportedComment {
+	sort.Stable(comments)
+	imported := make([]*ImportedComment, 0, len(comments))
+	for _, comment := range comments {
+		imported = append(imported, comment.importedComment())
+	}
+	return imported
+}
+
+func (c *githubReviewComment) importedComment() *ImportedComment {
+	commit, line := c.position()
+	author := githubPerson(c.User, c.CreatedAt)
+	var inReplyTo string
+	if c.InReplyToID > 0 {
+		inReplyTo = strconv.FormatInt(c.InReplyToID, 10)
+	}
+	imported := &ImportedComment{nil, GitHubService, strconv.FormatInt(c.ID, 10), inReplyTo}
+	value, err := NewComment(c.Body, commit, c.fileRef(line), author).Dematerialize()
+	if err != nil {
+		return imported
+	}
+	imported.Comment = value.(*Comment)
+	if c.UpdatedAt.After(c.CreatedAt) {
+		imported.Comment.Amender = githubPerson(c.User, c.UpdatedAt)
+	}
+	return imported
+}
+
+// The commit and line of a comment, taken together so the line is
+// within the commit: the line on the latest commit of the pull request,
+// or the line on the commit the comment was made on once it is outdated
+func (c *githubReviewComment) position() (string, int) {
+	if len(c.CommitID) > 0 && (c.Line > 0 || c.OriginalLine == 0) {
+		return c.CommitID, c.Line
+	} else if len(c.OriginalCommitID) > 0 {
+		return c.OriginalCommitID, c.OriginalLine
+	}
+	return c.CommitID, c.Line
+}
+
+func (c *githubReviewComment) fileRef(line int) *FileRef {
+	lineType := RefLineTypeNew
+	if c.Side == githubSideLeft {
+		lineType = RefLineTypeOld
+	}
+	return &FileRef{c.Path, line, lineType}
+}
+
+func githubPerson(user githubUser, date time.Time) *Person {
+	email := fmt.Sprintf(githubEmailFormat, user.Login)
+	return &Person{user.Login, email, date, date.Format("-0700")}
+}
+
+// Client for pull request review comments within the GitHub API,
+// or any service implementing the same REST interface
+type GitHubClient struct {
+	api         *restClient
+	repo        string
+	pullRequest int
+}
+
+type githubCommit struct {
+	SHA string `json:"sha"`
+}
+
+type githubNewComment struct {
+	Body        string `json:"body"`
+	CommitID    string `json:"commit_id,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Line        int    `json:"line,omitempty"`
+	Side        string `json:"side,omitempty"`
+	SubjectType string `json:"subject_type,omitempty"`
+}
+
+type githubCommentUpdate struct {
+	Body string `json:"body"`
+}
+
+// Create a client for the review comments of a repository in the format
+// `owner/name`. If a pull request number is not provided, comments from
+// all pull requests are fetched and no comments can be created.
+func NewGitHubClient(baseURL, token, repo string, pullRequest int) *GitHubClient {
+	header := make(http.Header)
+	if len(token) > 0 {
+		header.Set("Authorization", fmt.Sprintf("token %v", token))
+	}
+	return &GitHubClient{newRestClient(baseURL, header), repo, pullRequest}
+}
+
+func (g *GitHubClient) Name() string {
+	return GitHubService
+}
+
+// @return result.Result<[]*ImportedComment, error>
+func (g *GitHubClient) Comments() result.Result {
+	path := fmt.Sprintf("/repos/%v/pulls/comments", g.repo)
+	if g.pullRequest > 0 {
+		path = fmt.Sprintf("/repos/%v/pulls/%d/comments", g.repo, g.pullRequest)
+	}
+	pages := make([]*githubCommentSlice, 0)
+	err := g.api.requestPages(path, func() interface{} {
+		page := &githubCommentSlice{}
+		pages = append(pages, page)
+		return page
+	})
+	if err != nil {
+		return result.NewFailure(err)
+	}
+	comments := make(githubCommentSlice, 0)
+	for _, page := range pages {
+		comments = append(comments, *page...)
+	}
+	return result.NewSuccess(validImportedComments(importedGitHubComments(comments)))
+}
+
+// @return result.Result<[]string, error>
+func (g *GitHubClient) Commits() result.Result {
+	commits := make([]string, 0)
+	if g.pullRequest == 0 {
+		return result.NewSuccess(commits)
+	}
+	pages := make([]*[]githubCommit, 0)
+	path := fmt.Sprintf("/repos/%v/pulls/%d/commits", g.repo, g.pullRequest)
+	err := g.api.requestPages(path, func() interface{} {
+		page := &[]githubCommit{}
+		pages = append(pages, page)
+		return page
+	})
+	if err != nil {
+		return result.NewFailure(err)
+	}
+	for _, page := range pages {
+		for _, commit := range *page {
+			commits = append(commits, commit.SHA)
+		}
+	}
+	return result.NewSuccess(commits)
+}
+
+// Review comments must refer to a file
+func (g *GitHubClient) Supports(comment *Comment) bool {
+	return comment.FileRef != nil && len(comment.FileRef.Path) > 0
+}
+
+// @return result.Result<string, error>
+func (g *GitHubClient) CreateComment(comment *Comment, inReplyTo string) result.Result {
+	var body interface{}
+	path := fmt.Sprintf("/repos/%v/pulls/%d/comments", g.repo, g.pullRequest)
+	if len(inReplyTo) > 0 {
+		path = fmt.Sprintf("%v/%v/replies", path, inReplyTo)
+		body = &githubCommentUpdate{comment.Content}
+	} else {
+		body = newGitHubComment(comment)
+	}
+	created := &githubReviewComment{}
+	if _, err := g.api.request("POST", path, body, created); err != nil {
+		return result.NewFailure(err)
+	}
+	return result.NewSuccess(strconv.FormatInt(created.ID, 10))
+}
+
+// @return result.Result<bool, error>
+func (g *GitHubClient) UpdateComment(identifier string, comment *Comment) result.Result {
+	path := fmt.Sprintf("/repos/%v/pulls/comments/%v", g.repo, identifier)
+	_, err := g.api.request("PATCH", path, &githubCommentUpdate{comment.Content}, nil)
+	return gg.BoolResult(true, err)
+}
+
+func newGitHubComment(comment *Comment) *githubNewComment {
+	ref := comment.FileRef
+	created := &githubNewComment{Body: comment.Content, CommitID: *comment.Commit, Path: ref.Path}
+	if ref.Line > 0 {
+		created.Line = ref.Line
+		created.Side = githubSideRight
+		if ref.LineType == RefLineTypeOld {
+			created.Side = githubSideLeft
+		}
+	} else {
+		created.SubjectType = githubSubjectFile
+	}
+	return created
+}
@@ -0,0 +1,174 @@
+package libgitcomment
+
+import (
+	"fmt"
+	"github.com/stvp/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const githubCommentsJSON = `[
+  {
+    "id": 11,
+    "commit_id": "6dcb09b5b57875f334f61aebed695e2e4193db5e",
+    "original_commit_id": "9c48853fa3dc5c1c3d6f1f1cd1f2743e72652840",
+    "path": "file1.txt",
+    "line": 2,
+    "original_line": 3,
+    "side": "LEFT",
+    "body": "Is this still needed?",
+    "user": {"login": "octocat"},
+    "created_at": "2011-04-14T16:00:49Z",
+    "updated_at": "2011-04-14T17:00:49Z"
+  },
+  {
+    "id": 10,
+    "commit_id": "6dcb09b5b57875f334f61aebed695e2e4193db5e",
+    "path": "file1.txt",
+    "line": 7,
+    "side": "RIGHT",
+    "body": "Great stuff!",
+    "user": {"login": "hubot"},
+    "created_at": "2011-04-14T15:00:49Z",
+    "updated_at": "2011-04-14T15:00:49Z"
+  }
+]
+[
+  {
+    "id": 12,
+    "in_reply_to_id": 11,
+    "commit_id": "6dcb09b5b57875f334f61aebed695e2e4193db5e",
+    "original_commit_id": "9c48853fa3dc5c1c3d6f1f1cd1f2743e72652840",
+    "path": "file1.txt",
+    "original_line": 3,
+    "side": "LEFT",
+    "body": "Yes, for now.",
+    "user": {"login": "hubot"},
+    "created_at": "2011-04-14T18:00:49Z",
+    "updated_at": "2011-04-14T18:00:49Z"
+  }
+]`
+
+func parsedGitHubComments(t *testing.T) []*ImportedComment {
+	c, err := ParseGitHubComments(strings.NewReader(githubCommentsJSON)).Dematerialize()
+	assert.Nil(t, err)
+	return c.([]*ImportedComment)
+}
+
+func TestParseGitHubCommentsOrder(t *testing.T) {
+	comments := parsedGitHubComments(t)
+	assert.Equal(t, len(comments), 3)
+	assert.Equal(t, comments[0].ID, "10")
+	assert.Equal(t, comments[1].ID, "11")
+	assert.Equal(t, comments[2].ID, "12")
+}
+
+func TestParseGitHubCommentsReplies(t *testing.T) {
+	comments := parsedGitHubComments(t)
+	assert.Equal(t, comments[0].InReplyTo, "")
+	assert.Equal(t, comments[2].InReplyTo, "11")
+	assert.Equal(t, comments[2].Service, GitHubService)
+}
+
+func TestParseGitHubCommentsFileRef(t *testing.T) {
+	comments := parsedGitHubComments(t)
+	assert.Equal(t, *comments[0].Comment.FileRef, FileRef{"file1.txt", 7, RefLineTypeNew})
+	assert.Equal(t, *comments[1].Comment.FileRef, FileRef{"file1.txt", 2, RefLineTypeOld})
+}
+
+func TestParseGitHubCommentsOutdated(t *testing.T) {
+	comment := parsedGitHubComments(t)[2].Comment
+	assert.Equal(t, *comment.Commit, "9c48853fa3dc5c1c3d6f1f1cd1f2743e72652840")
+	assert.Equal(t, *comment.FileRef, FileRef{"file1.txt", 3, RefLineTypeOld})
+}
+
+func TestParseGitHubCommentsAuthor(t *testing.T) {
+	comment := parsedGitHubComments(t)[1].Comment
+	assert.Equal(t, comment.Author.Name, "octocat")
+	assert.Equal(t, comment.Author.Email, "octocat@users.noreply.github.com")
+	assert.Equal(t, comment.Author.Date.Unix(), int64(1302796849))
+	assert.Equal(t, comment.Amender.Date.Unix(), int64(1302800449))
+	assert.Equal(t, *comment.Commit, "6dcb09b5b57875f334f61aebed695e2e4193db5e")
+	assert.Equal(t, comment.Content, "Is this still needed?")
+}
+
+func TestParseGitHubCommentsInvalid(t *testing.T) {
+	_, err := ParseGitHubComments(strings.NewReader("[{")).Dematerialize()
+	assert.NotNil(t, err)
+}
+
+func TestParseGitHubCommentsWithoutBody(t *testing.T) {
+	content := `[{"id": 13, "commit_id": "abc1234", "path": "a.c", "line": 2, "body": "", "user": {"login": "hubot"}}]`
+	c, err := ParseGitHubComments(strings.NewReader(content)).Dematerialize()
+	assert.Nil(t, err)
+	comments := c.([]*ImportedComment)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].ID, "13")
+	assert.Nil(t, comments[0].Comment)
+}
+
+func githubTestServer(t *testing.T, received *[]string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/octo/repo/pulls/3/comments", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			body, _ := ioutil.ReadAll(r.Body)
+			*received = append(*received, string(body))
+			fmt.Fprint(w, `{"id": 42}`)
+			return
+		}
+		assert.Equal(t, r.Header.Get("Authorization"), "token s3cret")
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `[{"id": 12, "in_reply_to_id": 11, "commit_id": "abc1234", "path": "a.c", "line": 2, "body": "Agreed", "user": {"login": "hubot"}}]`)
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<http://%v%v?page=2>; rel="next"`, r.Host, r.URL.Path))
+		fmt.Fprint(w, `[{"id": 11, "commit_id": "abc1234", "path": "a.c", "line": 2, "body": "Why?", "user": {"login": "octocat"}}]`)
+	})
+	mux.HandleFunc("/repos/octo/repo/pulls/3/commits", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"sha": "abc1234"}, {"sha": "def5678"}]`)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestGitHubClientComments(t *testing.T) {
+	server := githubTestServer(t, nil)
+	defer server.Close()
+	client := NewGitHubClient(server.URL, "s3cret", "octo/repo", 3)
+	c, err := client.Comments().Dematerialize()
+	assert.Nil(t, err)
+	comments := c.([]*ImportedComment)
+	assert.Equal(t, len(comments), 2)
+	assert.Equal(t, comments[0].ID, "11")
+	assert.Equal(t, comments[1].InReplyTo, "11")
+}
+
+func TestGitHubClientCommits(t *testing.T) {
+	server := githubTestServer(t, nil)
+	defer server.Close()
+	client := NewGitHubClient(server.URL, "", "octo/repo", 3)
+	commits, err := client.Commits().Dematerialize()
+	assert.Nil(t, err)
+	assert.Equal(t, commits, []string{"abc1234", "def5678"})
+}
+
+func TestGitHubClientCreateComment(t *testing.T) {
+	received := make([]string, 0)
+	server := githubTestServer(t, &received)
+	defer server.Close()
+	client := NewGitHubClient(server.URL, "", "octo/repo", 3)
+	c, _ := NewComment("Missing error check", "abc1234", &FileRef{"a.c", 9, RefLineTypeOld}, nil).Dematerialize()
+	id, err := client.CreateComment(c.(*Comment), "").Dematerialize()
+	assert.Nil(t, err)
+	assert.Equal(t, id, "42")
+	assert.Equal(t, received[0], `{"body":"Missing error check","commit_id":"abc1234","path":"a.c","line":9,"side":"LEFT"}`)
+}
+
+func TestGitHubClientSupports(t *testing.T) {
+	client := NewGitHubClient("", "", "octo/repo", 3)
+	assert.True(t, client.Supports(&Comment{FileRef: &FileRef{"a.c", 0, RefLineTypeNew}}))
+	assert.False(t, client.Supports(&Comment{FileRef: new(FileRef)}))
+	assert.False(t, client.Supports(&Comment{}))
+}
@@ -0,0 +1,318 @@
+package libgitcomment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	gg "git"
+	"github.com/kylef/result.go/src/result"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	GitLabService      = "gitlab"
+	gitlabEmailFormat  = "%v@users.noreply.gitlab.com"
+	gitlabPositionText = "text"
+	noNoteCreatedError = "No note was created for the discussion"
+)
+
+type gitlabAuthor struct {
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+type gitlabPosition struct {
+	BaseSHA      string `json:"base_sha"`
+	StartSHA     string `json:"start_sha"`
+	HeadSHA      string `json:"head_sha"`
+	PositionType string `json:"position_type"`
+	OldPath      string `json:"old_path,omitempty"`
+	NewPath      string `json:"new_path,omitempty"`
+	OldLine      int    `json:"old_line,omitempty"`
+	NewLine      int    `json:"new_line,omitempty"`
+}
+
+// A merge request note as returned by the GitLab API
+type gitlabNote struct {
+	ID        int64           `json:"id"`
+	Body      string          `json:"body"`
+	Author    gitlabAuthor    `json:"author"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	System    bool            `json:"system"`
+	Position  *gitlabPosition `json:"position"`
+}
+
+// A merge request discussion as returned by the GitLab API
+type gitlabDiscussion struct {
+	ID    string        `json:"id"`
+	Notes []*gitlabNote `json:"notes"`
+}
+
+// Import diff notes from the JSON output of
+// `GET /projects/:id/merge_requests/:iid/discussions`
+// @return result.Result<*ImportSummary, error>
+func ImportGitLabComments(repoPath string, content io.Reader) result.Result {
+	return ParseGitLabComments(content).FlatMap(func(comments interface{}) result.Result {
+		return ImportComments(repoPath, comments.([]*ImportedComment))
+	})
+}
+
+// Parse merge request diff notes from GitLab API JSON. The content may
+// contain several pages of results in sequence. Notes which are not
+// attached to a diff position are ignored, and notes which are not valid
+// comments, such as notes without content, have no comment.
+// @return result.Result<[]*ImportedComment, error>
+func ParseGitLabComments(content io.Reader) result.Result {
+	decoder := json.NewDecoder(content)
+	discussions := make([]*gitlabDiscussion, 0)
+	for {
+		var page []*gitlabDiscussion
+		if err := decoder.Decode(&page); err == io.EOF {
+			break
+		} else if err != nil {
+			return result.NewFailure(err)
+		}
+		discussions = append(discussions, page...)
+	}
+	return result.NewSuccess(importedGitLabComments(discussions))
+}
+
+// Convert the notes of each discussion into comments, treating any
+// notes after the first as replies to it
+func importedGitLabComments(discussions []*gitlabDiscussion) []*ImportedComment {
+	imported := make([]*ImportedComment, 0)
+	for _, discussion := range discussions {
+		var first *gitlabNote
+		for _, note := range discussion.Notes {
+			if note.System {
+				continue
+			}
+			position := note.Position
+			var inReplyTo string
+			if first == nil {
+				first = note
+			} else {
+				inReplyTo = strconv.FormatInt(first.ID, 10)
+				if position == nil {
+					position = first.Position
+				}
+			}
+			if position == nil {
+				continue
+			}
+			imported = append(imported, note.importedComment(position, inReplyTo))
+		}
+	}
+	return imported
+}
+
+func (n *gitlabNote) importedComment(position *gitlabPosition, inReplyTo string) *ImportedComment {
+	author := gitlabPerson(n.Author, n.CreatedAt)
+	imported := &ImportedComment{nil, GitLabService, strconv.FormatInt(n.ID, 10), inReplyTo}
+	value, err := NewComment(n.Body, position.HeadSHA, position.fileRef(), author).Dematerialize()
+	if err != nil {
+		return imported
+	}
+	imported.Comment = value.(*Comment)
+	if n.UpdatedAt.After(n.CreatedAt) {
+		imported.Comment.Amender = gitlabPerson(n.Author, n.UpdatedAt)
+	}
+	return imported
+}
+
+func (p *gitlabPosition) fileRef() *FileRef {
+	if p.NewLine == 0 && p.OldLine > 0 {
+		return &FileRef{p.OldPath, p.OldLine, RefLineTypeOld}
+	}
+	path := p.NewPath
+	if len(path) == 0 {
+		path = p.OldPath
+	}
+	return &FileRef{path, p.NewLine, RefLineTypeNew}
+}
+
+func gitlabPerson(author gitlabAuthor, date time.Time) *Person {
+	name, email := author.Name, author.Email
+	if len(name) == 0 {
+		name = author.Username
+	}
+	if len(email) == 0 {
+		email = fmt.Sprintf(gitlabEmailFormat, author.Username)
+	}
+	date = date.Truncate(time.Second)
+	return &Person{name, email, date, date.Format("-0700")}
+}
+
+// Client for merge request discussions within the GitLab API
+type GitLabClient struct {
+	api          *restClient
+	project      string
+	mergeRequest int
+	discussions  map[string]string
+	parents      map[string]string
+}
+
+type gitlabCommit struct {
+	ID        string   `json:"id"`
+	ParentIDs []string `json:"parent_ids"`
+}
+
+type gitlabNewDiscussion struct {
+	Body     string          `json:"body"`
+	Position *gitlabPosition `json:"position"`
+}
+
+type gitlabNoteUpdate struct {
+	Body string `json:"body"`
+}
+
+// Create a client for the discussions of a merge request within a
+// project, identified by either its numeric ID or its full path
+func NewGitLabClient(baseURL, token, project string, mergeRequest int) *GitLabClient {
+	header := make(http.Header)
+	if len(token) > 0 {
+		header.Set("PRIVATE-TOKEN", token)
+	}
+	api := newRestClient(baseURL, header)
+	return &GitLabClient{api, project, mergeRequest, make(map[string]string), make(map[string]string)}
+}
+
+func (g *GitLabClient) Name() string {
+	return GitLabService
+}
+
+// @return result.Result<[]*ImportedComment, error>
+func (g *GitLabClient) Comments() result.Result {
+	pages := make([]*[]*gitlabDiscussion, 0)
+	err := g.api.requestPages(g.mergeRequestPath("/discussions"), func() interface{} {
+		page := &[]*gitlabDiscussion{}
+		pages = append(pages, page)
+		return page
+	})
+	if err != nil {
+		return result.NewFailure(err)
+	}
+	discussions := make([]*gitlabDiscussion, 0)
+	for _, page := range pages {
+		discussions = append(discussions, *page...)
+	}
+	for _, discussion := range discussions {
+		for _, note := range discussion.Notes {
+			g.discussions[strconv.FormatInt(note.ID, 10)] = discussion.ID
+		}
+	}
+	return result.NewSuccess(validImportedComments(importedGitLabComments(discussions)))
+}
+
+// @return result.Result<[]string, error>
+func (g *GitLabClient) Commits() result.Result {
+	pages := make([]*[]gitlabCommit, 0)
+	err := g.api.requestPages(g.mergeRequestPath("/commits"), func() interface{} {
+		page := &[]gitlabCommit{}
+		pages = append(pages, page)
+		return page
+	})
+	if err != nil {
+		return result.NewFailure(err)
+	}
+	commits := make([]string, 0)
+	for _, page := range pages {
+		for _, commit := range *page {
+			commits = append(commits, commit.ID)
+		}
+	}
+	return result.NewSuccess(commits)
+}
+
+// Diff notes must refer to a line of a file
+func (g *GitLabClient) Supports(comment *Comment) bool {
+	return comment.FileRef != nil && len(comment.FileRef.Path) > 0 && comment.FileRef.Line > 0
+}
+
+// Create a reply within an existing discussion, or a new discussion on
+// the diff of the commit the comment refers to
+// @return result.Result<string, error>
+func (g *GitLabClient) CreateComment(comment *Comment, inReplyTo string) result.Result {
+	if discussion, ok := g.discussions[inReplyTo]; ok && len(inReplyTo) > 0 {
+		note := &gitlabNote{}
+		path := g.mergeRequestPath(fmt.Sprintf("/discussions/%v/notes", discussion))
+		if _, err := g.api.request("POST", path, &gitlabNoteUpdate{comment.Content}, note); err != nil {
+			return result.NewFailure(err)
+		}
+		id := strconv.FormatInt(note.ID, 10)
+		g.discussions[id] = discussion
+		return result.NewSuccess(id)
+	}
+	return g.commitParent(*comment.Commit).FlatMap(func(parent interface{}) result.Result {
+		body := &gitlabNewDiscussion{comment.Content, newGitLabPosition(comment, parent.(string))}
+		discussion := &gitlabDiscussion{}
+		if _, err := g.api.request("POST", g.mergeRequestPath("/discussions"), body, discussion); err != nil {
+			return result.NewFailure(err)
+		}
+		if len(discussion.Notes) == 0 {
+			return result.NewFailure(errors.New(noNoteCreatedError))
+		}
+		id := strconv.FormatInt(discussion.Notes[0].ID, 10)
+		g.discussions[id] = discussion.ID
+		return result.NewSuccess(id)
+	})
+}
+
+// @return result.Result<bool, error>
+func (g *GitLabClient) UpdateComment(identifier string, comment *Comment) result.Result {
+	path := g.mergeRequestPath(fmt.Sprintf("/notes/%v", identifier))
+	_, err := g.api.request("PUT", path, &gitlabNoteUpdate{comment.Content}, nil)
+	return gg.BoolResult(true, err)
+}
+
+// Find the first parent of a commit, which is the base of the
+// single-commit diff a comment refers to
+// @return result.Result<string, error>
+func (g *GitLabClient) commitParent(commit string) result.Result {
+	if parent, ok := g.parents[commit]; ok {
+		return result.NewSuccess(parent)
+	}
+	info := &gitlabCommit{}
+	path := fmt.Sprintf("%v/repository/commits/%v", g.projectPath(), commit)
+	if _, err := g.api.request("GET", path, nil, info); err != nil {
+		return result.NewFailure(err)
+	}
+	var parent string
+	if len(info.ParentIDs) > 0 {
+		parent = info.ParentIDs[0]
+	}
+	g.parents[commit] = parent
+	return result.NewSuccess(parent)
+}
+
+func (g *GitLabClient) projectPath() string {
+	return fmt.Sprintf("/projects/%v", url.QueryEscape(g.project))
+}
+
+func (g *GitLabClient) mergeRequestPath(suffix string) string {
+	return fmt.Sprintf("%v/merge_requests/%d%v", g.projectPath(), g.mergeRequest, suffix)
+}
+
+func newGitLabPosition(comment *Comment, parent string) *gitlabPosition {
+	ref := comment.FileRef
+	position := &gitlabPosition{
+		BaseSHA:      parent,
+		StartSHA:     parent,
+		HeadSHA:      *comment.Commit,
+		PositionType: gitlabPositionText,
+		OldPath:      ref.Path,
+		NewPath:      ref.Path,
+	}
+	if ref.LineType == RefLineTypeOld {
+		position.OldLine = ref.Line
+	} else {
+		position.NewLine = ref.Line
+	}
+	return position
+}
@@ -0,0 +1,195 @@
+package libgitcomment
+
+import (
+	"fmt"
+	"github.com/stvp/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+const gitlabDiscussionsJSON = `[
+  {
+    "id": "6a9c1750b37d513a43987b574953fceb50b03ce7",
+    "notes": [
+      {
+        "id": 1126,
+        "type": "DiffNote",
+        "body": "Should this be configurable?",
+        "author": {"name": "Pat Doe", "username": "pat"},
+        "created_at": "2018-03-03T21:54:39.668Z",
+        "updated_at": "2018-03-04T08:00:00.000Z",
+        "system": false,
+        "position": {
+          "base_sha": "b5d6e7b1613fca24d250fa8e5bc7bcc3dd6002ef",
+          "start_sha": "b5d6e7b1613fca24d250fa8e5bc7bcc3dd6002ef",
+          "head_sha": "2be7ddb704c7b6b83732fdd5b9f09d5a397b5f8f",
+          "old_path": "package.json",
+          "new_path": "package.json",
+          "position_type": "text",
+          "new_line": 27
+        }
+      },
+      {
+        "id": 1127,
+        "type": "DiffNote",
+        "body": "Yes, eventually.",
+        "author": {"name": "Sam Roe", "username": "sam", "email": "sam@example.com"},
+        "created_at": "2018-03-04T09:00:00.000Z",
+        "updated_at": "2018-03-04T09:00:00.000Z",
+        "system": false
+      }
+    ]
+  },
+  {
+    "id": "87805b7c09016a7058e91bdbe7b29d1f284a39e6",
+    "notes": [
+      {
+        "id": 1128,
+        "body": "added 1 commit",
+        "author": {"name": "Pat Doe", "username": "pat"},
+        "created_at": "2018-03-05T09:00:00.000Z",
+        "updated_at": "2018-03-05T09:00:00.000Z",
+        "system": true
+      }
+    ]
+  },
+  {
+    "id": "3f1ac2e4e9a7c1d6b0b7ff3b2bb4b5c7a5d3e0f1",
+    "notes": [
+      {
+        "id": 1129,
+        "type": "DiffNote",
+        "body": "Removed too early?",
+        "author": {"name": "Pat Doe", "username": "pat"},
+        "created_at": "2018-03-06T09:00:00.000Z",
+        "updated_at": "2018-03-06T09:00:00.000Z",
+        "system": false,
+        "position": {
+          "base_sha": "b5d6e7b1613fca24d250fa8e5bc7bcc3dd6002ef",
+          "start_sha": "b5d6e7b1613fca24d250fa8e5bc7bcc3dd6002ef",
+          "head_sha": "2be7ddb704c7b6b83732fdd5b9f09d5a397b5f8f",
+          "old_path": "src/old.c",
+          "new_path": "src/old.c",
+          "position_type": "text",
+          "old_line": 12
+        }
+      }
+    ]
+  }
+]`
+
+func parsedGitLabComments(t *testing.T) []*ImportedComment {
+	c, err := ParseGitLabComments(strings.NewReader(gitlabDiscussionsJSON)).Dematerialize()
+	assert.Nil(t, err)
+	return c.([]*ImportedComment)
+}
+
+func TestParseGitLabCommentsSkipsSystemNotes(t *testing.T) {
+	comments := parsedGitLabComments(t)
+	assert.Equal(t, len(comments), 3)
+	assert.Equal(t, comments[0].ID, "1126")
+	assert.Equal(t, comments[1].ID, "1127")
+	assert.Equal(t, comments[2].ID, "1129")
+}
+
+func TestParseGitLabCommentsReplies(t *testing.T) {
+	comments := parsedGitLabComments(t)
+	assert.Equal(t, comments[0].InReplyTo, "")
+	assert.Equal(t, comments[1].InReplyTo, "1126")
+	assert.Equal(t, *comments[1].Comment.FileRef, FileRef{"package.json", 27, RefLineTypeNew})
+}
+
+func TestParseGitLabCommentsPosition(t *testing.T) {
+	comments := parsedGitLabComments(t)
+	assert.Equal(t, *comments[0].Comment.Commit, "2be7ddb704c7b6b83732fdd5b9f09d5a397b5f8f")
+	assert.Equal(t, *comments[0].Comment.FileRef, FileRef{"package.json", 27, RefLineTypeNew})
+	assert.Equal(t, *comments[2].Comment.FileRef, FileRef{"src/old.c", 12, RefLineTypeOld})
+}
+
+func TestParseGitLabCommentsAuthor(t *testing.T) {
+	comments := parsedGitLabComments(t)
+	assert.Equal(t, comments[0].Comment.Author.Name, "Pat Doe")
+	assert.Equal(t, comments[0].Comment.Author.Email, "pat@users.noreply.gitlab.com")
+	assert.Equal(t, comments[0].Comment.Author.Date.Unix(), int64(1520114079))
+	assert.Equal(t, comments[0].Comment.Amender.Date.Unix(), int64(1520150400))
+	assert.Equal(t, comments[1].Comment.Author.Email, "sam@example.com")
+}
+
+const gitlabEmptyNoteJSON = `[
+  {
+    "id": "6a9c1750b37d513a43987b574953fceb50b03ce7",
+    "notes": [
+      {
+        "id": 1130,
+        "body": "",
+        "author": {"name": "Pat Doe", "username": "pat"},
+        "system": false,
+        "position": {"head_sha": "2be7ddb704c7b6b83732fdd5b9f09d5a397b5f8f", "new_path": "a.c", "new_line": 2}
+      }
+    ]
+  }
+]`
+
+func TestParseGitLabCommentsWithoutBody(t *testing.T) {
+	c, err := ParseGitLabComments(strings.NewReader(gitlabEmptyNoteJSON)).Dematerialize()
+	assert.Nil(t, err)
+	comments := c.([]*ImportedComment)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].ID, "1130")
+	assert.Nil(t, comments[0].Comment)
+}
+
+func TestImportGitLabCommentsCountsInvalidNotes(t *testing.T) {
+	dir, _ := notesRepository(t)
+	defer os.RemoveAll(dir)
+	content := io.MultiReader(strings.NewReader(gitlabDiscussionsJSON), strings.NewReader(gitlabEmptyNoteJSON))
+	summary, err := ImportGitLabComments(dir, content).Dematerialize()
+	assert.Nil(t, err)
+	assert.Equal(t, len(summary.(*ImportSummary).Invalid), 1)
+	assert.Equal(t, summary.(*ImportSummary).Invalid[0].ID, "1130")
+	assert.Equal(t, len(summary.(*ImportSummary).Orphaned), 3)
+}
+
+func TestGitLabClientCreateComment(t *testing.T) {
+	received := make(map[string]string)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/projects/7/repository/commits/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": "2be7ddb7", "parent_ids": ["b5d6e7b1"]}`)
+	})
+	mux.HandleFunc("/api/v4/projects/7/merge_requests/4/discussions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			fmt.Fprint(w, gitlabDiscussionsJSON)
+			return
+		}
+		assert.Equal(t, r.Header.Get("PRIVATE-TOKEN"), "s3cret")
+		body, _ := ioutil.ReadAll(r.Body)
+		received[r.URL.Path] = string(body)
+		fmt.Fprint(w, `{"id": "abc", "notes": [{"id": 2001}]}`)
+	})
+	mux.HandleFunc("/api/v4/projects/7/merge_requests/4/discussions/6a9c1750b37d513a43987b574953fceb50b03ce7/notes", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received[r.URL.Path] = string(body)
+		fmt.Fprint(w, `{"id": 2002}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := NewGitLabClient(server.URL+"/api/v4", "s3cret", "7", 4)
+	_, err := client.Comments().Dematerialize()
+	assert.Nil(t, err)
+
+	c, _ := NewComment("Use a constant", "2be7ddb7", &FileRef{"main.c", 4, RefLineTypeNew}, nil).Dematerialize()
+	id, err := client.CreateComment(c.(*Comment), "").Dematerialize()
+	assert.Nil(t, err)
+	assert.Equal(t, id, "2001")
+	assert.Equal(t, received["/api/v4/projects/7/merge_requests/4/discussions"],
+		`{"body":"Use a constant","position":{"base_sha":"b5d6e7b1","start_sha":"b5d6e7b1","head_sha":"2be7ddb7","position_type":"text","old_path":"main.c","new_path":"main.c","new_line":4}}`)
+
+	id, err = client.CreateComment(c.(*Comment), "1127").Dematerialize()
+	assert.Nil(t, err)
+	assert.Equal(t, id, "2002")
+}
@@ -0,0 +1,136 @@
+package libgitcomment
+
+import (
+	"fmt"
+	gg "git"
+	"github.com/kylef/result.go/src/result"
+	git "gopkg.in/libgit2/git2go.v23"
+)
+
+// A comment authored within an external service, prepared for
+// import into the repository. Comment is nil if the external comment
+// is not a valid comment, such as one without content.
+type ImportedComment struct {
+	Comment   *Comment
+	Service   string
+	ID        string
+	InReplyTo string
+}
+
+// Outcome of importing a set of comments
+type ImportSummary struct {
+	Added    []*Comment
+	Skipped  []*ImportedComment
+	Orphaned []*ImportedComment
+	Invalid  []*ImportedComment
+}
+
+// Write comments from an external service to disk. Comments which
+// were imported previously are skipped, as are invalid comments and
+// comments on commits which are not present in the repository. Replies are linked to
+// their parent comment if the parent has been imported.
+// @return result.Result<*ImportSummary, error>
+func ImportComments(repoPath string, comments []*ImportedComment) result.Result {
+	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
+		return ExternalCommentIDs(repo).FlatMap(func(value interface{}) result.Result {
+			known := value.(map[string]string)
+			summary := &ImportSummary{}
+			for _, imported := range comments {
+				if imported.Comment == nil {
+					summary.Invalid = append(summary.Invalid, imported)
+					continue
+				}
+				key := externalIDKey(imported.Service, imported.ID)
+				if _, ok := known[key]; ok {
+					summary.Skipped = append(summary.Skipped, imported)
+					continue
+				}
+				comment := imported.Comment
+				if comment.Commit == nil || gg.ResolveSingleCommitHash(repo, *comment.Commit).Failure != nil {
+					summary.Orphaned = append(summary.Orphaned, imported)
+					continue
+				}
+				if err := importComment(repo, imported, known).Failure; err != nil {
+					return result.NewFailure(err)
+				}
+				known[key] = comment.Identity()
+				summary.Added = append(summary.Added, comment)
+			}
+			return result.NewSuccess(summary)
+		})
+	})
+}
+
+// Map of external identifiers in the format `service:identifier`
+// to the identities of the comments in the repository
+// @return result.Result<map[string]string, error>
+func ExternalCommentIDs(repo *git.Repository) result.Result {
+	ids := make(map[string]string)
+	return gg.CommentRefIterator(repo, func(ref *git.Reference) {
+		CommentFromRef(repo, ref.Name()).FlatMap(func(c interface{}) result.Result {
+			comment := c.(*Comment)
+			for service, id := range comment.ExternalIDs {
+				ids[externalIDKey(service, id)] = comment.Identity()
+			}
+			return result.Result{}
+		})
+	}).FlatMap(func(value interface{}) result.Result {
+		return result.NewSuccess(ids)
+	})
+}
+
+// @return result.Result<*Comment, error>
+func importComment(repo *git.Repository, imported *ImportedComment, known map[string]string) result.Result {
+	comment := imported.Comment
+	return validatedCommitForComment(repo, *comment.Commit).FlatMap(func(hash interface{}) result.Result {
+		comment.Commit = hash.(*string)
+		comment.SetExternalID(imported.Service, imported.ID)
+		if len(imported.InReplyTo) > 0 {
+			if parent, ok := known[externalIDKey(imported.Service, imported.InReplyTo)]; ok {
+				comment.Parent = &parent
+			}
+		}
+		return writeCommentToDisk(repo, comment)
+	})
+}
+
+// Remove invalid comments, which have no comment to import
+func validImportedComments(comments []*ImportedComment) []*ImportedComment {
+	valid := make([]*ImportedComment, 0, len(comments))
+	for _, comment := range comments {
+		if comment.Comment != nil {
+			valid = append(valid, comment)
+		}
+	}
+	return valid
+}
+
+func externalIDKey(service, identifier string) string {
+	return fmt.Sprintf("%v%v%v", service, externalIDSeparator, identifier)
+}
+
+// Order comments so that replies follow the comments they reply to,
+// otherwise preserving the original order
+func OrderReplies(comments []*ImportedComment) []*ImportedComment {
+	byID := make(map[string]*ImportedComment)
+	for _, comment := range comments {
+		byID[comment.ID] = comment
+	}
+	ordered := make([]*ImportedComment, 0, len(comments))
+	added := make(map[string]bool)
+	var add func(comment *ImportedComment)
+	add = func(comment *ImportedComment) {
+		if added[comment.ID] {
+			return
+		}
+		added[comment.ID] = true
+		if parent, ok := byID[comment.InReplyTo]; ok {
+			add(parent)
+		}
+		ordered = append(ordered, comment)
+	}
+	for _, comment := range comments {
+		add(comment)
+	}
+	return ordered
+}
@@ -0,0 +1,27 @@
+package libgitcomment
+
+import (
+	"github.com/stvp/assert"
+	"testing"
+)
+
+func TestOrderReplies(t *testing.T) {
+	comments := []*ImportedComment{
+		&ImportedComment{ID: "3", InReplyTo: "2"},
+		&ImportedComment{ID: "1"},
+		&ImportedComment{ID: "2", InReplyTo: "1"},
+		&ImportedComment{ID: "4", InReplyTo: "9"},
+	}
+	ordered := OrderReplies(comments)
+	assert.Equal(t, len(ordered), 4)
+	assert.Equal(t, ordered[0].ID, "1")
+	assert.Equal(t, ordered[1].ID, "2")
+	assert.Equal(t, ordered[2].ID, "3")
+	assert.Equal(t, ordered[3].ID, "4")
+}
+
+func TestValidImportedComments(t *testing.T) {
+	valid := &ImportedComment{Comment: &Comment{Content: "Why?"}, ID: "1"}
+	comments := []*ImportedComment{valid, &ImportedComment{ID: "2"}}
+	assert.Equal(t, validImportedComments(comments), []*ImportedComment{valid})
+}
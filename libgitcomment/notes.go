@@ -0,0 +1,182 @@
+package libgitcomment
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	gg "git"
+	"github.com/kylef/result.go/src/result"
+	git "gopkg.in/libgit2/git2go.v23"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	NotesService    = "notes"
+	DefaultNotesRef = "refs/notes/commits"
+	notesRefPrefix  = "refs/notes/"
+	noteDateFormat  = "Mon Jan 2 15:04:05 2006 -0700"
+	noteIndent      = "    "
+
+	foreignNoteError = "The note on commit %v in %v was not written by git-comment, so export to another notes reference"
+)
+
+// Outcome of exporting comments as notes
+type NotesSummary struct {
+	Written int
+	Removed int
+}
+
+// Start of the notes rendered from comments by ExportNotes
+var renderedNoteRegexp = regexp.MustCompile(`^comment [0-9a-f]{7}\nAuthor: `)
+
+// Expand the name of a notes reference in the same way as
+// `git notes --ref`, so `review` refers to `refs/notes/review`
+func ExpandNotesRef(name string) string {
+	if len(name) == 0 {
+		return DefaultNotesRef
+	} else if strings.HasPrefix(name, "refs/") {
+		return name
+	} else if strings.HasPrefix(name, "notes/") {
+		return "refs/" + name
+	}
+	return notesRefPrefix + name
+}
+
+// Import each note within a notes reference as a comment on the
+// commit it annotates. The author of the comment is the author of the
+// notes commit which added the note. Notes exported from comments are
+// not imported, and each commit's note is imported once, so importing
+// again or editing a note skips it.
+// @return result.Result<*ImportSummary, error>
+func ImportNotes(repoPath, notesRef string) result.Result {
+	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
+		return gg.NoteAuthors(repo, notesRef).FlatMap(func(value interface{}) result.Result {
+			authors := value.(map[string]*git.Signature)
+			comments := make([]*ImportedComment, 0)
+			return gg.IterateNotes(repo, notesRef, func(annotated *git.Oid, note *git.Note) {
+				if isRenderedNote(note.Message()) {
+					return
+				}
+				author, ok := authors[note.Id().String()]
+				if !ok {
+					author = note.Author()
+				}
+				person := &Person{author.Name, author.Email, author.When, author.When.Format("-0700")}
+				comments = append(comments, importedNote(notesRef, annotated.String(), note.Message(), person))
+			}).FlatMap(func(value interface{}) result.Result {
+				return ImportComments(repoPath, comments)
+			})
+		})
+	})
+}
+
+// Replace the notes of each commented commit with a summary of the
+// comments, so the comments are shown by `git log --notes=<ref>`.
+// Notes exported earlier for commits which no longer have comments are
+// removed. Nothing is exported if any of the commented commits has a
+// note which was not exported from comments.
+// @return result.Result<*NotesSummary, error>
+func ExportNotes(repoPath, notesRef string) result.Result {
+	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
+		return configuredCommitter(repoPath).Analysis(func(committer interface{}) result.Result {
+			return AllComments(repo).FlatMap(func(comments interface{}) result.Result {
+				return exportNotes(repo, notesRef, comments.(CommentSlice), committer.(*Person).Signature())
+			})
+		}, func(err error) result.Result {
+			return result.NewFailure(errors.New(noCommitterError))
+		})
+	})
+}
+
+func exportNotes(repo *git.Repository, notesRef string, comments CommentSlice, sig *git.Signature) result.Result {
+	commented := make(map[string]CommentSlice)
+	for _, comment := range comments {
+		if !comment.Deleted {
+			commented[*comment.Commit] = append(commented[*comment.Commit], comment)
+		}
+	}
+	commits := make([]string, 0, len(commented))
+	for commit := range commented {
+		commits = append(commits, commit)
+	}
+	sort.Strings(commits)
+	oids := make([]*git.Oid, 0, len(commits))
+	for _, commit := range commits {
+		oid, err := git.NewOid(commit)
+		if err != nil {
+			return result.NewFailure(err)
+		}
+		existing, err := gg.ReadNote(repo, notesRef, oid).Dematerialize()
+		if err != nil {
+			return result.NewFailure(err)
+		} else if !isReplaceableNote(existing.(string)) {
+			return result.NewFailure(fmt.Errorf(foreignNoteError, commit[:7], notesRef))
+		}
+		oids = append(oids, oid)
+	}
+	stale := make([]*git.Oid, 0)
+	iterated := gg.IterateNotes(repo, notesRef, func(annotated *git.Oid, note *git.Note) {
+		if _, ok := commented[annotated.String()]; !ok && isRenderedNote(note.Message()) {
+			stale = append(stale, annotated)
+		}
+	})
+	if iterated.Failure != nil {
+		return iterated
+	}
+	for index, oid := range oids {
+		if written := gg.WriteNote(repo, notesRef, oid, RenderNote(commented[commits[index]]), sig); written.Failure != nil {
+			return written
+		}
+	}
+	for _, oid := range stale {
+		if removed := gg.RemoveNote(repo, notesRef, oid, sig); removed.Failure != nil {
+			return removed
+		}
+	}
+	return result.NewSuccess(&NotesSummary{len(oids), len(stale)})
+}
+
+// Whether a note is missing or was rendered from comments, so it can
+// be replaced without losing notes written by other tools
+func isReplaceableNote(content string) bool {
+	return len(content) == 0 || isRenderedNote(content)
+}
+
+// Whether a note was rendered from comments by ExportNotes
+func isRenderedNote(content string) bool {
+	return renderedNoteRegexp.MatchString(content)
+}
+
+// Render comments as plain text in the style of `git log`
+func RenderNote(comments CommentSlice) string {
+	var buffer bytes.Buffer
+	for index, comment := range comments {
+		if index > 0 {
+			buffer.WriteString("\n")
+		}
+		fmt.Fprintf(&buffer, "comment %v\n", (*comment.ID)[:7])
+		fmt.Fprintf(&buffer, "Author: %v <%v>\n", comment.Author.Name, comment.Author.Email)
+		fmt.Fprintf(&buffer, "Date:   %v\n", comment.Author.Date.Format(noteDateFormat))
+		if comment.FileRef != nil && len(comment.FileRef.Path) > 0 {
+			fmt.Fprintf(&buffer, "File:   %v\n", comment.FileRef.Serialize())
+		}
+		buffer.WriteString("\n")
+		for _, line := range strings.Split(comment.Content, "\n") {
+			buffer.WriteString(strings.TrimRight(noteIndent+line, " ") + "\n")
+		}
+	}
+	return buffer.String()
+}
+
+// Create a comment from the note on a commit, identified by the notes
+// reference and commit so it stays the same when the note is edited
+func importedNote(notesRef, commit, message string, author *Person) *ImportedComment {
+	identifier := fmt.Sprintf("%v%v%v", notesRef, externalIDSeparator, commit)
+	imported := &ImportedComment{nil, NotesService, identifier, ""}
+	if value, err := NewComment(strings.TrimSpace(message), commit, &FileRef{}, author).Dematerialize(); err == nil {
+		imported.Comment = value.(*Comment)
+	}
+	return imported
+}
@@ -0,0 +1,156 @@
+package libgitcomment
+
+import (
+	"github.com/stvp/assert"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Create a repository with two commits, returning its path and a
+// function running git within it as the given author
+func notesRepository(t *testing.T) (string, func(author string, args ...string) string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "git-comment-repo")
+	assert.Nil(t, err)
+	run := func(author string, args ...string) string {
+		command := exec.Command("git", args...)
+		command.Dir = dir
+		command.Env = append(os.Environ(), "GIT_AUTHOR_NAME="+author, "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		output, err := command.Output()
+		assert.Nil(t, err)
+		return strings.TrimSpace(string(output))
+	}
+	run("Test", "init", "-q")
+	run("Test", "config", "user.name", "Test")
+	run("Test", "config", "user.email", "test@example.com")
+	run("Test", "commit", "-q", "--allow-empty", "-m", "First")
+	run("Test", "commit", "-q", "--allow-empty", "-m", "Second")
+	return dir, run
+}
+
+func TestExpandNotesRefDefault(t *testing.T) {
+	assert.Equal(t, ExpandNotesRef(""), "refs/notes/commits")
+}
+
+func TestExpandNotesRefShortName(t *testing.T) {
+	assert.Equal(t, ExpandNotesRef("review"), "refs/notes/review")
+	assert.Equal(t, ExpandNotesRef("notes/review"), "refs/notes/review")
+}
+
+func TestExpandNotesRefFullName(t *testing.T) {
+	assert.Equal(t, ExpandNotesRef("refs/notes/review"), "refs/notes/review")
+}
+
+func TestImportedNote(t *testing.T) {
+	author := &Person{"Selina Kyle", "cat@example.com", time.Unix(1437498360, 0), "+1100"}
+	imported := importedNote("refs/notes/commits", "acdacdacd", "Tested on staging\n", author)
+	assert.Equal(t, imported.Service, "notes")
+	assert.Equal(t, imported.ID, "refs/notes/commits:acdacdacd")
+	assert.Equal(t, imported.Comment.Content, "Tested on staging")
+	assert.Equal(t, *imported.Comment.FileRef, FileRef{})
+	assert.Equal(t, imported.Comment.Author, author)
+}
+
+func TestImportedEmptyNote(t *testing.T) {
+	author := &Person{"Selina Kyle", "cat@example.com", time.Unix(1437498360, 0), "+1100"}
+	assert.Nil(t, importedNote("refs/notes/commits", "acdacdacd", "\n", author).Comment)
+}
+
+func TestRenderNote(t *testing.T) {
+	date := time.Unix(1437498360, 0).In(time.FixedZone("", 11*60*60))
+	author := &Person{"Selina Kyle", "cat@example.com", date, "+1100"}
+	first, _ := NewComment("Needs a test\n\nFor the empty case", "acdacdacd", &FileRef{"main.go", 12, RefLineTypeNew}, author).Dematerialize()
+	second, _ := NewComment("Done", "acdacdacd", new(FileRef), author).Dematerialize()
+	firstID, secondID := "23caf9710a71e3736597415c57bdcf5eebae6bcb", "8ab7c6d9710a71e3736597415c57bdcf5eebae6b"
+	first.(*Comment).ID = &firstID
+	second.(*Comment).ID = &secondID
+	note := RenderNote(CommentSlice{first.(*Comment), second.(*Comment)})
+	assert.Equal(t, note, `comment 23caf97
+Author: Selina Kyle <cat@example.com>
+Date:   Wed Jul 22 04:06:00 2015 +1100
+File:   main.go:12
+
+    Needs a test
+
+    For the empty case
+
+comment 8ab7c6d
+Author: Selina Kyle <cat@example.com>
+Date:   Wed Jul 22 04:06:00 2015 +1100
+
+    Done
+`)
+}
+
+func TestIsReplaceableNote(t *testing.T) {
+	assert.True(t, isReplaceableNote(""))
+	assert.True(t, isReplaceableNote("comment 23caf97\nAuthor: Selina Kyle <cat@example.com>\n"))
+	assert.False(t, isReplaceableNote("Reviewed-by: Selina Kyle <cat@example.com>\n"))
+	assert.False(t, isReplaceableNote("comment on the release\n"))
+}
+
+func TestImportNotesAuthors(t *testing.T) {
+	dir, run := notesRepository(t)
+	defer os.RemoveAll(dir)
+	run("Selina Kyle", "notes", "add", "-m", "Tested on staging", "HEAD~1")
+	run("Bruce Wayne", "notes", "add", "-m", "Tested in production", "HEAD")
+	summary, err := ImportNotes(dir, DefaultNotesRef).Dematerialize()
+	assert.Nil(t, err)
+	added := summary.(*ImportSummary).Added
+	assert.Equal(t, len(added), 2)
+	authors := map[string]string{}
+	for _, comment := range added {
+		authors[comment.Content] = comment.Author.Name
+	}
+	assert.Equal(t, authors, map[string]string{"Tested on staging": "Selina Kyle", "Tested in production": "Bruce Wayne"})
+}
+
+func TestImportNotesSkipsEditedAndExportedNotes(t *testing.T) {
+	dir, run := notesRepository(t)
+	defer os.RemoveAll(dir)
+	run("Selina Kyle", "notes", "add", "-m", "Tested on staging", "HEAD")
+	_, err := ImportNotes(dir, DefaultNotesRef).Dematerialize()
+	assert.Nil(t, err)
+	summary, err := ImportNotes(dir, DefaultNotesRef).Dematerialize()
+	assert.Nil(t, err)
+	assert.Equal(t, len(summary.(*ImportSummary).Added), 0)
+	assert.Equal(t, len(summary.(*ImportSummary).Skipped), 1)
+	run("Selina Kyle", "notes", "add", "-f", "-m", "Tested on staging and production", "HEAD")
+	summary, err = ImportNotes(dir, DefaultNotesRef).Dematerialize()
+	assert.Nil(t, err)
+	assert.Equal(t, len(summary.(*ImportSummary).Added), 0)
+	assert.Equal(t, len(summary.(*ImportSummary).Skipped), 1)
+	_, err = ExportNotes(dir, "refs/notes/comments").Dematerialize()
+	assert.Nil(t, err)
+	summary, err = ImportNotes(dir, "refs/notes/comments").Dematerialize()
+	assert.Nil(t, err)
+	assert.Equal(t, len(summary.(*ImportSummary).Added), 0)
+}
+
+func TestExportNotesRemovesNotesWithoutComments(t *testing.T) {
+	dir, run := notesRepository(t)
+	defer os.RemoveAll(dir)
+	first, err := CreateComment(dir, "HEAD", "", "Needs a test", "", new(FileRef), nil).Dematerialize()
+	assert.Nil(t, err)
+	_, err = CreateComment(dir, "HEAD~1", "", "Looks good", "", new(FileRef), nil).Dematerialize()
+	assert.Nil(t, err)
+	summary, err := ExportNotes(dir, DefaultNotesRef).Dematerialize()
+	assert.Nil(t, err)
+	assert.Equal(t, *summary.(*NotesSummary), NotesSummary{2, 0})
+	_, err = DeleteComment(dir, *first.(*string)).Dematerialize()
+	assert.Nil(t, err)
+	summary, err = ExportNotes(dir, DefaultNotesRef).Dematerialize()
+	assert.Nil(t, err)
+	assert.Equal(t, *summary.(*NotesSummary), NotesSummary{1, 1})
+	assert.NotEqual(t, run("Test", "notes", "list", "HEAD~1"), "")
+	command := exec.Command("git", "notes", "list", "HEAD")
+	command.Dir = dir
+	assert.NotNil(t, command.Run())
+}
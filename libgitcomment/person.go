@@ -3,6 +3,7 @@ package libgitcomment
 import (
 	"errors"
 	"fmt"
+	gg "git"
 	"github.com/kylef/result.go/src/result"
 	git "gopkg.in/libgit2/git2go.v23"
 	"regexp"
@@ -46,6 +47,23 @@ func CreatePerson(properties string) result.Result {
 	})
 }
 
+// The author of new comments as configured in git
+// @return result.Result<*Person, error>
+func configuredAuthor(repoPath string) result.Result {
+	return gg.AuthorIdentity(repoPath).FlatMap(identityPerson)
+}
+
+// The committer of new comments as configured in git
+// @return result.Result<*Person, error>
+func configuredCommitter(repoPath string) result.Result {
+	return gg.CommitterIdentity(repoPath).FlatMap(identityPerson)
+}
+
+func identityPerson(value interface{}) result.Result {
+	identity := value.(*gg.Identity)
+	return result.NewSuccess(&Person{identity.Name, identity.Email, identity.Date, identity.Date.Format("-0700")})
+}
+
 func (p *Person) Serialize() string {
 	return fmt.Sprintf("%v <%v> %d %v", p.Name, p.Email, p.Date.Unix(), p.TimeOffset)
 }
@@ -15,6 +15,10 @@ type PropertyBlob struct {
 const lineSeparator string = "\n"
 const itemSeparator string = " "
 
+// Prefix of the lines following the first line of a multi-line
+// property value, in the same way as signatures in git commit headers
+const continuationPrefix string = " "
+
 func NewPropertyBlob() *PropertyBlob {
 	props := ordered_map.NewOrderedMap()
 	return &PropertyBlob{props, ""}
@@ -26,6 +30,7 @@ func CreatePropertyBlob(content string) *PropertyBlob {
 	lines := strings.Split(content, lineSeparator)
 	count := len(lines)
 	var message bytes.Buffer
+	var lastName string
 	for index, line := range lines {
 		if len(line) == 0 {
 			if parsingMessage {
@@ -38,12 +43,17 @@ func CreatePropertyBlob(content string) *PropertyBlob {
 			if index < count-1 {
 				message.WriteString(lineSeparator)
 			}
+		} else if strings.HasPrefix(line, continuationPrefix) {
+			if value, ok := props.Get(lastName); ok {
+				props.Set(lastName, value.(string)+lineSeparator+line[len(continuationPrefix):])
+			}
 		} else {
 			property := strings.SplitN(line, itemSeparator, 2)
 			if len(property) == 2 {
 				name := property[0]
 				value := property[1]
 				props.Set(name, value)
+				lastName = name
 			}
 		}
 	}
@@ -105,7 +115,7 @@ func (p *PropertyBlob) Serialize() string {
 		if nOk && vOk {
 			content.WriteString(name)
 			content.WriteString(itemSeparator)
-			content.WriteString(value)
+			content.WriteString(strings.Replace(value, lineSeparator, lineSeparator+continuationPrefix, -1))
 			content.WriteString(lineSeparator)
 		}
 	}
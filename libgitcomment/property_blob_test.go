@@ -49,3 +49,20 @@ func TestSerialize(t *testing.T) {
 	content := strings.Join([]string{props, message}, "")
 	assert.Equal(t, content, blob.Serialize())
 }
+
+func TestSerializeMultilineProperty(t *testing.T) {
+	blob := NewPropertyBlob()
+	blob.Set("gpgsig", "-----BEGIN PGP SIGNATURE-----\n\niQEcBAABAgAGBQJV\n-----END PGP SIGNATURE-----")
+	blob.Set("file", "src/example.txt")
+	blob.Message = "Looks good"
+	content := "gpgsig -----BEGIN PGP SIGNATURE-----\n \n iQEcBAABAgAGBQJV\n -----END PGP SIGNATURE-----\nfile src/example.txt\n\nLooks good"
+	assert.Equal(t, content, blob.Serialize())
+}
+
+func TestMultilinePropertyFromBlob(t *testing.T) {
+	content := "gpgsig -----BEGIN PGP SIGNATURE-----\n \n iQEcBAABAgAGBQJV\n -----END PGP SIGNATURE-----\nfile src/example.txt\n\nLooks good"
+	blob := CreatePropertyBlob(content)
+	assert.Equal(t, "-----BEGIN PGP SIGNATURE-----\n\niQEcBAABAgAGBQJV\n-----END PGP SIGNATURE-----", *blob.Get("gpgsig"))
+	assert.Equal(t, "src/example.txt", *blob.Get("file"))
+	assert.Equal(t, "Looks good", blob.Message)
+}
@@ -0,0 +1,184 @@
+package libgitcomment
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	gg "git"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	zeroID                 = "0000000000000000000000000000000000000000"
+	invalidUpdateError     = "Invalid reference update: %v"
+	invalidRefPathError    = "Invalid comment reference path"
+	refIDMismatchError     = "Comment reference does not match comment ID %v"
+	missingPropertyError   = "Comment is missing the %v property"
+	invalidPropertyError   = "Comment has an invalid %v property"
+	malformedPropertyError = "Comment has a malformed property line: %v"
+	missingMessageError    = "Comment has no message"
+	refCommitMismatchError = "Comment is on commit %v but the reference is for commit %v"
+	unknownCommitError     = "Comment is on unknown commit %v"
+	resurrectedError       = "Comment was deleted as %v"
+	pusherMismatchError    = "Comment author %v does not match the pushing identity %v"
+)
+
+var commentRefRe = regexp.MustCompile(`^refs/comments/([0-9a-f]{4})/([0-9a-f]{36})/([0-9a-f]{40})$`)
+var objectIDRe = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// An update to a reference received by a server-side git hook
+type RefUpdate struct {
+	Name  string
+	OldID string
+	NewID string
+}
+
+// Access to the objects and comments of a repository receiving a push.
+// Objects which are part of the push may be quarantined, so they are
+// not necessarily visible to libgit2.
+type ReceivedObjects interface {
+	// Content of a blob
+	ReadBlob(identifier string) (string, error)
+	// Whether a commit exists
+	HasCommit(hash string) bool
+	// Comments on a commit which were accepted previously
+	CommentsOnCommit(hash string) (CommentSlice, error)
+}
+
+// Parse the reference updates provided to a pre-receive hook, one per
+// line in the format `<old-value> <new-value> <ref-name>`
+func ParseRefUpdates(content io.Reader) ([]*RefUpdate, error) {
+	updates := make([]*RefUpdate, 0)
+	scanner := bufio.NewScanner(content)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf(invalidUpdateError, line)
+		}
+		updates = append(updates, &RefUpdate{fields[2], fields[0], fields[1]})
+	}
+	return updates, scanner.Err()
+}
+
+// Check an update to a comment reference. Deletions, updates to
+// references outside of the comment namespace, and updates to the
+// version reference are accepted. Comments with a deleted version in the
+// repository are rejected, which relies on deleted versions remaining
+// after their comments are pruned. If a pusher email is provided, new
+// comments must have been authored or last amended by the pusher.
+func ValidateRefUpdate(objects ReceivedObjects, update *RefUpdate, pusher string) error {
+	if !strings.HasPrefix(update.Name, gg.CommentRefBase+"/") || update.NewID == zeroID {
+		return nil
+	} else if update.Name == path.Join(gg.CommentRefBase, versionRef) {
+		return nil
+	}
+	commit, err := validateRefPath(update)
+	if err != nil {
+		return err
+	}
+	content, err := objects.ReadBlob(update.NewID)
+	if err != nil {
+		return err
+	}
+	comment, err := validateRefComment(commit, content)
+	if err != nil {
+		return err
+	} else if !objects.HasCommit(commit) {
+		return fmt.Errorf(unknownCommitError, commit)
+	}
+	comment.ID = &update.NewID
+	if !comment.Deleted {
+		existing, err := objects.CommentsOnCommit(*comment.Commit)
+		if err != nil {
+			return err
+		}
+		if deleted := deletedVersion(comment, existing); deleted != nil {
+			return fmt.Errorf(resurrectedError, *deleted.ID)
+		}
+	}
+	if len(pusher) > 0 && !matchesPusher(comment, pusher) {
+		return fmt.Errorf(pusherMismatchError, comment.Author.Email, pusher)
+	}
+	return nil
+}
+
+// Check the path of a comment reference, returning the commit it refers
+// to
+func validateRefPath(update *RefUpdate) (string, error) {
+	match := commentRefRe.FindStringSubmatch(update.Name)
+	if len(match) == 0 {
+		return "", errors.New(invalidRefPathError)
+	} else if match[3] != update.NewID {
+		return "", fmt.Errorf(refIDMismatchError, update.NewID)
+	}
+	return match[1] + match[2], nil
+}
+
+// Check that the content of a comment reference is a valid comment on
+// the commit of the reference
+func validateRefComment(commit, content string) (*Comment, error) {
+	if err := ValidateCommentContent(content); err != nil {
+		return nil, err
+	}
+	comment := DeserializeComment(content).Success.(*Comment)
+	if *comment.Commit != commit {
+		return nil, fmt.Errorf(refCommitMismatchError, *comment.Commit, commit)
+	}
+	return comment, nil
+}
+
+// Check that serialized comment content is well-formed, with a valid
+// commit, author, and amender, and a message unless deleted
+func ValidateCommentContent(content string) error {
+	for _, line := range strings.Split(content, lineSeparator) {
+		if len(line) == 0 {
+			break
+		} else if !strings.HasPrefix(line, continuationPrefix) && !strings.Contains(line, itemSeparator) {
+			return fmt.Errorf(malformedPropertyError, line)
+		}
+	}
+	blob := CreatePropertyBlob(content)
+	for _, key := range []string{commitKey, authorKey, amenderKey} {
+		if blob.Get(key) == nil {
+			return fmt.Errorf(missingPropertyError, key)
+		}
+	}
+	if !objectIDRe.MatchString(*blob.Get(commitKey)) {
+		return fmt.Errorf(invalidPropertyError, commitKey)
+	}
+	for _, key := range []string{authorKey, amenderKey} {
+		if CreatePerson(*blob.Get(key)).Failure != nil {
+			return fmt.Errorf(invalidPropertyError, key)
+		}
+	}
+	if blob.Get(deletedKey) == nil && len(blob.Message) == 0 {
+		return errors.New(missingMessageError)
+	}
+	return nil
+}
+
+// Find a deleted version of a comment, which has the same identity
+func deletedVersion(comment *Comment, existing CommentSlice) *Comment {
+	for _, other := range existing {
+		if other.Deleted && other.Identity() == comment.Identity() {
+			return other
+		}
+	}
+	return nil
+}
+
+func matchesPusher(comment *Comment, pusher string) bool {
+	for _, person := range []*Person{comment.Author, comment.Amender} {
+		if strings.EqualFold(person.Email, pusher) {
+			return true
+		}
+	}
+	return false
+}
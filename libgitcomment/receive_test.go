@@ -0,0 +1,184 @@
+package libgitcomment
+
+import (
+	"errors"
+	"github.com/stvp/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+const receivedCommit = "0155eb4229851634a0f03eb265b69f5a2d56f341"
+
+type fakeReceivedObjects struct {
+	blobs    map[string]string
+	commits  map[string]bool
+	comments CommentSlice
+}
+
+func (f *fakeReceivedObjects) ReadBlob(identifier string) (string, error) {
+	if content, ok := f.blobs[identifier]; ok {
+		return content, nil
+	}
+	return "", errors.New("missing blob")
+}
+
+func (f *fakeReceivedObjects) HasCommit(hash string) bool {
+	return f.commits[hash]
+}
+
+func (f *fakeReceivedObjects) CommentsOnCommit(hash string) (CommentSlice, error) {
+	return f.comments, nil
+}
+
+func receivedComment() *Comment {
+	author := &Person{"Selina Kyle", "cat@example.com", time.Unix(1437498360, 0), "+1100"}
+	c, _ := NewComment("Needs a test", receivedCommit, &FileRef{"main.go", 12, RefLineTypeNew}, author).Dematerialize()
+	return c.(*Comment)
+}
+
+func receivedObjects(id, content string) *fakeReceivedObjects {
+	return &fakeReceivedObjects{map[string]string{id: content}, map[string]bool{receivedCommit: true}, nil}
+}
+
+func receivedUpdate(commit, id string) *RefUpdate {
+	return &RefUpdate{"refs/comments/" + commit[:4] + "/" + commit[4:] + "/" + id, zeroID, id}
+}
+
+func TestParseRefUpdates(t *testing.T) {
+	input := zeroID + " 23caf9710a71e3736597415c57bdcf5eebae6bcb refs/comments/0155/eb42/23caf97\n\n"
+	updates, err := ParseRefUpdates(strings.NewReader(input))
+	assert.Nil(t, err)
+	assert.Equal(t, len(updates), 1)
+	assert.Equal(t, *updates[0], RefUpdate{"refs/comments/0155/eb42/23caf97", zeroID, "23caf9710a71e3736597415c57bdcf5eebae6bcb"})
+}
+
+func TestParseInvalidRefUpdates(t *testing.T) {
+	_, err := ParseRefUpdates(strings.NewReader("refs/heads/master\n"))
+	assert.NotNil(t, err)
+}
+
+func TestValidateRefUpdate(t *testing.T) {
+	id := "23caf9710a71e3736597415c57bdcf5eebae6bcb"
+	objects := receivedObjects(id, receivedComment().Serialize())
+	assert.Nil(t, ValidateRefUpdate(objects, receivedUpdate(receivedCommit, id), ""))
+}
+
+func TestValidateIgnoredRefUpdates(t *testing.T) {
+	objects := receivedObjects("", "")
+	assert.Nil(t, ValidateRefUpdate(objects, &RefUpdate{"refs/heads/master", zeroID, receivedCommit}, ""))
+	assert.Nil(t, ValidateRefUpdate(objects, &RefUpdate{"refs/comments/version", zeroID, receivedCommit}, ""))
+	assert.Nil(t, ValidateRefUpdate(objects, &RefUpdate{"refs/comments/0155/bad", receivedCommit, zeroID}, ""))
+}
+
+func TestValidateInvalidRefPath(t *testing.T) {
+	objects := receivedObjects("", "")
+	err := ValidateRefUpdate(objects, &RefUpdate{"refs/comments/0155/bad", zeroID, receivedCommit}, "")
+	assert.Equal(t, err.Error(), invalidRefPathError)
+}
+
+func TestValidateRefIDMismatch(t *testing.T) {
+	id := "23caf9710a71e3736597415c57bdcf5eebae6bcb"
+	objects := receivedObjects(id, receivedComment().Serialize())
+	update := receivedUpdate(receivedCommit, id)
+	update.NewID = "8ab7c6d9710a71e3736597415c57bdcf5eebae6b"
+	assert.NotNil(t, ValidateRefUpdate(objects, update, ""))
+}
+
+func TestValidateRefCommitMismatch(t *testing.T) {
+	id := "23caf9710a71e3736597415c57bdcf5eebae6bcb"
+	other := "8ab7c6d9710a71e3736597415c57bdcf5eebae6b"
+	objects := receivedObjects(id, receivedComment().Serialize())
+	objects.commits[other] = true
+	err := ValidateRefUpdate(objects, receivedUpdate(other, id), "")
+	assert.True(t, strings.Contains(err.Error(), "reference is for commit "+other))
+}
+
+func TestValidateUnknownCommit(t *testing.T) {
+	id := "23caf9710a71e3736597415c57bdcf5eebae6bcb"
+	objects := receivedObjects(id, receivedComment().Serialize())
+	objects.commits = nil
+	err := ValidateRefUpdate(objects, receivedUpdate(receivedCommit, id), "")
+	assert.True(t, strings.Contains(err.Error(), "unknown commit"))
+}
+
+func TestValidateResurrectedComment(t *testing.T) {
+	id := "23caf9710a71e3736597415c57bdcf5eebae6bcb"
+	objects := receivedObjects(id, receivedComment().Serialize())
+	deleted, deletedID := receivedComment(), "8ab7c6d9710a71e3736597415c57bdcf5eebae6b"
+	deleted.Deleted = true
+	deleted.ID = &deletedID
+	deleted.Origin = &id
+	objects.comments = CommentSlice{deleted}
+	err := ValidateRefUpdate(objects, receivedUpdate(receivedCommit, id), "")
+	assert.Equal(t, err.Error(), "Comment was deleted as "+deletedID)
+}
+
+func TestValidateCommentPrunedFromRemote(t *testing.T) {
+	id, deletedID := "23caf9710a71e3736597415c57bdcf5eebae6bcb", "8ab7c6d9710a71e3736597415c57bdcf5eebae6b"
+	live, deleted := receivedComment(), receivedComment()
+	live.ID = &id
+	deleted.ID = &deletedID
+	deleted.Origin = &id
+	deleted.Deleted = true
+	remote := CommentSlice{live}
+	prunable := prunableVersions(CommentSlice{deleted}, remote)
+	assert.Equal(t, prunable, CommentSlice{live})
+	objects := receivedObjects(id, live.Serialize())
+	objects.comments = unpushedDeletions(CommentSlice{deleted}, remote, prunable)
+	err := ValidateRefUpdate(objects, receivedUpdate(receivedCommit, id), "")
+	assert.Equal(t, err.Error(), "Comment was deleted as "+deletedID)
+}
+
+func TestValidateCommentBySameAuthorAsDeletedComment(t *testing.T) {
+	id := "23caf9710a71e3736597415c57bdcf5eebae6bcb"
+	objects := receivedObjects(id, receivedComment().Serialize())
+	deleted, deletedID := receivedComment(), "8ab7c6d9710a71e3736597415c57bdcf5eebae6b"
+	deleted.Deleted = true
+	deleted.ID = &deletedID
+	objects.comments = CommentSlice{deleted}
+	assert.Nil(t, ValidateRefUpdate(objects, receivedUpdate(receivedCommit, id), ""))
+}
+
+func TestValidateDeletionOfComment(t *testing.T) {
+	id := "23caf9710a71e3736597415c57bdcf5eebae6bcb"
+	deleted := receivedComment()
+	deleted.Deleted = true
+	objects := receivedObjects(id, deleted.Serialize())
+	objects.comments = CommentSlice{deleted}
+	assert.Nil(t, ValidateRefUpdate(objects, receivedUpdate(receivedCommit, id), ""))
+}
+
+func TestValidatePusher(t *testing.T) {
+	id := "23caf9710a71e3736597415c57bdcf5eebae6bcb"
+	objects := receivedObjects(id, receivedComment().Serialize())
+	update := receivedUpdate(receivedCommit, id)
+	assert.Nil(t, ValidateRefUpdate(objects, update, "Cat@Example.com"))
+	assert.NotNil(t, ValidateRefUpdate(objects, update, "bruce@example.com"))
+}
+
+func TestValidateCommentContent(t *testing.T) {
+	assert.Nil(t, ValidateCommentContent(receivedComment().Serialize()))
+}
+
+func TestValidateCommentMalformedProperty(t *testing.T) {
+	content := strings.Replace(receivedComment().Serialize(), "file main.go:12", "garbage", 1)
+	assert.Equal(t, ValidateCommentContent(content).Error(), "Comment has a malformed property line: garbage")
+}
+
+func TestValidateCommentMissingAuthor(t *testing.T) {
+	lines := strings.Split(receivedComment().Serialize(), "\n")
+	content := strings.Join(append(lines[:2], lines[3:]...), "\n")
+	assert.Equal(t, ValidateCommentContent(content).Error(), "Comment is missing the author property")
+}
+
+func TestValidateCommentInvalidCommit(t *testing.T) {
+	content := strings.Replace(receivedComment().Serialize(), receivedCommit, "HEAD", 1)
+	assert.Equal(t, ValidateCommentContent(content).Error(), "Comment has an invalid commit property")
+}
+
+func TestValidateCommentMissingMessage(t *testing.T) {
+	comment := receivedComment()
+	comment.Content = ""
+	assert.Equal(t, ValidateCommentContent(comment.Serialize()).Error(), missingMessageError)
+}
@@ -6,11 +6,16 @@ import (
 	gg "git"
 	"github.com/kylef/result.go/src/result"
 	git "gopkg.in/libgit2/git2go.v23"
+	"path"
+	"sort"
+	"strings"
 )
 
 const (
 	commentDefaultFetch = "+refs/comments/*:refs/remotes/%v/comments/*"
 	commentDefaultPush  = "refs/comments/*"
+	mergedMessageFormat = "Merged comment ref [%v] from %v"
+	pushedMessageFormat = "Pushed comment ref [%v] to %v"
 )
 
 // Configure a remote to fetch and push comment changes by default
@@ -28,14 +33,649 @@ func ConfigureRemoteForComments(repoPath, remoteName string) result.Result {
 	})
 }
 
-func DeleteRemoteComment(repoPath, remoteName, commentID string) result.Result {
-	return gg.WithRemote(repoPath, remoteName, func(remote *git.Remote) result.Result {
-		return CreatePerson(gg.ConfiguredCommitter(repoPath)).Analysis(func(val interface{}) result.Result {
-			sig := val.(*Person).Signature()
-			refspec := fmt.Sprintf(":%v", commentID)
-			return gg.Push(repoPath, remoteName, []string{refspec}, sig)
-		}, func(err error) result.Result {
-			return result.NewFailure(errors.New(noCommitterError))
+// Delete the remote references of comments in a single push
+// @return result.Result<CommentSlice, error>
+func DeleteRemoteComments(repoPath, remoteName string, identifiers []string) result.Result {
+	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
+		comments := make(CommentSlice, 0, len(identifiers))
+		for _, identifier := range identifiers {
+			comment, err := CommentByID(repo, identifier).Dematerialize()
+			if err != nil {
+				return result.NewFailure(err)
+			}
+			comments = append(comments, comment.(*Comment))
+		}
+		return deleteRemoteComments(repo, repoPath, remoteName, comments, CommentSlice{})
+	})
+}
+
+// Delete the remote references of comments which have been deleted
+// locally, using the comments last fetched from the remote. The deleted
+// versions of the comments are pushed in their place and are never
+// pruned, so the remote keeps a record of each deletion, which the
+// receive hook uses to reject the comment being pushed again. The
+// deleted versions are also kept locally, so they cannot be replaced by
+// an earlier version. Unless dryRun is set, the references are deleted
+// in a single push.
+// @return result.Result<CommentSlice, error>
+func PruneRemoteComments(repoPath, remoteName string, dryRun bool) result.Result {
+	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
+		return result.Combine(func(values ...interface{}) result.Result {
+			local, remote := values[0].(CommentSlice), values[1].(CommentSlice)
+			prunable := prunableVersions(local, remote)
+			if dryRun || len(prunable) == 0 {
+				return result.NewSuccess(prunable)
+			}
+			tombstones := unpushedDeletions(local, remote, prunable)
+			return deleteRemoteComments(repo, repoPath, remoteName, prunable, tombstones)
+		}, AllComments(repo), RemoteComments(repo, remoteName))
+	})
+}
+
+// Find remote versions of comments whose latest local version is deleted
+func prunableVersions(local, remote CommentSlice) CommentSlice {
+	prunable := make(CommentSlice, 0)
+	localVersions := groupVersions(local)
+	for _, comment := range remote {
+		latest := latestVersion(localVersions[comment.Identity()])
+		if latest != nil && latest.Deleted && !comment.Deleted {
+			prunable = append(prunable, comment)
+		}
+	}
+	return prunable
+}
+
+// Find the deleted versions of the comments being pruned which are not
+// on the remote
+func unpushedDeletions(local, remote, prunable CommentSlice) CommentSlice {
+	unpushed := make(CommentSlice, 0)
+	localVersions := groupVersions(local)
+	remoteVersions := groupVersions(remote)
+	seen := make(map[string]bool)
+	for _, comment := range prunable {
+		key := comment.Identity()
+		latest := latestVersion(localVersions[key])
+		if !seen[key] && !containsVersion(remoteVersions[key], latest) {
+			unpushed = append(unpushed, latest)
+		}
+		seen[key] = true
+	}
+	return unpushed
+}
+
+// Delete the references of comments from the remote and the matching
+// remote-tracking references, pushing deleted versions of comments in
+// the same push. Remotes without atomic pushes may apply some updates
+// and reject others, in which case the remote-tracking references of the
+// rejected comments are unchanged and the rejections are reported as an
+// error.
+// @return result.Result<CommentSlice, error>
+func deleteRemoteComments(repo *git.Repository, repoPath, remoteName string, comments, deletions CommentSlice) result.Result {
+	refspecs := make([]string, 0, len(comments)+len(deletions))
+	for _, comment := range comments {
+		refPath, err := commentRefPath(comment).Dematerialize()
+		if err != nil {
+			return result.NewFailure(err)
+		}
+		refspecs = append(refspecs, fmt.Sprintf(":%v", refPath))
+	}
+	for _, comment := range deletions {
+		refPath, err := commentRefPath(comment).Dematerialize()
+		if err != nil {
+			return result.NewFailure(err)
+		}
+		refspecs = append(refspecs, fmt.Sprintf("%v:%v", refPath, refPath))
+	}
+	return pushRefspecs(repoPath, remoteName, refspecs).FlatMap(func(value interface{}) result.Result {
+		deleted, rejected := acceptedComments(comments, value.(map[string]string))
+		pushed, rejectedDeletions := acceptedComments(deletions, value.(map[string]string))
+		for _, comment := range deleted {
+			if err := deleteTrackingReference(repo, remoteName, comment); err != nil {
+				return result.NewFailure(err)
+			}
+		}
+		for _, comment := range pushed {
+			if err := createTrackingReference(repo, remoteName, comment); err != nil {
+				return result.NewFailure(err)
+			}
+		}
+		rejected = append(rejected, rejectedDeletions...)
+		if len(rejected) > 0 {
+			return result.NewFailure(fmt.Errorf(partialDeleteError, len(deleted), len(comments), rejectionMessages(rejected)))
+		}
+		return result.NewSuccess(deleted)
+	})
+}
+
+// A comment whose reference update was rejected by a remote
+type RejectedComment struct {
+	Comment *Comment
+	Reason  string
+}
+
+func (r *RejectedComment) Error() string {
+	return fmt.Sprintf(rejectedPushError, (*r.Comment.ID)[:7], r.Reason)
+}
+
+// Reasons for rejected reference updates, one per line
+func rejectionMessages(rejected []*RejectedComment) string {
+	messages := make([]string, 0, len(rejected))
+	for _, rejection := range rejected {
+		messages = append(messages, rejection.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Split comments into those whose reference updates were accepted by a
+// remote and those which were rejected, given the reasons for rejected
+// updates by reference name. The references of the comments must be
+// valid.
+func acceptedComments(comments CommentSlice, rejections map[string]string) (CommentSlice, []*RejectedComment) {
+	accepted := make(CommentSlice, 0, len(comments))
+	rejected := make([]*RejectedComment, 0)
+	for _, comment := range comments {
+		refPath, _ := commentRefPath(comment).Dematerialize()
+		if reason, ok := rejections[refPath.(string)]; ok {
+			rejected = append(rejected, &RejectedComment{comment, reason})
+		} else {
+			accepted = append(accepted, comment)
+		}
+	}
+	return accepted, rejected
+}
+
+// Push refspecs to a remote atomically where the remote supports it, as
+// the configured committer, providing the reasons for rejected updates
+// by reference name
+// @return result.Result<map[string]string, error>
+func pushRefspecs(repoPath, remoteName string, refspecs []string) result.Result {
+	return configuredCommitter(repoPath).Analysis(func(val interface{}) result.Result {
+		return gg.AtomicPush(repoPath, remoteName, refspecs, val.(*Person).Signature())
+	}, func(err error) result.Result {
+		return result.NewFailure(errors.New(noCommitterError))
+	})
+}
+
+// How to resolve a comment which was amended both locally and on a
+// remote since the last synchronization. Deleting a comment always
+// takes precedence over amending it.
+type ConflictPolicy string
+
+const (
+	// Keep the most recently amended version
+	PreferNewest ConflictPolicy = "newest"
+	// Keep the local version
+	PreferLocal ConflictPolicy = "local"
+	// Keep the remote version
+	PreferRemote ConflictPolicy = "remote"
+)
+
+// A comment amended both locally and on a remote
+type CommentConflict struct {
+	Local      *Comment
+	Remote     *Comment
+	Resolution *Comment
+}
+
+// Outcome of synchronizing comments with a remote
+type RemoteSyncSummary struct {
+	// Remote comments added to or updated in the local comments
+	Merged CommentSlice
+	// Local comments pushed to the remote
+	Pushed CommentSlice
+	// Local comments removed because they were deleted from the remote
+	Removed   CommentSlice
+	Conflicts []*CommentConflict
+}
+
+// Changes required to bring local and remote comments in line
+type remoteSyncPlan struct {
+	// Remote versions to reference locally
+	merge CommentSlice
+	// Local versions to remove
+	unlink CommentSlice
+	// Local comments whose versions were all removed from the remote
+	removed CommentSlice
+	// Local versions to push to the remote
+	push CommentSlice
+	// Remote versions to remove from the remote
+	prune     CommentSlice
+	conflicts []*CommentConflict
+}
+
+// Fetch comments from a remote, merge new and updated remote comments
+// into the local comments, and push new and updated local comments back
+// to the remote. Versions of the same comment are matched by the
+// identifier of their first version. When a comment was amended on both sides since the last
+// synchronization, the policy decides which version is kept. Local
+// comments fetched previously which are no longer on the remote were
+// deleted there, so they are removed rather than pushed again.
+//
+// Local comments are pushed in a single atomic push where the remote
+// supports it. If the remote rejects any update the synchronization
+// fails, leaving local comments unchanged, and remotes without atomic
+// pushes keep the updates they accepted until the next synchronization.
+// @return result.Result<*RemoteSyncSummary, error>
+func SyncRemoteComments(repoPath, remoteName string, policy ConflictPolicy) result.Result {
+	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
+		return RemoteComments(repo, remoteName).FlatMap(func(previous interface{}) result.Result {
+			refspec := fmt.Sprintf(commentDefaultFetch, remoteName)
+			return gg.Fetch(repoPath, remoteName, []string{refspec}).FlatMap(func(value interface{}) result.Result {
+				return result.Combine(func(values ...interface{}) result.Result {
+					known := knownVersions(previous.(CommentSlice))
+					plan := planRemoteSync(values[0].(CommentSlice), values[1].(CommentSlice), known, policy)
+					return applyRemoteSync(repo, repoPath, remoteName, plan)
+				}, AllComments(repo), RemoteComments(repo, remoteName))
+			})
+		})
+	})
+}
+
+// Fetch comments on the commits in a revision range from a remote, and
+// merge new and updated remote comments into the local comments. Local
+// amendments are kept, to be pushed by `SyncRemoteComments`.
+// @return result.Result<CommentSlice, error>
+func FetchRemoteComments(repoPath, remoteName, revisions string) result.Result {
+	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
+		return gg.ResolveCommits(repo, revisions).FlatMap(func(commitRange interface{}) result.Result {
+			commits := commitRange.(*gg.CommitRange).Commits()
+			hashes := make([]string, len(commits))
+			for index, commit := range commits {
+				hashes[index] = commit.Id().String()
+			}
+			return RemoteComments(repo, remoteName).FlatMap(func(previous interface{}) result.Result {
+				refspecs := commitFetchRefspecs(remoteName, hashes)
+				return gg.Fetch(repoPath, remoteName, refspecs).FlatMap(func(value interface{}) result.Result {
+					return result.Combine(func(values ...interface{}) result.Result {
+						remote := commentsOnHashes(values[1].(CommentSlice), hashes)
+						plan := planRemoteSync(values[0].(CommentSlice), remote, knownVersions(previous.(CommentSlice)), PreferLocal)
+						if err := mergeRemoteComments(repo, remoteName, plan); err != nil {
+							return result.NewFailure(err)
+						}
+						return result.NewSuccess(plan.merge)
+					}, CommentsOnCommits(repo, commits), RemoteComments(repo, remoteName))
+				})
+			})
 		})
 	})
 }
+
+// Refspecs fetching the comments on each commit into the remote-tracking
+// namespace
+func commitFetchRefspecs(remoteName string, hashes []string) []string {
+	refspecs := make([]string, 0, len(hashes))
+	seen := make(map[string]bool)
+	for _, hash := range hashes {
+		dir, err := gg.CommitRefDir(hash).Dematerialize()
+		if err != nil || seen[dir.(string)] {
+			continue
+		}
+		seen[dir.(string)] = true
+		refspec := fmt.Sprintf("+%v/*:%v/*", dir, remoteTrackingPath(remoteName, dir.(string)))
+		refspecs = append(refspecs, refspec)
+	}
+	return refspecs
+}
+
+// Filter comments to those on any of the commits
+func commentsOnHashes(comments CommentSlice, hashes []string) CommentSlice {
+	included := make(map[string]bool)
+	for _, hash := range hashes {
+		included[hash] = true
+	}
+	filtered := make(CommentSlice, 0)
+	for _, comment := range comments {
+		if included[*comment.Commit] {
+			filtered = append(filtered, comment)
+		}
+	}
+	return filtered
+}
+
+// Identifiers of remote comment versions which have been fetched
+func knownVersions(comments CommentSlice) map[string]bool {
+	known := make(map[string]bool)
+	for _, comment := range comments {
+		known[*comment.ID] = true
+	}
+	return known
+}
+
+// Find all comments fetched from a remote
+// @return result.Result<CommentSlice, error>
+func RemoteComments(repo *git.Repository, remoteName string) result.Result {
+	comments := make(CommentSlice, 0)
+	return gg.RemoteCommentRefIterator(repo, remoteName, func(ref *git.Reference) {
+		CommentFromRef(repo, ref.Name()).FlatMap(func(comment interface{}) result.Result {
+			comments = append(comments, comment.(*Comment))
+			return result.Result{}
+		})
+	}).FlatMap(func(value interface{}) result.Result {
+		sort.Stable(comments)
+		return result.NewSuccess(comments)
+	})
+}
+
+// Decide how to reconcile local and remote comments. Known identifiers
+// are the remote versions fetched by the previous synchronization, so a
+// version which is known has not changed remotely since then, and a
+// known version which is no longer on the remote was deleted there.
+func planRemoteSync(local, remote CommentSlice, known map[string]bool, policy ConflictPolicy) *remoteSyncPlan {
+	plan := &remoteSyncPlan{}
+	localVersions := groupVersions(local)
+	remoteVersions := groupVersions(remote)
+	keys := make([]string, 0, len(localVersions)+len(remoteVersions))
+	for key := range localVersions {
+		keys = append(keys, key)
+	}
+	for key := range remoteVersions {
+		if _, ok := localVersions[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		localComment := latestVersion(localVersions[key])
+		remoteComment := latestVersion(remoteVersions[key])
+		if remoteComment == nil && known[*localComment.ID] {
+			// Fetched previously and since removed from the remote
+			plan.removed = append(plan.removed, localComment)
+			plan.unlink = append(plan.unlink, localVersions[key]...)
+			continue
+		}
+		kept := localComment
+		if localComment == nil {
+			kept = remoteComment
+		} else if remoteComment != nil && *localComment.ID != *remoteComment.ID {
+			kept = resolveVersions(localComment, remoteComment, known, policy)
+			if !known[*remoteComment.ID] && !known[*localComment.ID] {
+				plan.conflicts = append(plan.conflicts, &CommentConflict{localComment, remoteComment, kept})
+			}
+		}
+		if !containsVersion(localVersions[key], kept) {
+			plan.merge = append(plan.merge, kept)
+		}
+		// A comment deleted locally with no versions on the remote, such
+		// as after pruning, has nothing to delete there
+		if !containsVersion(remoteVersions[key], kept) && (remoteComment != nil || !kept.Deleted) {
+			plan.push = append(plan.push, kept)
+		}
+		for _, comment := range localVersions[key] {
+			if *comment.ID != *kept.ID {
+				plan.unlink = append(plan.unlink, comment)
+			}
+		}
+		for _, comment := range remoteVersions[key] {
+			if *comment.ID != *kept.ID {
+				plan.prune = append(plan.prune, comment)
+			}
+		}
+	}
+	return plan
+}
+
+// Choose between differing local and remote versions of a comment
+func resolveVersions(local, remote *Comment, known map[string]bool, policy ConflictPolicy) *Comment {
+	if local.Deleted != remote.Deleted {
+		if local.Deleted {
+			return local
+		}
+		return remote
+	} else if known[*remote.ID] {
+		return local
+	} else if known[*local.ID] {
+		return remote
+	}
+	switch policy {
+	case PreferLocal:
+		return local
+	case PreferRemote:
+		return remote
+	}
+	return newerVersion(local, remote)
+}
+
+// Group versions of comments by the identifier of their first version
+func groupVersions(comments CommentSlice) map[string]CommentSlice {
+	versions := make(map[string]CommentSlice)
+	for _, comment := range comments {
+		key := comment.Identity()
+		versions[key] = append(versions[key], comment)
+	}
+	return versions
+}
+
+// Find the version of a comment which supersedes the others, preferring
+// deleted versions followed by the most recently amended
+func latestVersion(versions CommentSlice) *Comment {
+	var latest *Comment
+	for _, comment := range versions {
+		if latest == nil || (comment.Deleted && !latest.Deleted) {
+			latest = comment
+		} else if comment.Deleted == latest.Deleted {
+			latest = newerVersion(latest, comment)
+		}
+	}
+	return latest
+}
+
+// The more recently amended of two versions. Versions amended at the
+// same time are ordered by identifier, so every repository makes the
+// same choice.
+func newerVersion(a, b *Comment) *Comment {
+	if b.Amender.Date.After(a.Amender.Date) {
+		return b
+	} else if a.Amender.Date.Equal(b.Amender.Date) && *b.ID > *a.ID {
+		return b
+	}
+	return a
+}
+
+func containsVersion(versions CommentSlice, comment *Comment) bool {
+	for _, version := range versions {
+		if *version.ID == *comment.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// Push planned changes to the remote, then update local comments and
+// remote-tracking references if the remote accepted every change
+// @return result.Result<*RemoteSyncSummary, error>
+func applyRemoteSync(repo *git.Repository, repoPath, remoteName string, plan *remoteSyncPlan) result.Result {
+	refspecs := make([]string, 0, len(plan.push)+len(plan.prune))
+	for _, comment := range plan.push {
+		refPath, err := commentRefPath(comment).Dematerialize()
+		if err != nil {
+			return result.NewFailure(err)
+		}
+		refspecs = append(refspecs, fmt.Sprintf("%v:%v", refPath, refPath))
+	}
+	for _, comment := range plan.prune {
+		refPath, err := commentRefPath(comment).Dematerialize()
+		if err != nil {
+			return result.NewFailure(err)
+		}
+		refspecs = append(refspecs, fmt.Sprintf(":%v", refPath))
+	}
+	pushed := result.NewSuccess(map[string]string{})
+	if len(refspecs) > 0 {
+		pushed = pushRefspecs(repoPath, remoteName, refspecs)
+	}
+	return pushed.FlatMap(func(value interface{}) result.Result {
+		updated := append(append(CommentSlice{}, plan.push...), plan.prune...)
+		_, rejected := acceptedComments(updated, value.(map[string]string))
+		if len(rejected) > 0 {
+			return result.NewFailure(fmt.Errorf(rejectedSyncError, len(rejected), len(refspecs), rejectionMessages(rejected)))
+		}
+		if err := mergeRemoteComments(repo, remoteName, plan); err != nil {
+			return result.NewFailure(err)
+		}
+		for _, comment := range plan.push {
+			if err := createTrackingReference(repo, remoteName, comment); err != nil {
+				return result.NewFailure(err)
+			}
+		}
+		for _, comment := range plan.prune {
+			if err := deleteTrackingReference(repo, remoteName, comment); err != nil {
+				return result.NewFailure(err)
+			}
+		}
+		return result.NewSuccess(&RemoteSyncSummary{plan.merge, plan.push, plan.removed, plan.conflicts})
+	})
+}
+
+// Replace local comments with the remote versions chosen by a plan
+func mergeRemoteComments(repo *git.Repository, remoteName string, plan *remoteSyncPlan) error {
+	for _, comment := range plan.unlink {
+		if err := deleteReference(repo, comment, *comment.ID); err != nil {
+			return err
+		}
+	}
+	for _, comment := range plan.merge {
+		message := fmt.Sprintf(mergedMessageFormat, (*comment.ID)[:7], remoteName)
+		refPath, err := commentRefPath(comment).Dematerialize()
+		if err == nil {
+			err = createReference(repo, refPath.(string), *comment.ID, message)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Path of the reference to a comment. Fails if the comment does not
+// have a valid commit, such as a malformed comment fetched from a remote.
+// @return result.Result<string, error>
+func commentRefPath(comment *Comment) result.Result {
+	if comment.Commit == nil {
+		return result.NewFailure(fmt.Errorf(invalidCommitError, *comment.ID))
+	}
+	return RefPath(comment, *comment.ID).Analysis(func(refPath interface{}) result.Result {
+		return result.NewSuccess(refPath)
+	}, func(err error) result.Result {
+		return result.NewFailure(fmt.Errorf(invalidCommitError, *comment.ID))
+	})
+}
+
+// Path of the remote-tracking reference matching a comment reference
+func remoteTrackingPath(remoteName, refPath string) string {
+	return gg.RemoteCommentRefBase(remoteName) + strings.TrimPrefix(refPath, gg.CommentRefBase)
+}
+
+// Reference a comment pushed to a remote as a remote-tracking reference
+func createTrackingReference(repo *git.Repository, remoteName string, comment *Comment) error {
+	refPath, err := commentRefPath(comment).Dematerialize()
+	if err != nil {
+		return err
+	}
+	message := fmt.Sprintf(pushedMessageFormat, (*comment.ID)[:7], remoteName)
+	return createReference(repo, remoteTrackingPath(remoteName, refPath.(string)), *comment.ID, message)
+}
+
+// Delete the remote-tracking reference of a comment if it exists
+func deleteTrackingReference(repo *git.Repository, remoteName string, comment *Comment) error {
+	refPath, err := commentRefPath(comment).Dematerialize()
+	if err != nil {
+		return err
+	}
+	ref, err := repo.References.Lookup(remoteTrackingPath(remoteName, refPath.(string)))
+	if git.IsErrorCode(err, git.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return ref.Delete()
+}
+
+func createReference(repo *git.Repository, refPath, identifier, message string) error {
+	oid, err := git.NewOid(identifier)
+	if err == nil {
+		_, err = repo.References.Create(refPath, oid, true, message)
+	}
+	return err
+}
+
+// Local and remote versions of a comment with differing content
+type CommentDifference struct {
+	Local  *Comment
+	Remote *Comment
+}
+
+// Comparison of local comments with the comments of a remote
+type RemoteStatus struct {
+	// Comments which only exist locally
+	Unpushed CommentSlice
+	// Comments which only exist on the remote
+	Unmerged CommentSlice
+	// Comments which exist in both places with different content
+	Differing []*CommentDifference
+	// References on the remote to comments which have not been fetched
+	Unfetched []string
+}
+
+// Whether local and remote comments match
+func (s *RemoteStatus) UpToDate() bool {
+	return len(s.Unpushed)+len(s.Unmerged)+len(s.Differing)+len(s.Unfetched) == 0
+}
+
+// Compare local comments with the comments last fetched from a remote,
+// or with the comment references currently on the remote if live
+// @return result.Result<*RemoteStatus, error>
+func RemoteCommentStatus(repoPath, remoteName string, live bool) result.Result {
+	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
+		remote := RemoteComments(repo, remoteName)
+		unfetched := make([]string, 0)
+		if live {
+			remote = gg.ListRemoteRefs(repoPath, remoteName, gg.CommentRefBase+"/").FlatMap(func(refs interface{}) result.Result {
+				comments := make(CommentSlice, 0)
+				for refName, identifier := range refs.(map[string]string) {
+					if path.Base(refName) == versionRef {
+						continue
+					} else if comment := CommentByID(repo, identifier).Success; comment != nil {
+						comments = append(comments, comment.(*Comment))
+					} else {
+						unfetched = append(unfetched, refName)
+					}
+				}
+				sort.Stable(comments)
+				sort.Strings(unfetched)
+				return result.NewSuccess(comments)
+			})
+		}
+		return result.Combine(func(values ...interface{}) result.Result {
+			status := compareComments(values[0].(CommentSlice), values[1].(CommentSlice))
+			status.Unfetched = unfetched
+			return result.NewSuccess(status)
+		}, AllComments(repo), remote)
+	})
+}
+
+// Compare the latest versions of local and remote comments, matching
+// versions by the identifier of their first version
+func compareComments(local, remote CommentSlice) *RemoteStatus {
+	status := &RemoteStatus{Unpushed: CommentSlice{}, Unmerged: CommentSlice{}, Differing: []*CommentDifference{}}
+	localVersions := groupVersions(local)
+	remoteVersions := groupVersions(remote)
+	for _, comment := range local {
+		latest := latestVersion(localVersions[comment.Identity()])
+		if latest != comment {
+			continue
+		}
+		if other := latestVersion(remoteVersions[comment.Identity()]); other == nil {
+			status.Unpushed = append(status.Unpushed, comment)
+		} else if *other.ID != *comment.ID {
+			status.Differing = append(status.Differing, &CommentDifference{comment, other})
+		}
+	}
+	for _, comment := range remote {
+		if latestVersion(remoteVersions[comment.Identity()]) != comment {
+			continue
+		} else if _, ok := localVersions[comment.Identity()]; !ok {
+			status.Unmerged = append(status.Unmerged, comment)
+		}
+	}
+	return status
+}
@@ -0,0 +1,266 @@
+package libgitcomment
+
+import (
+	"github.com/stvp/assert"
+	"testing"
+	"time"
+)
+
+const syncedCommit = "0155eb4229851634a0f03eb265b69f5a2d56f341"
+
+var otherSyncedCommit = "95b2bd5cd7b6e96e2b8fb3a9aa1c4c1c7d3c9e41"
+
+// Identifier of the first version of synced comments
+var syncedOrigin = "a0"
+
+func syncedComment(id string, amended int64) *Comment {
+	author := &Person{"Selina Kyle", "cat@example.com", time.Unix(1437498360, 0), "+1100"}
+	c, _ := NewComment("Needs a test", syncedCommit, &FileRef{}, author).Dematerialize()
+	comment := c.(*Comment)
+	comment.ID = &id
+	comment.Origin = &syncedOrigin
+	comment.Amend("Needs a test: "+id, &Person{"Bruce Wayne", "bruce@example.com", time.Unix(amended, 0), "+1100"})
+	return comment
+}
+
+// A comment which is not a version of any other, by the same author at
+// the same time as synced comments
+func separateComment(id string) *Comment {
+	comment := syncedComment(id, 1437498360)
+	comment.Origin = nil
+	return comment
+}
+
+func TestPlanRemoteSyncMergesRemoteComments(t *testing.T) {
+	remote := syncedComment("a1", 1437498360)
+	plan := planRemoteSync(CommentSlice{}, CommentSlice{remote}, map[string]bool{}, PreferNewest)
+	assert.Equal(t, plan.merge, CommentSlice{remote})
+	assert.Equal(t, len(plan.push), 0)
+	assert.Equal(t, len(plan.conflicts), 0)
+}
+
+func TestPlanRemoteSyncPushesLocalComments(t *testing.T) {
+	local := syncedComment("a1", 1437498360)
+	plan := planRemoteSync(CommentSlice{local}, CommentSlice{}, map[string]bool{}, PreferNewest)
+	assert.Equal(t, plan.push, CommentSlice{local})
+	assert.Equal(t, len(plan.merge), 0)
+}
+
+func TestPlanRemoteSyncUnchanged(t *testing.T) {
+	local := syncedComment("a1", 1437498360)
+	remote := syncedComment("a1", 1437498360)
+	plan := planRemoteSync(CommentSlice{local}, CommentSlice{remote}, map[string]bool{"a1": true}, PreferNewest)
+	assert.Equal(t, len(plan.merge)+len(plan.push)+len(plan.unlink)+len(plan.prune), 0)
+}
+
+func TestPlanRemoteSyncDeletedRemotely(t *testing.T) {
+	first := syncedComment("a1", 1437498000)
+	latest := syncedComment("b2", 1437498360)
+	plan := planRemoteSync(CommentSlice{first, latest}, CommentSlice{}, map[string]bool{"b2": true}, PreferNewest)
+	assert.Equal(t, plan.removed, CommentSlice{latest})
+	assert.Equal(t, plan.unlink, CommentSlice{first, latest})
+	assert.Equal(t, len(plan.push)+len(plan.merge)+len(plan.prune), 0)
+}
+
+func TestPlanRemoteSyncPushesLocalAmendmentOfDeletedComment(t *testing.T) {
+	amended := syncedComment("b2", 1437498360)
+	plan := planRemoteSync(CommentSlice{amended}, CommentSlice{}, map[string]bool{"a1": true}, PreferNewest)
+	assert.Equal(t, plan.push, CommentSlice{amended})
+	assert.Equal(t, len(plan.removed)+len(plan.unlink), 0)
+}
+
+func TestPlanRemoteSyncLocalAmendment(t *testing.T) {
+	local := syncedComment("b2", 1437498000)
+	remote := syncedComment("a1", 1437498360)
+	plan := planRemoteSync(CommentSlice{local}, CommentSlice{remote}, map[string]bool{"a1": true}, PreferRemote)
+	assert.Equal(t, plan.push, CommentSlice{local})
+	assert.Equal(t, plan.prune, CommentSlice{remote})
+	assert.Equal(t, len(plan.conflicts), 0)
+}
+
+func TestPlanRemoteSyncRemoteAmendment(t *testing.T) {
+	local := syncedComment("a1", 1437498360)
+	remote := syncedComment("b2", 1437498000)
+	plan := planRemoteSync(CommentSlice{local}, CommentSlice{remote}, map[string]bool{"a1": true}, PreferLocal)
+	assert.Equal(t, plan.merge, CommentSlice{remote})
+	assert.Equal(t, plan.unlink, CommentSlice{local})
+	assert.Equal(t, len(plan.conflicts), 0)
+}
+
+func TestPlanRemoteSyncConflictPreferNewest(t *testing.T) {
+	local := syncedComment("a1", 1437498360)
+	remote := syncedComment("b2", 1437498999)
+	plan := planRemoteSync(CommentSlice{local}, CommentSlice{remote}, map[string]bool{}, PreferNewest)
+	assert.Equal(t, len(plan.conflicts), 1)
+	assert.Equal(t, plan.conflicts[0].Resolution, remote)
+	assert.Equal(t, plan.merge, CommentSlice{remote})
+	assert.Equal(t, plan.unlink, CommentSlice{local})
+}
+
+func TestPlanRemoteSyncConflictPreferLocal(t *testing.T) {
+	local := syncedComment("a1", 1437498360)
+	remote := syncedComment("b2", 1437498999)
+	plan := planRemoteSync(CommentSlice{local}, CommentSlice{remote}, map[string]bool{}, PreferLocal)
+	assert.Equal(t, plan.conflicts[0].Resolution, local)
+	assert.Equal(t, plan.push, CommentSlice{local})
+	assert.Equal(t, plan.prune, CommentSlice{remote})
+}
+
+func TestPlanRemoteSyncDeletionWins(t *testing.T) {
+	local := syncedComment("a1", 1437498000)
+	local.Deleted = true
+	remote := syncedComment("b2", 1437498999)
+	plan := planRemoteSync(CommentSlice{local}, CommentSlice{remote}, map[string]bool{}, PreferRemote)
+	assert.Equal(t, plan.conflicts[0].Resolution, local)
+	assert.Equal(t, plan.push, CommentSlice{local})
+}
+
+func TestPlanRemoteSyncKeepsSeparateComments(t *testing.T) {
+	first := separateComment("a1")
+	second := separateComment("b2")
+	plan := planRemoteSync(CommentSlice{first, second}, CommentSlice{}, map[string]bool{}, PreferNewest)
+	assert.Equal(t, plan.push, CommentSlice{first, second})
+	assert.Equal(t, len(plan.unlink)+len(plan.prune)+len(plan.conflicts), 0)
+	plan = planRemoteSync(CommentSlice{first}, CommentSlice{second}, map[string]bool{}, PreferNewest)
+	assert.Equal(t, plan.push, CommentSlice{first})
+	assert.Equal(t, plan.merge, CommentSlice{second})
+	assert.Equal(t, len(plan.unlink)+len(plan.prune)+len(plan.conflicts), 0)
+}
+
+func TestPlanRemoteSyncFetchKeepsSeparateLocalComments(t *testing.T) {
+	local := separateComment("a1")
+	fetched := separateComment("b2")
+	plan := planRemoteSync(CommentSlice{local}, CommentSlice{fetched}, map[string]bool{}, PreferLocal)
+	assert.Equal(t, plan.merge, CommentSlice{fetched})
+	assert.Equal(t, len(plan.unlink), 0)
+}
+
+func TestPlanRemoteSyncMatchesOrigin(t *testing.T) {
+	first := separateComment("a0")
+	amended := syncedComment("b2", 1437498999)
+	plan := planRemoteSync(CommentSlice{amended}, CommentSlice{first}, map[string]bool{"a0": true}, PreferNewest)
+	assert.Equal(t, plan.push, CommentSlice{amended})
+	assert.Equal(t, plan.prune, CommentSlice{first})
+	assert.Equal(t, len(plan.unlink), 0)
+}
+
+func TestLatestVersion(t *testing.T) {
+	older := syncedComment("a1", 1437498000)
+	newer := syncedComment("b2", 1437498999)
+	tied := syncedComment("c3", 1437498999)
+	assert.Equal(t, latestVersion(CommentSlice{newer, older}), newer)
+	assert.Equal(t, latestVersion(CommentSlice{tied, newer}), tied)
+	assert.Nil(t, latestVersion(CommentSlice{}))
+}
+
+func TestCompareComments(t *testing.T) {
+	shared := syncedComment("a1", 1437498360)
+	local := separateComment("b2")
+	local.Commit = &otherSyncedCommit
+	remote := separateComment("c3")
+	status := compareComments(CommentSlice{shared, local}, CommentSlice{shared, remote})
+	assert.Equal(t, status.Unpushed, CommentSlice{local})
+	assert.Equal(t, status.Unmerged, CommentSlice{remote})
+	assert.Equal(t, len(status.Differing), 0)
+	assert.False(t, status.UpToDate())
+}
+
+func TestCompareCommentsDiffering(t *testing.T) {
+	older := syncedComment("a1", 1437498000)
+	newer := syncedComment("b2", 1437498360)
+	status := compareComments(CommentSlice{newer}, CommentSlice{older, newer})
+	assert.True(t, status.UpToDate())
+	status = compareComments(CommentSlice{older}, CommentSlice{newer})
+	assert.Equal(t, len(status.Differing), 1)
+	assert.Equal(t, *status.Differing[0], CommentDifference{older, newer})
+}
+
+func TestCompareCommentsSeparateComments(t *testing.T) {
+	first := separateComment("a1")
+	second := separateComment("b2")
+	status := compareComments(CommentSlice{first}, CommentSlice{second})
+	assert.Equal(t, status.Unpushed, CommentSlice{first})
+	assert.Equal(t, status.Unmerged, CommentSlice{second})
+	assert.Equal(t, len(status.Differing), 0)
+}
+
+func TestPrunableVersions(t *testing.T) {
+	remote := syncedComment("a1", 1437498000)
+	tombstone := syncedComment("b2", 1437498360)
+	tombstone.Deleted = true
+	other := separateComment("c3")
+	other.Commit = &otherSyncedCommit
+	prunable := prunableVersions(CommentSlice{tombstone, other}, CommentSlice{remote, tombstone, other})
+	assert.Equal(t, prunable, CommentSlice{remote})
+}
+
+func TestPlanRemoteSyncAfterPrune(t *testing.T) {
+	remote := syncedComment("a1", 1437498000)
+	tombstone := syncedComment("b2", 1437498360)
+	tombstone.Deleted = true
+	assert.Equal(t, prunableVersions(CommentSlice{tombstone}, CommentSlice{remote}), CommentSlice{remote})
+	plan := planRemoteSync(CommentSlice{tombstone}, CommentSlice{}, map[string]bool{}, PreferNewest)
+	assert.Equal(t, len(plan.push)+len(plan.merge)+len(plan.unlink)+len(plan.prune)+len(plan.removed), 0)
+}
+
+func TestUnpushedDeletions(t *testing.T) {
+	first := syncedComment("a1", 1437498000)
+	amended := syncedComment("b2", 1437498100)
+	tombstone := syncedComment("c3", 1437498360)
+	tombstone.Deleted = true
+	prunable := prunableVersions(CommentSlice{tombstone}, CommentSlice{first, amended})
+	assert.Equal(t, unpushedDeletions(CommentSlice{tombstone}, CommentSlice{first, amended}, prunable), CommentSlice{tombstone})
+	remote := CommentSlice{first, tombstone}
+	prunable = prunableVersions(CommentSlice{tombstone}, remote)
+	assert.Equal(t, len(unpushedDeletions(CommentSlice{tombstone}, remote, prunable)), 0)
+}
+
+func TestPrunableVersionsSeparateComments(t *testing.T) {
+	deleted := separateComment("a1")
+	deleted.Deleted = true
+	other := separateComment("b2")
+	assert.Equal(t, len(prunableVersions(CommentSlice{deleted}, CommentSlice{deleted, other})), 0)
+}
+
+func TestPrunableVersionsWithoutDeletions(t *testing.T) {
+	remote := syncedComment("a1", 1437498000)
+	assert.Equal(t, len(prunableVersions(CommentSlice{remote}, CommentSlice{remote})), 0)
+}
+
+func TestCommitFetchRefspecs(t *testing.T) {
+	refspecs := commitFetchRefspecs("origin", []string{syncedCommit, syncedCommit, "abc"})
+	assert.Equal(t, refspecs, []string{
+		"+refs/comments/0155/eb4229851634a0f03eb265b69f5a2d56f341/*:refs/remotes/origin/comments/0155/eb4229851634a0f03eb265b69f5a2d56f341/*",
+	})
+}
+
+func TestCommentsOnHashes(t *testing.T) {
+	comment := syncedComment("a1", 1437498000)
+	other := syncedComment("b2", 1437498000)
+	other.Commit = &otherSyncedCommit
+	assert.Equal(t, commentsOnHashes(CommentSlice{comment, other}, []string{otherSyncedCommit}), CommentSlice{other})
+}
+
+func TestCommentRefPath(t *testing.T) {
+	comment := syncedComment("a1", 1437498360)
+	refPath, err := commentRefPath(comment).Dematerialize()
+	assert.Nil(t, err)
+	assert.Equal(t, refPath, "refs/comments/0155/eb4229851634a0f03eb265b69f5a2d56f341/a1")
+}
+
+func TestCommentRefPathInvalidCommit(t *testing.T) {
+	comment := syncedComment("a1", 1437498360)
+	short := "0155"
+	comment.Commit = &short
+	assert.NotNil(t, commentRefPath(comment).Failure)
+	comment.Commit = nil
+	assert.NotNil(t, commentRefPath(comment).Failure)
+}
+
+func TestAcceptedComments(t *testing.T) {
+	accepted, other := syncedComment("a1", 1437498360), separateComment("b2")
+	refPath, _ := commentRefPath(other).Dematerialize()
+	pushed, rejected := acceptedComments(CommentSlice{accepted, other}, map[string]string{refPath.(string): "hook declined"})
+	assert.Equal(t, pushed, CommentSlice{accepted})
+	assert.Equal(t, rejected, []*RejectedComment{&RejectedComment{other, "hook declined"}})
+}
@@ -0,0 +1,97 @@
+package libgitcomment
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	requestFailedError = "%v %v failed: %v %v"
+	jsonContentType    = "application/json"
+)
+
+var nextPageRe = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// Minimal client for JSON REST APIs of code review services
+type restClient struct {
+	baseURL string
+	header  http.Header
+	client  *http.Client
+}
+
+func newRestClient(baseURL string, header http.Header) *restClient {
+	return &restClient{strings.TrimRight(baseURL, "/"), header, http.DefaultClient}
+}
+
+// Perform a request, encoding the body as JSON and decoding the response
+// into the value, if provided. Returns the URL of the next page of
+// results if the response is paginated.
+func (c *restClient) request(method, path string, body, value interface{}) (string, error) {
+	var content io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return "", err
+		}
+		content = bytes.NewReader(data)
+	}
+	request, err := http.NewRequest(method, c.url(path), content)
+	if err != nil {
+		return "", err
+	}
+	for name, values := range c.header {
+		request.Header[name] = values
+	}
+	request.Header.Set("Accept", jsonContentType)
+	if body != nil {
+		request.Header.Set("Content-Type", jsonContentType)
+	}
+	response, err := c.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusMultipleChoices {
+		message, _ := ioutil.ReadAll(response.Body)
+		return "", fmt.Errorf(requestFailedError, method, path, response.Status, strings.TrimSpace(string(message)))
+	}
+	if value != nil {
+		if err := json.NewDecoder(response.Body).Decode(value); err != nil {
+			return "", err
+		}
+	}
+	return nextPage(response.Header.Get("Link")), nil
+}
+
+// Fetch each page of a paginated list, invoking a function to
+// allocate the value to decode each page into
+func (c *restClient) requestPages(path string, page func() interface{}) error {
+	for len(path) > 0 {
+		next, err := c.request("GET", path, nil, page())
+		if err != nil {
+			return err
+		}
+		path = next
+	}
+	return nil
+}
+
+func (c *restClient) url(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	return c.baseURL + path
+}
+
+func nextPage(link string) string {
+	if match := nextPageRe.FindStringSubmatch(link); len(match) > 1 {
+		return match[1]
+	}
+	return ""
+}
@@ -0,0 +1,23 @@
+package libgitcomment
+
+import (
+	"github.com/stvp/assert"
+	"testing"
+)
+
+func TestNextPage(t *testing.T) {
+	link := `<https://api.example.com/items?page=3>; rel="next", <https://api.example.com/items?page=9>; rel="last"`
+	assert.Equal(t, nextPage(link), "https://api.example.com/items?page=3")
+}
+
+func TestNextPageLast(t *testing.T) {
+	link := `<https://api.example.com/items?page=1>; rel="first", <https://api.example.com/items?page=8>; rel="prev"`
+	assert.Equal(t, nextPage(link), "")
+	assert.Equal(t, nextPage(""), "")
+}
+
+func TestRestClientURL(t *testing.T) {
+	client := newRestClient("https://api.example.com/", nil)
+	assert.Equal(t, client.url("/items"), "https://api.example.com/items")
+	assert.Equal(t, client.url("https://other.example.com/items"), "https://other.example.com/items")
+}
@@ -0,0 +1,285 @@
+package libgitcomment
+
+import (
+	"errors"
+	gx "exec"
+	"fmt"
+	gg "git"
+	"github.com/kylef/result.go/src/result"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type SignatureStatus string
+
+// Signature verification states, using the same letters as the `%G?`
+// placeholder of `git log`
+const (
+	SignatureGood            SignatureStatus = "G"
+	SignatureBad             SignatureStatus = "B"
+	SignatureUnknownValidity SignatureStatus = "U"
+	SignatureExpired         SignatureStatus = "X"
+	SignatureExpiredKey      SignatureStatus = "Y"
+	SignatureRevokedKey      SignatureStatus = "R"
+	SignatureCannotCheck     SignatureStatus = "E"
+	SignatureNone            SignatureStatus = "N"
+)
+
+const (
+	gpgProgramConfig      = "gpg.program"
+	gpgFormatConfig       = "gpg.format"
+	sshProgramConfig      = "gpg.ssh.program"
+	allowedSignersConfig  = "gpg.ssh.allowedSignersFile"
+	signingKeyConfig      = "user.signingKey"
+	sshFormat             = "ssh"
+	defaultGPGProgram     = "gpg"
+	defaultSSHProgram     = "ssh-keygen"
+	sshNamespace          = "git"
+	literalKeyPrefix      = "key::"
+	sshKeyPrefix          = "ssh-"
+	gpgStatusPrefix       = "[GNUPG:] "
+	gpgSignatureBegin     = "-----BEGIN PGP SIGNATURE-----"
+	sshSignatureBegin     = "-----BEGIN SSH SIGNATURE-----"
+	sshGoodSignature      = "Good \"git\" signature"
+	signingFailedError    = "Failed to sign the comment: %v"
+	unknownSignatureError = "Unknown signature format"
+	missingSSHKeyError    = "user.signingKey needs to be set for ssh signing"
+	missingSignersError   = "gpg.ssh.allowedSignersFile needs to be configured and exist for ssh signature verification"
+)
+
+// Creates and checks detached signatures over comment content
+type Signer interface {
+	// Sign content
+	// @return result.Result<string, error>
+	Sign(content string) result.Result
+	// Check a signature of content
+	// @return result.Result<*Verification, error>
+	Verify(content, signature string) result.Result
+}
+
+// The outcome of checking a comment signature
+type Verification struct {
+	Status SignatureStatus
+	// Name of the signer, if known
+	Signer string
+	// Identifier of the signing key, if known
+	Key string
+	// Human-readable output of the verifying program
+	Output string
+}
+
+// Signs content with OpenPGP using gpg or a compatible program
+type GPGSigner struct {
+	Program string
+	Key     string
+}
+
+// Signs content with an SSH key using ssh-keygen, checking signatures
+// against a file of allowed signers
+type SSHSigner struct {
+	Program string
+	// Path of a private or public key, or a literal public key held by
+	// ssh-agent
+	Key string
+	// Path of the allowed signers file, in the format described by
+	// ssh-keygen(1)
+	AllowedSigners string
+}
+
+// Create a signer using the configured signing program and format. The
+// key defaults to `user.signingKey`, followed by the committer identity
+// when signing with GPG.
+// @return result.Result<Signer, error>
+func ConfiguredSigner(repoPath, key string) result.Result {
+	if len(key) == 0 {
+		key = gg.ConfiguredString(repoPath, signingKeyConfig, "")
+	}
+	if gg.ConfiguredString(repoPath, gpgFormatConfig, "") == sshFormat {
+		if len(key) == 0 {
+			return result.NewFailure(errors.New(missingSSHKeyError))
+		}
+		return result.NewSuccess(configuredSSHSigner(repoPath, key))
+	}
+	program := gg.ConfiguredString(repoPath, gpgProgramConfig, defaultGPGProgram)
+	if len(key) > 0 {
+		return result.NewSuccess(&GPGSigner{program, key})
+	}
+	return configuredCommitter(repoPath).Analysis(func(committer interface{}) result.Result {
+		person := committer.(*Person)
+		return result.NewSuccess(&GPGSigner{program, fmt.Sprintf("%v <%v>", person.Name, person.Email)})
+	}, func(err error) result.Result {
+		return result.NewFailure(errors.New(noCommitterError))
+	})
+}
+
+// Create a signer able to check a signature
+// @return result.Result<Signer, error>
+func configuredVerifier(repoPath, signature string) result.Result {
+	if strings.HasPrefix(signature, gpgSignatureBegin) {
+		return result.NewSuccess(&GPGSigner{Program: gg.ConfiguredString(repoPath, gpgProgramConfig, defaultGPGProgram)})
+	} else if strings.HasPrefix(signature, sshSignatureBegin) {
+		return result.NewSuccess(configuredSSHSigner(repoPath, ""))
+	}
+	return result.NewFailure(errors.New(unknownSignatureError))
+}
+
+func configuredSSHSigner(repoPath, key string) *SSHSigner {
+	program := gg.ConfiguredString(repoPath, sshProgramConfig, defaultSSHProgram)
+	allowedSigners := gg.ConfiguredString(repoPath, allowedSignersConfig, "")
+	return &SSHSigner{program, expandHome(key), expandHome(allowedSigners)}
+}
+
+// Create a detached, armored signature in the same way as `git tag -s`
+// @return result.Result<string, error>
+func (g *GPGSigner) Sign(content string) result.Result {
+	signature, status, err := gx.CaptureCommand(content, g.Program, "--status-fd=2", "-bsau", g.Key)
+	if err != nil || !strings.Contains(status, gpgStatusPrefix+"SIG_CREATED ") {
+		return result.NewFailure(fmt.Errorf(signingFailedError, strings.TrimSpace(status)))
+	}
+	return result.NewSuccess(strings.TrimRight(signature, "\n"))
+}
+
+// @return result.Result<*Verification, error>
+func (g *GPGSigner) Verify(content, signature string) result.Result {
+	file, err := writeTempFile(signature + "\n")
+	if err != nil {
+		return result.NewFailure(err)
+	}
+	defer os.Remove(file)
+	status, output, _ := gx.CaptureCommand(content, g.Program, "--status-fd=1", "--keyid-format=long", "--verify", file, "-")
+	verification := parseGPGStatus(status)
+	verification.Output = output
+	return result.NewSuccess(verification)
+}
+
+// Create a signature in the same way as `git commit -S` when
+// `gpg.format` is ssh
+// @return result.Result<string, error>
+func (s *SSHSigner) Sign(content string) result.Result {
+	args := []string{"-Y", "sign", "-n", sshNamespace}
+	if isLiteralSSHKey(s.Key) {
+		file, err := writeTempFile(strings.TrimPrefix(s.Key, literalKeyPrefix))
+		if err != nil {
+			return result.NewFailure(err)
+		}
+		defer os.Remove(file)
+		args = append(args, "-f", file, "-U")
+	} else {
+		args = append(args, "-f", s.Key)
+	}
+	signature, output, err := gx.CaptureCommand(content, s.Program, args...)
+	if err != nil || !strings.HasPrefix(signature, sshSignatureBegin) {
+		return result.NewFailure(fmt.Errorf(signingFailedError, strings.TrimSpace(output)))
+	}
+	return result.NewSuccess(strings.TrimRight(signature, "\n"))
+}
+
+// Check a signature against the allowed signers file. Signatures by
+// keys which are not allowed signers are reported as having unknown
+// validity if they are otherwise correct.
+// @return result.Result<*Verification, error>
+func (s *SSHSigner) Verify(content, signature string) result.Result {
+	if _, err := os.Stat(s.AllowedSigners); len(s.AllowedSigners) == 0 || err != nil {
+		return result.NewSuccess(&Verification{Status: SignatureCannotCheck, Output: missingSignersError + "\n"})
+	}
+	file, err := writeTempFile(signature + "\n")
+	if err != nil {
+		return result.NewFailure(err)
+	}
+	defer os.Remove(file)
+	principals, _, err := gx.CaptureCommand("", s.Program, "-Y", "find-principals", "-f", s.AllowedSigners, "-s", file)
+	principal := strings.TrimSpace(strings.Split(principals, "\n")[0])
+	if err != nil || len(principal) == 0 {
+		output, stderr, err := gx.CaptureCommand(content, s.Program, "-Y", "check-novalidate", "-n", sshNamespace, "-s", file)
+		return result.NewSuccess(parseSSHVerification(output+stderr, "", err == nil, SignatureUnknownValidity))
+	}
+	output, stderr, err := gx.CaptureCommand(content, s.Program, "-Y", "verify", "-n", sshNamespace, "-f", s.AllowedSigners, "-I", principal, "-s", file)
+	return result.NewSuccess(parseSSHVerification(output+stderr, principal, err == nil, SignatureGood))
+}
+
+// Interpret the output of ssh-keygen, which reports a good signature
+// along with the key fingerprint
+func parseSSHVerification(output, principal string, succeeded bool, good SignatureStatus) *Verification {
+	verification := &Verification{Status: SignatureBad, Signer: principal, Output: output}
+	if succeeded && strings.HasPrefix(output, sshGoodSignature) {
+		verification.Status = good
+		fields := strings.Fields(output)
+		for index, field := range fields {
+			if field == "key" && index+1 < len(fields) {
+				verification.Key = fields[index+1]
+			}
+		}
+	}
+	return verification
+}
+
+func isLiteralSSHKey(key string) bool {
+	return strings.HasPrefix(key, literalKeyPrefix) || strings.HasPrefix(key, sshKeyPrefix)
+}
+
+// Expand a path relative to the home directory
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		return filepath.Join(os.Getenv("HOME"), path[2:])
+	}
+	return path
+}
+
+// Write content to a new temporary file, returning its path
+func writeTempFile(content string) (string, error) {
+	file, err := ioutil.TempFile("", "git-comment-signature")
+	if err != nil {
+		return "", err
+	}
+	_, err = file.WriteString(content)
+	file.Close()
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// Interpret the machine-readable status output of gpg
+func parseGPGStatus(status string) *Verification {
+	verification := &Verification{Status: SignatureCannotCheck}
+	for _, line := range strings.Split(status, "\n") {
+		if !strings.HasPrefix(line, gpgStatusPrefix) {
+			continue
+		}
+		fields := strings.Fields(line[len(gpgStatusPrefix):])
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "GOODSIG":
+			verification.Status = SignatureUnknownValidity
+		case "BADSIG":
+			verification.Status = SignatureBad
+		case "EXPSIG":
+			verification.Status = SignatureExpired
+		case "EXPKEYSIG":
+			verification.Status = SignatureExpiredKey
+		case "REVKEYSIG":
+			verification.Status = SignatureRevokedKey
+		case "ERRSIG":
+			verification.Status = SignatureCannotCheck
+		case "TRUST_MARGINAL", "TRUST_FULLY", "TRUST_ULTIMATE":
+			if verification.Status == SignatureUnknownValidity {
+				verification.Status = SignatureGood
+			}
+			continue
+		default:
+			continue
+		}
+		if len(fields) > 1 {
+			verification.Key = fields[1]
+		}
+		if len(fields) > 2 {
+			verification.Signer = strings.Join(fields[2:], " ")
+		}
+	}
+	return verification
+}
@@ -0,0 +1,136 @@
+package libgitcomment
+
+import (
+	"github.com/kylef/result.go/src/result"
+	"github.com/stvp/assert"
+	"testing"
+	"time"
+)
+
+type fakeSigner struct {
+	signed string
+}
+
+func (f *fakeSigner) Sign(content string) result.Result {
+	f.signed = content
+	return result.NewSuccess("-----BEGIN PGP SIGNATURE-----\n\nc2lnbmVk\n-----END PGP SIGNATURE-----")
+}
+
+func (f *fakeSigner) Verify(content, signature string) result.Result {
+	if content == f.signed {
+		return result.NewSuccess(&Verification{Status: SignatureGood})
+	}
+	return result.NewSuccess(&Verification{Status: SignatureBad})
+}
+
+func signedComment(signer Signer) *Comment {
+	author := &Person{"Selina Kyle", "cat@example.com", time.Unix(1437498360, 0), "+1100"}
+	c, _ := NewComment("Needs a test", "acdacdacd", new(FileRef), author).Dematerialize()
+	comment := c.(*Comment)
+	comment.Sign(signer)
+	return comment
+}
+
+func TestSignComment(t *testing.T) {
+	signer := &fakeSigner{}
+	comment := signedComment(signer)
+	assert.NotNil(t, comment.Signature)
+	verification, err := comment.Verify(signer).Dematerialize()
+	assert.Nil(t, err)
+	assert.Equal(t, verification.(*Verification).Status, SignatureGood)
+}
+
+func TestSignedContentExcludesSignature(t *testing.T) {
+	signer := &fakeSigner{}
+	comment := signedComment(signer)
+	deserialized, _ := DeserializeComment(comment.Serialize()).Dematerialize()
+	assert.Equal(t, *deserialized.(*Comment).Signature, *comment.Signature)
+	assert.Equal(t, deserialized.(*Comment).SignedContent(), signer.signed)
+}
+
+func TestSignedContentExcludesExternalIDs(t *testing.T) {
+	signer := &fakeSigner{}
+	comment := signedComment(signer)
+	comment.SetExternalID("github", "1734")
+	verification, _ := comment.Verify(signer).Dematerialize()
+	assert.Equal(t, verification.(*Verification).Status, SignatureGood)
+}
+
+func TestVerifyChangedComment(t *testing.T) {
+	signer := &fakeSigner{}
+	comment := signedComment(signer)
+	comment.Content = "Needs two tests"
+	verification, _ := comment.Verify(signer).Dematerialize()
+	assert.Equal(t, verification.(*Verification).Status, SignatureBad)
+}
+
+func TestAmendRemovesSignature(t *testing.T) {
+	comment := signedComment(&fakeSigner{})
+	comment.Amend("Needs two tests", comment.Author)
+	assert.Nil(t, comment.Signature)
+	verification, _ := comment.Verify(&fakeSigner{}).Dematerialize()
+	assert.Equal(t, verification.(*Verification).Status, SignatureNone)
+}
+
+func TestParseGPGStatusGood(t *testing.T) {
+	verification := parseGPGStatus(`[GNUPG:] NEWSIG
+[GNUPG:] KEY_CONSIDERED 4AEE18F83AFDEB23 0
+[GNUPG:] GOODSIG 4AEE18F83AFDEB23 Selina Kyle <cat@example.com>
+[GNUPG:] VALIDSIG 4AEE18F83AFDEB23 2015-07-21 1437498360 0 4 0 1 10 00 4AEE18F83AFDEB23
+[GNUPG:] TRUST_ULTIMATE 0 pgp
+`)
+	assert.Equal(t, verification.Status, SignatureGood)
+	assert.Equal(t, verification.Key, "4AEE18F83AFDEB23")
+	assert.Equal(t, verification.Signer, "Selina Kyle <cat@example.com>")
+}
+
+func TestParseGPGStatusUntrusted(t *testing.T) {
+	verification := parseGPGStatus("[GNUPG:] GOODSIG 4AEE18F83AFDEB23 Selina Kyle <cat@example.com>\n[GNUPG:] TRUST_UNDEFINED 0 pgp\n")
+	assert.Equal(t, verification.Status, SignatureUnknownValidity)
+}
+
+func TestParseGPGStatusBad(t *testing.T) {
+	verification := parseGPGStatus("[GNUPG:] BADSIG 4AEE18F83AFDEB23 Selina Kyle <cat@example.com>\n")
+	assert.Equal(t, verification.Status, SignatureBad)
+}
+
+func TestParseGPGStatusMissingKey(t *testing.T) {
+	verification := parseGPGStatus("[GNUPG:] ERRSIG 4AEE18F83AFDEB23 1 10 00 1437498360 9 -\n[GNUPG:] NO_PUBKEY 4AEE18F83AFDEB23\n")
+	assert.Equal(t, verification.Status, SignatureCannotCheck)
+	assert.Equal(t, verification.Key, "4AEE18F83AFDEB23")
+}
+
+func TestParseSSHVerificationGood(t *testing.T) {
+	output := "Good \"git\" signature for cat@example.com with ED25519 key SHA256:QWam7h5HJ67nTzi2uyXrmd15seEPaCvKR2ua52QqU1I\n"
+	verification := parseSSHVerification(output, "cat@example.com", true, SignatureGood)
+	assert.Equal(t, verification.Status, SignatureGood)
+	assert.Equal(t, verification.Signer, "cat@example.com")
+	assert.Equal(t, verification.Key, "SHA256:QWam7h5HJ67nTzi2uyXrmd15seEPaCvKR2ua52QqU1I")
+}
+
+func TestParseSSHVerificationUnknownSigner(t *testing.T) {
+	output := "Good \"git\" signature with ED25519 key SHA256:7g/46+FyFzgikYAbkQsrkB/J8qByapfoJPuKpWPmaJU\n"
+	verification := parseSSHVerification(output, "", true, SignatureUnknownValidity)
+	assert.Equal(t, verification.Status, SignatureUnknownValidity)
+	assert.Equal(t, verification.Key, "SHA256:7g/46+FyFzgikYAbkQsrkB/J8qByapfoJPuKpWPmaJU")
+}
+
+func TestParseSSHVerificationBad(t *testing.T) {
+	output := "Could not verify signature.\nSignature verification failed: incorrect signature\n"
+	verification := parseSSHVerification(output, "cat@example.com", false, SignatureGood)
+	assert.Equal(t, verification.Status, SignatureBad)
+	assert.Equal(t, verification.Key, "")
+}
+
+func TestLiteralSSHKey(t *testing.T) {
+	assert.True(t, isLiteralSSHKey("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIJ4rdBVP"))
+	assert.True(t, isLiteralSSHKey("key::ecdsa-sha2-nistp256 AAAAE2VjZHNh"))
+	assert.False(t, isLiteralSSHKey("/home/selina/.ssh/id_ed25519"))
+}
+
+func TestSSHVerifyWithoutAllowedSigners(t *testing.T) {
+	signer := &SSHSigner{"ssh-keygen", "", ""}
+	verification, err := signer.Verify("content", "-----BEGIN SSH SIGNATURE-----").Dematerialize()
+	assert.Nil(t, err)
+	assert.Equal(t, verification.(*Verification).Status, SignatureCannotCheck)
+}
@@ -10,19 +10,35 @@ import (
 )
 
 const (
-	CommentStorageDir    = ".git/comments"
+	commentStorageDir    = "comments"
 	maxCommentsOnCommit  = 4096
 	defaultMessageFormat = "Created a comment ref on [%v] to [%v]"
 	maxCommentError      = "Maximum comments on [%v] reached."
 )
 
-// Create a new comment on a commit, optionally with a file and line
+// Find the directory holding comment data kept outside of refs, such
+// as the search index, which is shared by every working tree
+// @return result.Result<string, error>
+func CommentStoragePath(repoPath string) result.Result {
+	return gg.CommonDir(repoPath).FlatMap(func(dir interface{}) result.Result {
+		return result.NewSuccess(path.Join(dir.(string), commentStorageDir))
+	})
+}
+
+// Create a new comment on a commit, optionally with a file and line and
+// the language of its content. The comment is signed if a signer is
+// provided.
 // @return result.Result<*string, error>
-func CreateComment(repoPath, commit, author, message string, fileRef *FileRef) result.Result {
+func CreateComment(repoPath, commit, author, message, language string, fileRef *FileRef, signer Signer) result.Result {
 	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
 		return validatedCommitForComment(repo, commit).FlatMap(func(hash interface{}) result.Result {
 			return commentAuthor(repoPath, author).FlatMap(func(author interface{}) result.Result {
 				return NewComment(message, *(hash).(*string), fileRef, author.(*Person))
+			}).FlatMap(func(value interface{}) result.Result {
+				if len(language) > 0 {
+					value.(*Comment).Language = &language
+				}
+				return signComment(value.(*Comment), signer)
 			}).FlatMap(func(value interface{}) result.Result {
 				comment := value.(*Comment)
 				success := writeCommentToDisk(repo, comment)
@@ -34,14 +50,17 @@ func CreateComment(repoPath, commit, author, message string, fileRef *FileRef) r
 	})
 }
 
-// Update an existing comment with a new message
+// Update an existing comment with a new message. The comment is
+// signed if a signer is provided.
 // @return result.Result<*Comment, error>
-func UpdateComment(repoPath, identifier, committer, message string) result.Result {
+func UpdateComment(repoPath, identifier, committer, message string, signer Signer) result.Result {
 	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
 		return CommentByID(repo, identifier).FlatMap(func(c interface{}) result.Result {
 			comment := c.(*Comment)
 			return commentCommitter(repoPath, committer).FlatMap(func(committer interface{}) result.Result {
 				comment.Amend(message, committer.(*Person))
+				return signComment(comment, signer)
+			}).FlatMap(func(value interface{}) result.Result {
 				return writeCommentToDisk(repo, comment)
 			})
 		})
@@ -55,11 +74,34 @@ func DeleteComment(repoPath string, identifier string) result.Result {
 		return CommentByID(repo, identifier).FlatMap(func(c interface{}) result.Result {
 			comment := c.(*Comment)
 			comment.Deleted = true
+			comment.Signature = nil
 			return writeCommentToDisk(repo, comment)
 		})
 	})
 }
 
+// Check the signature of a comment
+// @return result.Result<*Verification, error>
+func VerifyComment(repoPath, identifier string) result.Result {
+	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
+		return CommentByID(repo, identifier).FlatMap(func(c interface{}) result.Result {
+			return VerifyCommentSignature(repoPath, c.(*Comment))
+		})
+	})
+}
+
+// Check the signature of a comment using the program configured for
+// the format of the signature
+// @return result.Result<*Verification, error>
+func VerifyCommentSignature(repoPath string, comment *Comment) result.Result {
+	if comment.Signature == nil {
+		return result.NewSuccess(&Verification{Status: SignatureNone})
+	}
+	return configuredVerifier(repoPath, *comment.Signature).FlatMap(func(signer interface{}) result.Result {
+		return comment.Verify(signer.(Signer))
+	})
+}
+
 // Generate the path within refs for a given comment
 //
 // Comment refs are nested under refs/comments. The
@@ -76,6 +118,15 @@ func RefPath(comment *Comment, identifier string) result.Result {
 	})
 }
 
+// Sign a comment if a signer is provided
+// @return result.Result<*Comment, error>
+func signComment(comment *Comment, signer Signer) result.Result {
+	if signer == nil {
+		return result.NewSuccess(comment)
+	}
+	return comment.Sign(signer)
+}
+
 // Determine author for comment preferring the author string if
 // available.
 // @return result.Result<*Person, error>
@@ -83,7 +134,7 @@ func commentAuthor(repoPath, author string) result.Result {
 	if len(author) > 0 {
 		return CreatePerson(author)
 	}
-	return CreatePerson(gg.ConfiguredAuthor(repoPath))
+	return configuredAuthor(repoPath)
 }
 
 // Determine committer for comment preferring the committer string if
@@ -93,14 +144,19 @@ func commentCommitter(repoPath, committer string) result.Result {
 	if len(committer) > 0 {
 		return CreatePerson(committer)
 	}
-	return CreatePerson(gg.ConfiguredCommitter(repoPath))
+	return configuredCommitter(repoPath)
 }
 
 // Write git object for a given comment and update the
-// comment refs
+// comment refs. A new version of an existing comment records the
+// identifier of the first version as its origin.
 // @return result.Result<*Comment, error>
 func writeCommentToDisk(repo *git.Repository, comment *Comment) result.Result {
 	if comment.ID != nil {
+		if comment.Origin == nil {
+			origin := *comment.ID
+			comment.Origin = &origin
+		}
 		if err := deleteReference(repo, comment, *comment.ID); err != nil {
 			return result.NewFailure(err)
 		}
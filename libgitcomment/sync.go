@@ -0,0 +1,152 @@
+package libgitcomment
+
+import (
+	gg "git"
+	"github.com/kylef/result.go/src/result"
+	git "gopkg.in/libgit2/git2go.v23"
+	"sort"
+)
+
+// A code review service which comments can be synchronized with
+type ReviewService interface {
+	// Name used to record the identifiers of synchronized comments
+	Name() string
+	// Comments within the service
+	// @return result.Result<[]*ImportedComment, error>
+	Comments() result.Result
+	// Hashes of commits which comments can be created on
+	// @return result.Result<[]string, error>
+	Commits() result.Result
+	// Whether a comment can be represented within the service
+	Supports(comment *Comment) bool
+	// Create a comment within the service, optionally as a reply
+	// @return result.Result<string, error>
+	CreateComment(comment *Comment, inReplyTo string) result.Result
+	// Replace the content of an existing comment
+	// @return result.Result<bool, error>
+	UpdateComment(identifier string, comment *Comment) result.Result
+}
+
+// Outcome of synchronizing comments with a review service
+type SyncSummary struct {
+	Fetched       []*Comment
+	UpdatedLocal  []*Comment
+	Pushed        []*Comment
+	UpdatedRemote []*Comment
+}
+
+// Synchronize comments with a review service. New comments in the service
+// are imported, and new local comments on commits known to the service
+// are pushed. Comments which exist in both places are compared using the
+// identifiers recorded when they were last synchronized, and the most
+// recently amended version replaces the other.
+// @return result.Result<*SyncSummary, error>
+func SyncComments(repoPath string, service ReviewService) result.Result {
+	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
+		return result.Combine(func(values ...interface{}) result.Result {
+			remote := values[0].([]*ImportedComment)
+			commits := values[1].([]string)
+			local := values[2].(CommentSlice)
+			summary := &SyncSummary{}
+			return syncRemoteComments(repo, service, remote, local, summary).FlatMap(func(value interface{}) result.Result {
+				return pushLocalComments(repo, service, commits, local, summary)
+			}).FlatMap(func(value interface{}) result.Result {
+				return result.NewSuccess(summary)
+			})
+		}, service.Comments(), service.Commits(), AllComments(repo))
+	})
+}
+
+// Find all comments in the repository
+// @return result.Result<CommentSlice, error>
+func AllComments(repo *git.Repository) result.Result {
+	comments := make(CommentSlice, 0)
+	return gg.CommentRefIterator(repo, func(ref *git.Reference) {
+		CommentFromRef(repo, ref.Name()).FlatMap(func(comment interface{}) result.Result {
+			comments = append(comments, comment.(*Comment))
+			return result.Result{}
+		})
+	}).FlatMap(func(value interface{}) result.Result {
+		sort.Stable(comments)
+		return result.NewSuccess(comments)
+	})
+}
+
+// Import new remote comments and reconcile changes to comments which
+// were previously synchronized
+// @return result.Result<bool, error>
+func syncRemoteComments(repo *git.Repository, service ReviewService, remote []*ImportedComment, local CommentSlice, summary *SyncSummary) result.Result {
+	known := make(map[string]string)
+	linked := make(map[string]*Comment)
+	for _, comment := range local {
+		for name, id := range comment.ExternalIDs {
+			known[externalIDKey(name, id)] = comment.Identity()
+		}
+		if id, ok := comment.ExternalID(service.Name()); ok {
+			linked[id] = comment
+		}
+	}
+	for _, imported := range remote {
+		comment, ok := linked[imported.ID]
+		if !ok {
+			commit := imported.Comment.Commit
+			if commit == nil || gg.ResolveSingleCommitHash(repo, *commit).Failure != nil {
+				continue
+			}
+			if err := importComment(repo, imported, known).Failure; err != nil {
+				return result.NewFailure(err)
+			}
+			known[externalIDKey(imported.Service, imported.ID)] = imported.Comment.Identity()
+			summary.Fetched = append(summary.Fetched, imported.Comment)
+			continue
+		}
+		if comment.Deleted || comment.Content == imported.Comment.Content {
+			continue
+		}
+		if imported.Comment.Amender.Date.After(comment.Amender.Date) {
+			comment.Amend(imported.Comment.Content, imported.Comment.Amender)
+			if err := writeCommentToDisk(repo, comment).Failure; err != nil {
+				return result.NewFailure(err)
+			}
+			summary.UpdatedLocal = append(summary.UpdatedLocal, comment)
+		} else {
+			if err := service.UpdateComment(imported.ID, comment).Failure; err != nil {
+				return result.NewFailure(err)
+			}
+			summary.UpdatedRemote = append(summary.UpdatedRemote, comment)
+		}
+	}
+	return result.NewSuccess(true)
+}
+
+// Create comments within the service for local comments which have
+// not yet been synchronized, replying to parent comments where possible
+// @return result.Result<bool, error>
+func pushLocalComments(repo *git.Repository, service ReviewService, commits []string, local CommentSlice, summary *SyncSummary) result.Result {
+	reviewed := make(map[string]bool)
+	for _, commit := range commits {
+		reviewed[commit] = true
+	}
+	parents := local.ByIdentity()
+	for _, comment := range local {
+		if _, ok := comment.ExternalID(service.Name()); ok || comment.Deleted || !reviewed[*comment.Commit] || !service.Supports(comment) {
+			continue
+		}
+		var inReplyTo string
+		if comment.Parent != nil {
+			if parent, ok := parents[*comment.Parent]; ok {
+				inReplyTo, _ = parent.ExternalID(service.Name())
+			}
+		}
+		created := service.CreateComment(comment, inReplyTo)
+		if created.Failure != nil {
+			return created
+		}
+		comment.SetExternalID(service.Name(), created.Success.(string))
+		if err := writeCommentToDisk(repo, comment).Failure; err != nil {
+			return result.NewFailure(err)
+		}
+		summary.Pushed = append(summary.Pushed, comment)
+	}
+	return result.NewSuccess(true)
+}
@@ -0,0 +1,135 @@
+package search
+
+import (
+	"fmt"
+	bleve_search "github.com/blevesearch/bleve/search"
+	"sort"
+	"strings"
+)
+
+// A property of comments counted among the comments matching a search
+type Facet string
+
+const (
+	// Comments counted by author name
+	FacetByAuthor Facet = "author"
+	// Comments counted by the top-level directory of their file
+	FacetByPath Facet = "path"
+	// Comments counted by the month they were created
+	FacetByMonth Facet = "month"
+
+	authorFacetField    = "facet_author"
+	directoryFacetField = "facet_directory"
+	monthFacetField     = "facet_month"
+
+	monthFormat = "2006-01"
+	// Directory of files at the top level of the repository
+	rootDirectory = "."
+
+	unknownFacetError = "Unknown facet '%v', expected author, path, or month"
+)
+
+// The number of matching comments with each value of a facet
+type FacetCounts struct {
+	Facet  Facet         `json:"facet"`
+	Counts []*FacetCount `json:"counts"`
+	// Number of matching comments without a value, such as comments on
+	// a whole commit when counting by path
+	Missing int `json:"missing"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Parse a comma-separated list of facets, such as `author,month`
+func ParseFacets(value string) ([]Facet, error) {
+	facets := make([]Facet, 0)
+	for _, name := range strings.Split(value, ",") {
+		facet := Facet(strings.ToLower(strings.TrimSpace(name)))
+		if len(facet) == 0 {
+			continue
+		} else if len(facetField(facet)) == 0 {
+			return nil, fmt.Errorf(unknownFacetError, name)
+		}
+		facets = append(facets, facet)
+	}
+	return facets, nil
+}
+
+// The index field holding the values of a facet, or empty if the facet
+// is unknown
+func facetField(facet Facet) string {
+	switch facet {
+	case FacetByAuthor:
+		return authorFacetField
+	case FacetByPath:
+		return directoryFacetField
+	case FacetByMonth:
+		return monthFacetField
+	}
+	return ""
+}
+
+// The counts of a facet, the most common values first, or the most
+// recent first for months
+func facetCounts(facet Facet, facetResult *bleve_search.FacetResult) *FacetCounts {
+	counts := &FacetCounts{facet, make([]*FacetCount, 0), 0}
+	if facetResult == nil {
+		return counts
+	}
+	counts.Missing = facetResult.Missing
+	for _, term := range facetResult.Terms {
+		if len(term.Term) == 0 {
+			counts.Missing += term.Count
+			continue
+		}
+		counts.Counts = append(counts.Counts, &FacetCount{term.Term, term.Count})
+	}
+	if facet == FacetByMonth {
+		sort.Sort(sort.Reverse(countsByValue(counts.Counts)))
+	} else {
+		sort.Sort(countsByCount(counts.Counts))
+	}
+	return counts
+}
+
+// The top-level directory containing a file
+func topDirectory(filePath string) string {
+	if index := strings.Index(filePath, "/"); index > 0 {
+		return filePath[:index+1]
+	}
+	return rootDirectory
+}
+
+type countsByValue []*FacetCount
+
+func (c countsByValue) Len() int {
+	return len(c)
+}
+
+func (c countsByValue) Less(i, j int) bool {
+	return c[i].Value < c[j].Value
+}
+
+func (c countsByValue) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+type countsByCount []*FacetCount
+
+func (c countsByCount) Len() int {
+	return len(c)
+}
+
+func (c countsByCount) Less(i, j int) bool {
+	if c[i].Count == c[j].Count {
+		return c[i].Value < c[j].Value
+	}
+	return c[i].Count > c[j].Count
+}
+
+func (c countsByCount) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
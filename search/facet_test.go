@@ -0,0 +1,59 @@
+package search
+
+import (
+	bleve_search "github.com/blevesearch/bleve/search"
+	"github.com/stvp/assert"
+	"testing"
+)
+
+func TestParseFacets(t *testing.T) {
+	facets, err := ParseFacets("author, PATH,,month")
+	assert.Nil(t, err)
+	assert.Equal(t, facets, []Facet{FacetByAuthor, FacetByPath, FacetByMonth})
+}
+
+func TestParseFacetsEmpty(t *testing.T) {
+	facets, err := ParseFacets("")
+	assert.Nil(t, err)
+	assert.Equal(t, len(facets), 0)
+}
+
+func TestParseFacetsUnknown(t *testing.T) {
+	_, err := ParseFacets("author,commit")
+	assert.NotNil(t, err)
+}
+
+func TestTopDirectory(t *testing.T) {
+	assert.Equal(t, topDirectory("src/lib/parse.c"), "src/")
+	assert.Equal(t, topDirectory("README"), ".")
+}
+
+func TestFacetCountsByCount(t *testing.T) {
+	counts := facetCounts(FacetByPath, &bleve_search.FacetResult{
+		Missing: 1,
+		Terms: bleve_search.TermFacets{
+			&bleve_search.TermFacet{Term: "src/", Count: 2},
+			&bleve_search.TermFacet{Term: "", Count: 3},
+			&bleve_search.TermFacet{Term: "docs/", Count: 5},
+			&bleve_search.TermFacet{Term: ".", Count: 2},
+		},
+	})
+	assert.Equal(t, counts.Missing, 4)
+	assert.Equal(t, counts.Counts, []*FacetCount{{"docs/", 5}, {".", 2}, {"src/", 2}})
+}
+
+func TestFacetCountsByMonth(t *testing.T) {
+	counts := facetCounts(FacetByMonth, &bleve_search.FacetResult{
+		Terms: bleve_search.TermFacets{
+			&bleve_search.TermFacet{Term: "2016-01", Count: 4},
+			&bleve_search.TermFacet{Term: "2016-03", Count: 1},
+			&bleve_search.TermFacet{Term: "2015-12", Count: 2},
+		},
+	})
+	assert.Equal(t, counts.Counts, []*FacetCount{{"2016-03", 1}, {"2016-01", 4}, {"2015-12", 2}})
+}
+
+func TestFacetCountsMissingResult(t *testing.T) {
+	counts := facetCounts(FacetByAuthor, nil)
+	assert.Equal(t, len(counts.Counts), 0)
+}
@@ -0,0 +1,152 @@
+package search
+
+import (
+	"errors"
+	gg "git"
+	"github.com/kylef/result.go/src/result"
+	git "gopkg.in/libgit2/git2go.v23"
+	gc "libgitcomment"
+	"regexp"
+	"strings"
+)
+
+const (
+	missingPatternError = "A pattern is required"
+)
+
+// Options for matching comment content against a pattern without
+// using the search index
+type GrepOptions struct {
+	Pattern string
+	// Whether the pattern is a fixed string instead of a regular
+	// expression
+	Fixed      bool
+	IgnoreCase bool
+	// Whether the pattern must match whole words
+	WordRegexp bool
+	// Number of lines of context to include before and after matches
+	Before int
+	After  int
+	// Only match comments found in a source, LocalSource or a remote
+	// name, if provided
+	Remote string
+}
+
+// A comment with content lines matching a pattern
+type GrepMatch struct {
+	Comment *gc.Comment
+	// Matching lines and their context, in order
+	Lines []*GrepLine
+	// Number of lines matching the pattern
+	Count int
+	// Where the comment was found, LocalSource or remote names
+	Sources []string
+}
+
+// A line of comment content
+type GrepLine struct {
+	// Line number within the comment content, starting from one
+	Number  int
+	Content string
+	// Start and end offsets of each match within the line, which is
+	// context if there are none
+	Matches [][]int
+}
+
+// Find comments with content matching a pattern, either on the commits
+// in a revision range or all comments if no range is provided. Comments
+// fetched from remotes are included, and deleted comments are not
+// matched.
+// @return result.Result<[]*GrepMatch, error>
+func GrepComments(repoPath, revisions string, options *GrepOptions) result.Result {
+	pattern, err := compilePattern(options)
+	if err != nil {
+		return result.NewFailure(err)
+	}
+	return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
+		var included map[string]bool
+		commits := result.NewSuccess(true)
+		if len(revisions) > 0 {
+			commits = rangeCommits(repo, revisions)
+		}
+		return commits.FlatMap(func(value interface{}) result.Result {
+			included, _ = value.(map[string]bool)
+			return sourcedComments(repo)
+		}).FlatMap(func(value interface{}) result.Result {
+			matches := make([]*GrepMatch, 0)
+			for _, found := range value.([]*sourcedComment) {
+				comment := found.comment
+				if comment.Deleted || included != nil && !included[*comment.Commit] {
+					continue
+				} else if len(options.Remote) > 0 && !hasSource(found.sources, options.Remote) {
+					continue
+				}
+				if match := grepComment(comment, pattern, options.Before, options.After); match != nil {
+					match.Sources = found.sources
+					matches = append(matches, match)
+				}
+			}
+			return result.NewSuccess(matches)
+		})
+	})
+}
+
+// The hashes of the commits in a revision range
+// @return result.Result<map[string]bool, error>
+func rangeCommits(repo *git.Repository, revisions string) result.Result {
+	return gg.ResolveCommits(repo, revisions).FlatMap(func(commitRange interface{}) result.Result {
+		hashes := make(map[string]bool)
+		for _, commit := range commitRange.(*gg.CommitRange).Commits() {
+			hashes[commit.Id().String()] = true
+		}
+		return result.NewSuccess(hashes)
+	})
+}
+
+// Build the regular expression described by grep options
+func compilePattern(options *GrepOptions) (*regexp.Regexp, error) {
+	if len(options.Pattern) == 0 {
+		return nil, errors.New(missingPatternError)
+	}
+	pattern := options.Pattern
+	if options.Fixed {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if options.WordRegexp {
+		pattern = `\b(?:` + pattern + `)\b`
+	}
+	if options.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// Find the lines of comment content matching a pattern, along with the
+// requested context, or nil if no lines match
+func grepComment(comment *gc.Comment, pattern *regexp.Regexp, before, after int) *GrepMatch {
+	lines := strings.Split(strings.TrimSuffix(comment.Content, "\n"), "\n")
+	matches := make([][][]int, len(lines))
+	included := make([]bool, len(lines))
+	count := 0
+	for index, line := range lines {
+		if matches[index] = pattern.FindAllStringIndex(line, -1); len(matches[index]) == 0 {
+			continue
+		}
+		count++
+		for context := index - before; context <= index+after; context++ {
+			if context >= 0 && context < len(lines) {
+				included[context] = true
+			}
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+	match := &GrepMatch{comment, []*GrepLine{}, count, nil}
+	for index, line := range lines {
+		if included[index] {
+			match.Lines = append(match.Lines, &GrepLine{index + 1, line, matches[index]})
+		}
+	}
+	return match
+}
@@ -0,0 +1,62 @@
+package search
+
+import (
+	"github.com/stvp/assert"
+	gc "libgitcomment"
+	"testing"
+)
+
+func grepContent(t *testing.T, content string, options *GrepOptions) *GrepMatch {
+	pattern, err := compilePattern(options)
+	assert.Nil(t, err)
+	return grepComment(&gc.Comment{Content: content}, pattern, options.Before, options.After)
+}
+
+func TestCompilePatternMissing(t *testing.T) {
+	_, err := compilePattern(&GrepOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestCompilePatternInvalid(t *testing.T) {
+	_, err := compilePattern(&GrepOptions{Pattern: "TODO("})
+	assert.NotNil(t, err)
+}
+
+func TestGrepRegexp(t *testing.T) {
+	match := grepContent(t, "Title\nTODO(kim): fix\nTODO: later", &GrepOptions{Pattern: `TODO\(\w+\)`})
+	assert.Equal(t, match.Count, 1)
+	assert.Equal(t, match.Lines, []*GrepLine{&GrepLine{2, "TODO(kim): fix", [][]int{{0, 9}}}})
+}
+
+func TestGrepFixedString(t *testing.T) {
+	match := grepContent(t, "a.b\naxb", &GrepOptions{Pattern: "a.b", Fixed: true})
+	assert.Equal(t, match.Count, 1)
+	assert.Equal(t, match.Lines[0].Number, 1)
+}
+
+func TestGrepIgnoreCase(t *testing.T) {
+	match := grepContent(t, "Leak\nleak", &GrepOptions{Pattern: "LEAK", IgnoreCase: true})
+	assert.Equal(t, match.Count, 2)
+}
+
+func TestGrepWordRegexp(t *testing.T) {
+	match := grepContent(t, "leaky\na leak", &GrepOptions{Pattern: "leak", WordRegexp: true})
+	assert.Equal(t, match.Count, 1)
+	assert.Equal(t, match.Lines[0].Number, 2)
+}
+
+func TestGrepNoMatch(t *testing.T) {
+	assert.Nil(t, grepContent(t, "nothing here", &GrepOptions{Pattern: "leak"}))
+}
+
+func TestGrepContext(t *testing.T) {
+	content := "one\ntwo\nleak\nfour\nfive\nsix\nleak"
+	match := grepContent(t, content, &GrepOptions{Pattern: "leak", Before: 1, After: 1})
+	numbers := make([]int, 0)
+	for _, line := range match.Lines {
+		numbers = append(numbers, line.Number)
+	}
+	assert.Equal(t, numbers, []int{2, 3, 4, 6, 7})
+	assert.Equal(t, len(match.Lines[0].Matches), 0)
+	assert.Equal(t, match.Count, 2)
+}
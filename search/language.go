@@ -0,0 +1,151 @@
+package search
+
+import (
+	"fmt"
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/analyzers/standard_analyzer"
+	"github.com/blevesearch/bleve/analysis/language/ar"
+	"github.com/blevesearch/bleve/analysis/language/ckb"
+	"github.com/blevesearch/bleve/analysis/language/en"
+	"github.com/blevesearch/bleve/analysis/language/fa"
+	"github.com/blevesearch/bleve/analysis/language/fr"
+	"github.com/blevesearch/bleve/analysis/language/hi"
+	"github.com/blevesearch/bleve/analysis/language/it"
+	"github.com/blevesearch/bleve/analysis/language/pt"
+	"github.com/blevesearch/bleve/registry"
+	gc "libgitcomment"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// Config listing the languages of comment content, the first of
+	// which is the default
+	searchLanguageConfig = "comment.searchLanguage"
+	// Language analyzing comment content when none is configured, which
+	// splits words without stemming them
+	defaultSearchLanguage = standard_analyzer.Name
+
+	unknownLanguageError   = "Unsupported search language '%v', expected one of: %v"
+	duplicateLanguageError = "Search language '%v' is configured more than once"
+)
+
+// Analyzers for comment content by language. Languages whose analyzers
+// need cgo or dictionaries which are not vendored, such as Japanese, are
+// not supported.
+var languageAnalyzers = map[string]string{
+	defaultSearchLanguage: standard_analyzer.Name,
+	"ar":                  ar.AnalyzerName,
+	"ckb":                 ckb.AnalyzerName,
+	"en":                  en.AnalyzerName,
+	"fa":                  fa.AnalyzerName,
+	"fr":                  fr.AnalyzerName,
+	"hi":                  hi.AnalyzerName,
+	"it":                  it.AnalyzerName,
+	"pt":                  pt.AnalyzerName,
+}
+
+// Common words which identify the language of comment content. The
+// standard analyzer removes English stop words, so they identify it
+// too.
+var languageStopWords = map[string]string{
+	defaultSearchLanguage: en.StopName,
+	"ar":                  ar.StopName,
+	"ckb":                 ckb.StopName,
+	"en":                  en.StopName,
+	"fa":                  fa.StopName,
+	"fr":                  fr.StopName,
+	"hi":                  hi.StopName,
+	"it":                  it.StopName,
+	"pt":                  pt.StopName,
+}
+
+// Parse the configured search languages, a comma-separated list such as
+// `fr,en`. The first language is used for comments unless another
+// configured language is detected in their content.
+func parseLanguages(value string) ([]string, error) {
+	languages := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, language := range strings.Split(value, ",") {
+		language = strings.ToLower(strings.TrimSpace(language))
+		if len(language) == 0 {
+			continue
+		} else if _, ok := languageAnalyzers[language]; !ok {
+			return nil, fmt.Errorf(unknownLanguageError, language, strings.Join(SupportedLanguages(), ", "))
+		} else if seen[language] {
+			return nil, fmt.Errorf(duplicateLanguageError, language)
+		}
+		seen[language] = true
+		languages = append(languages, language)
+	}
+	if len(languages) == 0 {
+		languages = append(languages, defaultSearchLanguage)
+	}
+	return languages, nil
+}
+
+// Languages which comment content can be analyzed in
+func SupportedLanguages() []string {
+	languages := make([]string, 0, len(languageAnalyzers))
+	for language := range languageAnalyzers {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Load the stop words identifying each language
+func languageTokenMaps(languages []string) (map[string]analysis.TokenMap, error) {
+	cache := registry.NewCache()
+	tokenMaps := make(map[string]analysis.TokenMap)
+	for _, language := range languages {
+		if name, ok := languageStopWords[language]; ok {
+			tokenMap, err := cache.TokenMapNamed(name)
+			if err != nil {
+				return nil, err
+			}
+			tokenMaps[language] = tokenMap
+		}
+	}
+	return tokenMaps, nil
+}
+
+// Choose the language of comment content from the configured languages.
+// The language given for the comment is used if configured, otherwise
+// the language with the most stop words in the content.
+// Ties are resolved in favor of the language configured first.
+func detectLanguage(content, given string, languages []string, tokenMaps map[string]analysis.TokenMap) string {
+	if len(languages) == 1 {
+		return languages[0]
+	}
+	for _, language := range languages {
+		if language == given {
+			return language
+		}
+	}
+	words := strings.FieldsFunc(strings.ToLower(content), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsMark(c)
+	})
+	detected, best := languages[0], 0
+	for _, language := range languages {
+		score := 0
+		for _, word := range words {
+			if tokenMaps[language][word] {
+				score++
+			}
+		}
+		if score > best {
+			detected, best = language, score
+		}
+	}
+	return detected
+}
+
+// The language given for a comment, or empty if it should be detected
+func commentLanguage(comment *gc.Comment) string {
+	if comment.Language != nil {
+		return strings.ToLower(*comment.Language)
+	}
+	return ""
+}
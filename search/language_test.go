@@ -0,0 +1,76 @@
+package search
+
+import (
+	"github.com/stvp/assert"
+	"testing"
+)
+
+func TestParseLanguagesDefault(t *testing.T) {
+	languages, err := parseLanguages("")
+	assert.Nil(t, err)
+	assert.Equal(t, languages, []string{"standard"})
+}
+
+func TestParseLanguagesList(t *testing.T) {
+	languages, err := parseLanguages(" FR, pt,,en ")
+	assert.Nil(t, err)
+	assert.Equal(t, languages, []string{"fr", "pt", "en"})
+}
+
+func TestParseLanguagesUnsupported(t *testing.T) {
+	_, err := parseLanguages("fr,xx")
+	assert.NotNil(t, err)
+}
+
+func TestParseLanguagesDuplicate(t *testing.T) {
+	_, err := parseLanguages("fr,en,fr")
+	assert.NotNil(t, err)
+}
+
+func TestDetectLanguageSingle(t *testing.T) {
+	languages := []string{"fr"}
+	assert.Equal(t, detectLanguage("The parser is broken", "", languages, nil), "fr")
+}
+
+func TestDetectLanguageStopWords(t *testing.T) {
+	languages := []string{"en", "fr", "pt"}
+	tokenMaps, err := languageTokenMaps(languages)
+	assert.Nil(t, err)
+	assert.Equal(t, detectLanguage("Les fonctions ne vérifient pas les erreurs", "", languages, tokenMaps), "fr")
+	assert.Equal(t, detectLanguage("As funções não verificam os erros", "", languages, tokenMaps), "pt")
+	assert.Equal(t, detectLanguage("The functions do not check the errors", "", languages, tokenMaps), "en")
+}
+
+func TestDetectLanguageTie(t *testing.T) {
+	languages := []string{"pt", "fr"}
+	tokenMaps, err := languageTokenMaps(languages)
+	assert.Nil(t, err)
+	assert.Equal(t, detectLanguage("refactor", "", languages, tokenMaps), "pt")
+}
+
+func TestParseLanguagesRightToLeftAndIndic(t *testing.T) {
+	languages, err := parseLanguages("ar,fa,hi")
+	assert.Nil(t, err)
+	assert.Equal(t, languages, []string{"ar", "fa", "hi"})
+}
+
+func TestDetectLanguageGiven(t *testing.T) {
+	languages := []string{"en", "fr"}
+	tokenMaps, err := languageTokenMaps(languages)
+	assert.Nil(t, err)
+	assert.Equal(t, detectLanguage("The functions do not check the errors", "fr", languages, tokenMaps), "fr")
+}
+
+func TestDetectLanguageGivenNotConfigured(t *testing.T) {
+	languages := []string{"en", "fr"}
+	tokenMaps, err := languageTokenMaps(languages)
+	assert.Nil(t, err)
+	assert.Equal(t, detectLanguage("The functions do not check the errors", "pt", languages, tokenMaps), "en")
+}
+
+func TestDetectLanguageStopWordsHindi(t *testing.T) {
+	languages := []string{"en", "hi"}
+	tokenMaps, err := languageTokenMaps(languages)
+	assert.Nil(t, err)
+	assert.Equal(t, detectLanguage("यह फ़ंक्शन त्रुटियों की जाँच नहीं करता है", "", languages, tokenMaps), "hi")
+}
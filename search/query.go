@@ -0,0 +1,282 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"github.com/blevesearch/bleve"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	authorField  = "author"
+	amenderField = "amender"
+	commitField  = "commit"
+	pathField    = "path"
+	fileField    = "file"
+	lineField    = "line"
+	createdField = "created"
+	amendedField = "amended"
+	remoteField  = "remote"
+
+	dateFormat = "2006-01-02"
+
+	unknownFieldError    = "Unknown search field '%v'"
+	emptyFilterError     = "No value provided for '%v'"
+	invalidOperatorError = "'%v' does not support '%v'"
+	invalidLineError     = "Invalid line number '%v'"
+	invalidDateError     = "Invalid date '%v', expected YYYY-MM-DD or an RFC 3339 time"
+	unterminatedError    = "Unterminated quote in search query"
+)
+
+// Comparison operators supported by line and date fields
+var operators = []string{">=", "<=", ">", "<"}
+
+// A search for comments containing text and matching field filters
+type Query struct {
+	// Text matched against comment content, using the bleve query
+	// string syntax
+	Text string
+	// Filters which all must match
+	Filters []*FieldFilter
+}
+
+// A restriction on a comment field, such as `author:alice` or
+// `created:>2016-01-01`
+type FieldFilter struct {
+	Field string
+	// Comparison operator for line and date fields, or empty for an
+	// exact match
+	Operator string
+	Value    string
+	// Whether matching comments are excluded instead
+	Exclude bool
+}
+
+// Parse a search query. Terms of the form `field:value` for the fields
+// author, amender, commit, path (or file), line, created, amended and
+// remote become filters, prefixed with `-` to exclude matches. All other terms
+// are matched against comment content.
+func ParseQuery(text string) (*Query, error) {
+	terms, err := splitTerms(text)
+	if err != nil {
+		return nil, err
+	}
+	query := &Query{Filters: []*FieldFilter{}}
+	content := make([]string, 0, len(terms))
+	for _, term := range terms {
+		name, value, exclude := splitFieldTerm(term)
+		if !isFilterField(name) {
+			content = append(content, term)
+		} else if err := query.addFilter(name, value, exclude); err != nil {
+			return nil, err
+		}
+	}
+	query.Text = strings.Join(content, " ")
+	return query, nil
+}
+
+// Restrict the query to comments where a field matches a value, which
+// may begin with an operator for line and date fields
+func (q *Query) AddFilter(field, value string) error {
+	if !isFilterField(field) {
+		return fmt.Errorf(unknownFieldError, field)
+	}
+	return q.addFilter(field, value, false)
+}
+
+func (q *Query) addFilter(field, value string, exclude bool) error {
+	if field == fileField {
+		field = pathField
+	}
+	operator := ""
+	for _, candidate := range operators {
+		if strings.HasPrefix(value, candidate) {
+			operator = candidate
+			value = value[len(candidate):]
+			break
+		}
+	}
+	value = unquote(value)
+	if len(value) == 0 {
+		return fmt.Errorf(emptyFilterError, field)
+	}
+	filter := &FieldFilter{field, operator, value, exclude}
+	switch field {
+	case lineField:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf(invalidLineError, value)
+		}
+	case createdField, amendedField:
+		if _, _, err := parseDate(value); err != nil {
+			return err
+		}
+	default:
+		if len(operator) > 0 {
+			return fmt.Errorf(invalidOperatorError, field, operator)
+		}
+	}
+	q.Filters = append(q.Filters, filter)
+	return nil
+}
+
+// Build the bleve query matching comments
+func (q *Query) searchQuery() bleve.Query {
+	must := make([]bleve.Query, 0, len(q.Filters)+1)
+	mustNot := make([]bleve.Query, 0, len(q.Filters))
+	if len(strings.TrimSpace(q.Text)) > 0 {
+		must = append(must, bleve.NewQueryStringQuery(q.Text))
+	} else {
+		must = append(must, bleve.NewMatchAllQuery())
+	}
+	for _, filter := range q.Filters {
+		if filter.Exclude {
+			mustNot = append(mustNot, filter.searchQuery())
+		} else {
+			must = append(must, filter.searchQuery())
+		}
+	}
+	return bleve.NewBooleanQuery(must, nil, mustNot)
+}
+
+func (f *FieldFilter) searchQuery() bleve.Query {
+	switch f.Field {
+	case authorField, amenderField:
+		return personQuery(f.Field, strings.ToLower(f.Value))
+	case commitField:
+		return bleve.NewPrefixQuery(strings.ToLower(f.Value)).SetField(commitField)
+	case pathField:
+		return pathQuery(f.Value)
+	case remoteField:
+		return bleve.NewTermQuery(f.Value).SetField(sourcesField)
+	case lineField:
+		line, _ := strconv.Atoi(f.Value)
+		start, end := operatorRange(f.Operator, float64(line), float64(line+1))
+		return bleve.NewNumericRangeQuery(start, end).SetField(lineField)
+	}
+	date, next, _ := parseDate(f.Value)
+	start, end := operatorRange(f.Operator, float64(date.Unix()), float64(next.Unix()))
+	return bleve.NewDateRangeQuery(formatTime(start), formatTime(end)).SetField(f.Field)
+}
+
+// Match a person by name or email address, or by the username of the
+// email address. Wildcards are supported.
+func personQuery(field, value string) bleve.Query {
+	nameField, emailField := field+personNameSuffix, field+personEmailSuffix
+	if strings.ContainsAny(value, "*?") {
+		return bleve.NewDisjunctionQuery([]bleve.Query{
+			bleve.NewWildcardQuery(value).SetField(nameField),
+			bleve.NewWildcardQuery(value).SetField(emailField),
+		})
+	}
+	return bleve.NewDisjunctionQuery([]bleve.Query{
+		bleve.NewTermQuery(value).SetField(nameField),
+		bleve.NewTermQuery(value).SetField(emailField),
+		bleve.NewPrefixQuery(value + "@").SetField(emailField),
+	})
+}
+
+// Match a file path, or the files within a directory. Wildcards are
+// supported.
+func pathQuery(value string) bleve.Query {
+	if strings.ContainsAny(value, "*?") {
+		return bleve.NewWildcardQuery(value).SetField(pathField)
+	} else if strings.HasSuffix(value, "/") {
+		return bleve.NewPrefixQuery(value).SetField(pathField)
+	}
+	return bleve.NewDisjunctionQuery([]bleve.Query{
+		bleve.NewTermQuery(value).SetField(pathField),
+		bleve.NewPrefixQuery(value + "/").SetField(pathField),
+	})
+}
+
+// The range [start, end) matching an operator applied to a value, where
+// next is the smallest value after it. Unbounded ends are nil.
+func operatorRange(operator string, value, next float64) (*float64, *float64) {
+	switch operator {
+	case ">":
+		return &next, nil
+	case ">=":
+		return &value, nil
+	case "<":
+		return nil, &value
+	case "<=":
+		return nil, &next
+	}
+	return &value, &next
+}
+
+// Parse a date or time, returning it along with the start of the
+// following day or second
+func parseDate(value string) (time.Time, time.Time, error) {
+	if date, err := time.ParseInLocation(dateFormat, value, time.Local); err == nil {
+		return date, date.AddDate(0, 0, 1), nil
+	} else if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, date.Add(time.Second), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf(invalidDateError, value)
+}
+
+func formatTime(unix *float64) *string {
+	if unix == nil {
+		return nil
+	}
+	formatted := time.Unix(int64(*unix), 0).Format(time.RFC3339)
+	return &formatted
+}
+
+func isFilterField(name string) bool {
+	switch name {
+	case authorField, amenderField, commitField, pathField, fileField, lineField, createdField, amendedField, remoteField:
+		return true
+	}
+	return false
+}
+
+// Split a fielded term such as `-author:alice` into the field name,
+// value, and whether it is excluded
+func splitFieldTerm(term string) (string, string, bool) {
+	exclude := strings.HasPrefix(term, "-")
+	trimmed := strings.TrimLeft(term, "+-")
+	index := strings.Index(trimmed, ":")
+	if index <= 0 {
+		return "", term, false
+	}
+	return strings.ToLower(trimmed[:index]), trimmed[index+1:], exclude
+}
+
+// Split a query into whitespace-separated terms, keeping quoted text
+// together
+func splitTerms(text string) ([]string, error) {
+	terms := make([]string, 0)
+	var term []rune
+	quoted := false
+	for _, c := range text {
+		if c == '"' {
+			quoted = !quoted
+		} else if unicode.IsSpace(c) && !quoted {
+			if len(term) > 0 {
+				terms = append(terms, string(term))
+				term = nil
+			}
+			continue
+		}
+		term = append(term, c)
+	}
+	if quoted {
+		return nil, errors.New(unterminatedError)
+	}
+	if len(term) > 0 {
+		terms = append(terms, string(term))
+	}
+	return terms, nil
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
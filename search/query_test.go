@@ -0,0 +1,100 @@
+package search
+
+import (
+	"github.com/stvp/assert"
+	"testing"
+)
+
+func TestParseQueryText(t *testing.T) {
+	query, err := ParseQuery("fix  \"the parser\" content:leak")
+	assert.Nil(t, err)
+	assert.Equal(t, query.Text, "fix \"the parser\" content:leak")
+	assert.Equal(t, len(query.Filters), 0)
+}
+
+func TestParseQueryFilters(t *testing.T) {
+	query, err := ParseQuery("author:alice leak Path:src/ -commit:abc123 created:>2016-01-01")
+	assert.Nil(t, err)
+	assert.Equal(t, query.Text, "leak")
+	assert.Equal(t, query.Filters, []*FieldFilter{
+		&FieldFilter{"author", "", "alice", false},
+		&FieldFilter{"path", "", "src/", false},
+		&FieldFilter{"commit", "", "abc123", true},
+		&FieldFilter{"created", ">", "2016-01-01", false},
+	})
+}
+
+func TestParseQueryQuotedFilter(t *testing.T) {
+	query, err := ParseQuery("author:\"Alice Smith\" file:\"docs/User Guide.md\"")
+	assert.Nil(t, err)
+	assert.Equal(t, query.Filters, []*FieldFilter{
+		&FieldFilter{"author", "", "Alice Smith", false},
+		&FieldFilter{"path", "", "docs/User Guide.md", false},
+	})
+}
+
+func TestParseQueryRemoteFilter(t *testing.T) {
+	query, err := ParseQuery("leak remote:origin -remote:local")
+	assert.Nil(t, err)
+	assert.Equal(t, query.Filters, []*FieldFilter{
+		&FieldFilter{"remote", "", "origin", false},
+		&FieldFilter{"remote", "", "local", true},
+	})
+}
+
+func TestParseQueryUnterminatedQuote(t *testing.T) {
+	_, err := ParseQuery("author:\"Alice")
+	assert.NotNil(t, err)
+}
+
+func TestParseQueryInvalidDate(t *testing.T) {
+	_, err := ParseQuery("created:>yesterday")
+	assert.NotNil(t, err)
+}
+
+func TestParseQueryInvalidLine(t *testing.T) {
+	_, err := ParseQuery("line:>=ten")
+	assert.NotNil(t, err)
+}
+
+func TestParseQueryOperatorOnKeyword(t *testing.T) {
+	_, err := ParseQuery("author:>alice")
+	assert.NotNil(t, err)
+}
+
+func TestParseQueryEmptyFilter(t *testing.T) {
+	_, err := ParseQuery("path:")
+	assert.NotNil(t, err)
+}
+
+func TestAddFilterUnknownField(t *testing.T) {
+	query, _ := ParseQuery("")
+	assert.NotNil(t, query.AddFilter("reviewer", "alice"))
+	assert.Nil(t, query.AddFilter("created", ">=2016-01-01"))
+	assert.Equal(t, query.Filters, []*FieldFilter{&FieldFilter{"created", ">=", "2016-01-01", false}})
+}
+
+func TestOperatorRange(t *testing.T) {
+	start, end := operatorRange("", 3, 4)
+	assert.Equal(t, *start, float64(3))
+	assert.Equal(t, *end, float64(4))
+	start, end = operatorRange(">", 3, 4)
+	assert.Equal(t, *start, float64(4))
+	assert.Nil(t, end)
+	start, end = operatorRange("<=", 3, 4)
+	assert.Nil(t, start)
+	assert.Equal(t, *end, float64(4))
+}
+
+func TestParseDateDay(t *testing.T) {
+	date, next, err := parseDate("2016-02-28")
+	assert.Nil(t, err)
+	assert.Equal(t, date.Format(dateFormat), "2016-02-28")
+	assert.Equal(t, next.Format(dateFormat), "2016-02-29")
+}
+
+func TestParseDateTime(t *testing.T) {
+	date, next, err := parseDate("2016-02-28T10:00:00Z")
+	assert.Nil(t, err)
+	assert.Equal(t, next.Sub(date).Seconds(), float64(1))
+}
@@ -0,0 +1,597 @@
+package search
+
+import (
+	"encoding/json"
+	gg "git"
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis/analyzers/custom_analyzer"
+	"github.com/blevesearch/bleve/analysis/analyzers/keyword_analyzer"
+	"github.com/blevesearch/bleve/analysis/token_filters/lower_case_filter"
+	"github.com/blevesearch/bleve/analysis/tokenizers/single_token"
+	bleve_search "github.com/blevesearch/bleve/search"
+	"github.com/kylef/result.go/src/result"
+	git "gopkg.in/libgit2/git2go.v23"
+	gc "libgitcomment"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	indexFilePath = "index"
+	// Internal index key listing the identifiers and sources of the
+	// comment references seen by the last update
+	indexedCommentsKey = "comments"
+	// Internal index key holding the version of the index mapping
+	indexVersionKey = "version"
+	// Internal index key holding the search languages the index was
+	// created with
+	indexLanguagesKey = "languages"
+	// Directory within the index holding the indexes of comments in
+	// languages other than the default
+	languageIndexDir = "languages"
+	// Version of the index mapping, incremented when the mapping changes
+	// so older indexes are rebuilt
+	indexVersion = "5"
+
+	contentField      = "content"
+	authorNameField   = authorField + personNameSuffix
+	personNameSuffix  = "_name"
+	personEmailSuffix = "_email"
+	lowercaseAnalyzer = "lowercase_keyword"
+)
+
+// The fields of a comment which are indexed
+type CommentIndex struct {
+	Content      string    `json:"content"`
+	AuthorName   string    `json:"author_name"`
+	AuthorEmail  string    `json:"author_email"`
+	AmenderName  string    `json:"amender_name"`
+	AmenderEmail string    `json:"amender_email"`
+	Commit       string    `json:"commit"`
+	Path         string    `json:"path"`
+	Line         *float64  `json:"line"`
+	Created      time.Time `json:"created"`
+	Amended      time.Time `json:"amended"`
+	// Where the comment was found, LocalSource or remote names
+	Sources []string `json:"sources"`
+	// Values counted by facets
+	AuthorFacet    string `json:"facet_author"`
+	DirectoryFacet string `json:"facet_directory"`
+	MonthFacet     string `json:"facet_month"`
+}
+
+// Order of search results
+type SortOrder string
+
+const (
+	// Most relevant comments first
+	SortByScore SortOrder = "score"
+	// Most recently created comments first
+	SortByDate SortOrder = "date"
+	// Comments ordered by author name, then most recently created
+	SortByAuthor SortOrder = "author"
+)
+
+// Options controlling which matching comments are returned
+type SearchOptions struct {
+	Sort SortOrder
+	// Number of matching comments to skip
+	Offset int
+	// Maximum number of comments to return, or zero for all
+	Limit int
+	// Properties to count among all matching comments
+	Facets []Facet
+}
+
+// A page of comments matching a search
+type SearchResults struct {
+	// Number of comments matching the search
+	Total int
+	Hits  []*SearchHit
+	// Counts of matching comments for each requested facet
+	Facets []*FacetCounts
+}
+
+// A comment matching a search
+type SearchHit struct {
+	Comment *gc.Comment
+	Score   float64
+	// The passage of the comment content which best matches the search
+	// text, with matched terms between MatchStart and MatchEnd. Empty
+	// when the search has no text.
+	Snippet string
+	// Where the comment was found, LocalSource or remote names
+	Sources []string
+}
+
+// Changes made to bring the index up to date
+type IndexSummary struct {
+	Added   int
+	Removed int
+}
+
+// Find the comments matching a query, updating the index first if it is
+// out of date. Only the comments within the requested page are read.
+// @return result.Result<*SearchResults, error>
+func CommentsMatching(repoPath string, query *Query, options *SearchOptions) result.Result {
+	return openIndex(repoPath, true, func(repo *git.Repository, indexes *indexSet) result.Result {
+		index := indexes.searchable()
+		return updateIndex(repo, indexes).FlatMap(func(value interface{}) result.Result {
+			return result.NewResult(index.DocCount())
+		}).FlatMap(func(count interface{}) result.Result {
+			return result.NewResult(index.Search(searchRequest(query, options, int(count.(uint64)))))
+		}).FlatMap(func(match interface{}) result.Result {
+			found := match.(*bleve.SearchResult)
+			matches := found.Hits
+			if sortedByFields(options.Sort) {
+				sortMatches(matches, options.Sort)
+				matches = pageMatches(matches, options.Offset, options.Limit)
+				if err := highlightMatches(indexes, matches); err != nil {
+					return result.NewFailure(err)
+				}
+			}
+			hits := make([]*SearchHit, 0, len(matches))
+			for _, match := range matches {
+				gc.CommentByID(repo, match.ID).FlatMap(func(comment interface{}) result.Result {
+					hit := &SearchHit{comment.(*gc.Comment), match.Score, "", storedSources(match.Fields[sourcesField])}
+					if fragments := match.Fragments[contentField]; len(fragments) > 0 {
+						hit.Snippet = snippetLine(fragments[0])
+					}
+					hits = append(hits, hit)
+					return result.Result{}
+				})
+			}
+			facets := make([]*FacetCounts, 0, len(options.Facets))
+			for _, facet := range options.Facets {
+				facets = append(facets, facetCounts(facet, found.Facets[string(facet)]))
+			}
+			return result.NewSuccess(&SearchResults{int(found.Total), hits, facets})
+		})
+	})
+}
+
+// A request for the page of matching comments in order of score, or
+// for every matching comment along with the fields it is ordered by
+// otherwise. Comments are highlighted once paged.
+func searchRequest(query *Query, options *SearchOptions, count int) *bleve.SearchRequest {
+	request := bleve.NewSearchRequestOptions(query.searchQuery(), count, 0, false)
+	request.Fields = []string{sourcesField}
+	if sortedByFields(options.Sort) {
+		request.Fields = append(request.Fields, createdField, authorNameField)
+	} else {
+		if options.Offset > 0 {
+			request.From = options.Offset
+		}
+		if options.Limit > 0 {
+			request.Size = options.Limit
+		}
+		request.Highlight = bleve.NewHighlightWithStyle(snippetHighlighter)
+		request.Highlight.AddField(contentField)
+	}
+	for _, facet := range options.Facets {
+		request.AddFacet(string(facet), bleve.NewFacetRequest(facetField(facet), count))
+	}
+	return request
+}
+
+func sortedByFields(order SortOrder) bool {
+	return order == SortByDate || order == SortByAuthor
+}
+
+// Order matches, which are initially ordered by score
+func sortMatches(matches bleve_search.DocumentMatchCollection, order SortOrder) {
+	switch order {
+	case SortByDate:
+		sort.Stable(matchesByDate(matches))
+	case SortByAuthor:
+		sort.Stable(matchesByAuthor(matches))
+	}
+}
+
+// The matches within a page of results
+func pageMatches(matches bleve_search.DocumentMatchCollection, offset, limit int) bleve_search.DocumentMatchCollection {
+	if offset >= len(matches) {
+		return bleve_search.DocumentMatchCollection{}
+	} else if offset < 0 {
+		offset = 0
+	}
+	matches = matches[offset:]
+	if limit > 0 && limit < len(matches) {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// Find the snippets of matches which were not highlighted by the search,
+// reading the indexed content of each from the index holding it
+func highlightMatches(indexes *indexSet, matches bleve_search.DocumentMatchCollection) error {
+	highlighter, err := bleve.Config.Cache.HighlighterNamed(snippetHighlighter)
+	if err != nil {
+		return err
+	}
+	for _, match := range matches {
+		for _, index := range indexes.indexes {
+			doc, err := index.Document(match.ID)
+			if err != nil {
+				return err
+			} else if doc != nil {
+				highlighter.BestFragmentsInField(match, doc, contentField, 1)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// The stored creation date of a matching comment
+func matchCreated(match *bleve_search.DocumentMatch) time.Time {
+	value, _ := match.Fields[createdField].(string)
+	created, _ := time.Parse(time.RFC3339, value)
+	return created
+}
+
+// The stored author name of a matching comment, ignoring case
+func matchAuthor(match *bleve_search.DocumentMatch) string {
+	value, _ := match.Fields[authorNameField].(string)
+	return strings.ToLower(value)
+}
+
+type matchesByDate bleve_search.DocumentMatchCollection
+
+func (m matchesByDate) Len() int {
+	return len(m)
+}
+
+func (m matchesByDate) Less(i, j int) bool {
+	return matchCreated(m[i]).After(matchCreated(m[j]))
+}
+
+func (m matchesByDate) Swap(i, j int) {
+	m[i], m[j] = m[j], m[i]
+}
+
+type matchesByAuthor bleve_search.DocumentMatchCollection
+
+func (m matchesByAuthor) Len() int {
+	return len(m)
+}
+
+func (m matchesByAuthor) Less(i, j int) bool {
+	a, b := matchAuthor(m[i]), matchAuthor(m[j])
+	if a == b {
+		return matchCreated(m[i]).After(matchCreated(m[j]))
+	}
+	return a < b
+}
+
+func (m matchesByAuthor) Swap(i, j int) {
+	m[i], m[j] = m[j], m[i]
+}
+
+// Rebuild the search index from all comments
+// @return result.Result<*IndexSummary, error>
+func IndexComments(repoPath string) result.Result {
+	return gc.CommentStoragePath(repoPath).FlatMap(func(storage interface{}) result.Result {
+		if err := os.RemoveAll(indexPath(storage.(string))); err != nil {
+			return result.NewFailure(err)
+		}
+		return UpdateIndex(repoPath)
+	})
+}
+
+// Bring the search index up to date with the comment references,
+// creating the index if needed. New comments are indexed, and amended
+// or deleted comments are replaced or removed.
+// @return result.Result<*IndexSummary, error>
+func UpdateIndex(repoPath string) result.Result {
+	return openIndex(repoPath, true, updateIndex)
+}
+
+// Bring the search index up to date if it exists. Comments are indexed
+// when next searched if this fails.
+// @return result.Result<*IndexSummary, error>
+func RefreshIndex(repoPath string) result.Result {
+	return gc.CommentStoragePath(repoPath).FlatMap(func(storage interface{}) result.Result {
+		if _, err := os.Stat(indexPath(storage.(string))); err != nil {
+			return result.NewSuccess(&IndexSummary{})
+		}
+		return openIndex(repoPath, false, updateIndex)
+	})
+}
+
+// @return result.Result<*IndexSummary, error>
+func updateIndex(repo *git.Repository, indexes *indexSet) result.Result {
+	index := indexes.primary()
+	var sources map[string][]string
+	return commentSources(repo).FlatMap(func(value interface{}) result.Result {
+		sources = value.(map[string][]string)
+		return result.NewResult(index.GetInternal([]byte(indexedCommentsKey)))
+	}).FlatMap(func(content interface{}) result.Result {
+		indexed := make([]string, 0)
+		if len(content.([]byte)) > 0 {
+			if err := json.Unmarshal(content.([]byte), &indexed); err != nil {
+				return result.NewFailure(err)
+			}
+		}
+		current := sourceEntries(sources)
+		added, removed := indexChanges(indexed, current)
+		if len(added)+len(removed) == 0 {
+			return result.NewSuccess(&IndexSummary{})
+		}
+		tokenMaps, err := languageTokenMaps(indexes.languages)
+		if err != nil {
+			return result.NewFailure(err)
+		}
+		summary := &IndexSummary{Added: len(added)}
+		reindexed := make(map[string]bool)
+		for _, entry := range added {
+			reindexed[entryID(entry)] = true
+		}
+		batches := make(map[string]*bleve.Batch)
+		for i, language := range indexes.languages {
+			batches[language] = indexes.indexes[i].NewBatch()
+		}
+		// Comments with changed sources replace their previous entry
+		for _, entry := range removed {
+			if reindexed[entryID(entry)] {
+				continue
+			}
+			summary.Removed++
+			for _, batch := range batches {
+				batch.Delete(entryID(entry))
+			}
+		}
+		for _, entry := range added {
+			id := entryID(entry)
+			comment, err := gc.CommentByID(repo, id).Dematerialize()
+			if err != nil || comment.(*gc.Comment).Deleted {
+				continue
+			}
+			language := detectLanguage(comment.(*gc.Comment).Content, commentLanguage(comment.(*gc.Comment)), indexes.languages, tokenMaps)
+			if err := batches[language].Index(id, commentIndex(comment.(*gc.Comment), sources[id])); err != nil {
+				return result.NewFailure(err)
+			}
+		}
+		state, err := json.Marshal(current)
+		if err != nil {
+			return result.NewFailure(err)
+		}
+		// Record the update in the default index last, so comments are
+		// indexed again if updating another language fails
+		for i := len(indexes.languages) - 1; i > 0; i-- {
+			if err := indexes.indexes[i].Batch(batches[indexes.languages[i]]); err != nil {
+				return result.NewFailure(err)
+			}
+		}
+		batch := batches[indexes.languages[0]]
+		batch.SetInternal([]byte(indexedCommentsKey), state)
+		return gg.BoolResult(true, index.Batch(batch)).FlatMap(func(value interface{}) result.Result {
+			return result.NewSuccess(summary)
+		})
+	})
+}
+
+// Find the comment identifiers which need to be added to or removed
+// from the index
+func indexChanges(indexed, current []string) ([]string, []string) {
+	seen := make(map[string]bool)
+	for _, id := range indexed {
+		seen[id] = true
+	}
+	exists := make(map[string]bool)
+	added := make([]string, 0)
+	for _, id := range current {
+		exists[id] = true
+		if !seen[id] {
+			added = append(added, id)
+		}
+	}
+	removed := make([]string, 0)
+	for _, id := range indexed {
+		if !exists[id] {
+			removed = append(removed, id)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// The path of the search index within the comment storage directory
+func indexPath(storage string) string {
+	return filepath.Join(storage, indexFilePath)
+}
+
+// The path of the index of comments in a language, where the default
+// language is at position zero
+func languageIndexPath(storage string, position int, language string) string {
+	if position == 0 {
+		return indexPath(storage)
+	}
+	return filepath.Join(indexPath(storage), languageIndexDir, language)
+}
+
+// The indexes of comments in each configured search language
+type indexSet struct {
+	languages []string
+	indexes   []bleve.Index
+}
+
+// The index of comments in the default language, which records the
+// state of the index set
+func (s *indexSet) primary() bleve.Index {
+	return s.indexes[0]
+}
+
+// An index searching comments in every language
+func (s *indexSet) searchable() bleve.Index {
+	if len(s.indexes) == 1 {
+		return s.indexes[0]
+	}
+	return bleve.NewIndexAlias(s.indexes...)
+}
+
+func (s *indexSet) Close() {
+	for _, index := range s.indexes {
+		index.Close()
+	}
+}
+
+// Open the search indexes, creating them if allowed. Indexes created
+// with an older mapping or other search languages are rebuilt.
+// @return result.Result<*indexSet, error>
+func openIndex(repoPath string, create bool, ifSuccess func(*git.Repository, *indexSet) result.Result) result.Result {
+	languages, err := parseLanguages(gg.ConfiguredString(repoPath, searchLanguageConfig, defaultSearchLanguage))
+	if err != nil {
+		return result.NewFailure(err)
+	}
+	return gc.CommentStoragePath(repoPath).FlatMap(func(value interface{}) result.Result {
+		storage := value.(string)
+		return gg.WithRepository(repoPath, func(repo *git.Repository) result.Result {
+			os.Mkdir(storage, 0700)
+			success := func(value interface{}) result.Result {
+				indexes := value.(*indexSet)
+				defer indexes.Close()
+				return ifSuccess(repo, indexes)
+			}
+			opened := openIndexSet(storage, languages)
+			if opened.Failure != nil && create {
+				opened = createIndex(storage, languages)
+			}
+			return opened.FlatMap(success)
+		})
+	})
+}
+
+// @return result.Result<*indexSet, error>
+func openIndexSet(storage string, languages []string) result.Result {
+	return result.NewResult(bleve.Open(indexPath(storage))).FlatMap(func(value interface{}) result.Result {
+		indexes := &indexSet{languages, []bleve.Index{value.(bleve.Index)}}
+		version, err := indexes.primary().GetInternal([]byte(indexVersionKey))
+		if err == nil && string(version) != indexVersion {
+			return rebuildIndex(storage, indexes)
+		}
+		configured, err := indexes.primary().GetInternal([]byte(indexLanguagesKey))
+		if err == nil && string(configured) != strings.Join(languages, ",") {
+			return rebuildIndex(storage, indexes)
+		}
+		for position, language := range languages[1:] {
+			index, err := bleve.Open(languageIndexPath(storage, position+1, language))
+			if err != nil {
+				return rebuildIndex(storage, indexes)
+			}
+			indexes.indexes = append(indexes.indexes, index)
+		}
+		return result.NewSuccess(indexes)
+	})
+}
+
+// @return result.Result<*indexSet, error>
+func rebuildIndex(storage string, indexes *indexSet) result.Result {
+	indexes.Close()
+	os.RemoveAll(indexPath(storage))
+	return createIndex(storage, indexes.languages)
+}
+
+// @return result.Result<*indexSet, error>
+func createIndex(storage string, languages []string) result.Result {
+	indexes := &indexSet{languages, make([]bleve.Index, 0, len(languages))}
+	for position, language := range languages {
+		mapping, err := indexMapping(languageAnalyzers[language])
+		if err != nil {
+			indexes.Close()
+			return result.NewFailure(err)
+		}
+		if position == 1 {
+			os.Mkdir(filepath.Join(indexPath(storage), languageIndexDir), 0700)
+		}
+		index, err := bleve.New(languageIndexPath(storage, position, language), mapping)
+		if err != nil {
+			indexes.Close()
+			return result.NewFailure(err)
+		}
+		indexes.indexes = append(indexes.indexes, index)
+	}
+	batch := indexes.primary().NewBatch()
+	batch.SetInternal([]byte(indexVersionKey), []byte(indexVersion))
+	batch.SetInternal([]byte(indexLanguagesKey), []byte(strings.Join(languages, ",")))
+	if err := indexes.primary().Batch(batch); err != nil {
+		indexes.Close()
+		return result.NewFailure(err)
+	}
+	return result.NewSuccess(indexes)
+}
+
+// Map comment content as text analyzed for a language, people, commits,
+// paths and facet values as keywords, and dates and line numbers for
+// range queries. Unfielded search text matches content.
+func indexMapping(contentAnalyzer string) (*bleve.IndexMapping, error) {
+	mapping := bleve.NewIndexMapping()
+	err := mapping.AddCustomAnalyzer(lowercaseAnalyzer, map[string]interface{}{
+		"type":          custom_analyzer.Name,
+		"tokenizer":     single_token.Name,
+		"token_filters": []string{lower_case_filter.Name},
+	})
+	if err != nil {
+		return nil, err
+	}
+	document := bleve.NewDocumentStaticMapping()
+	content := bleve.NewTextFieldMapping()
+	content.Analyzer = contentAnalyzer
+	document.AddFieldMappingsAt(contentField, content)
+	for _, field := range []string{authorField, amenderField} {
+		document.AddFieldMappingsAt(field+personNameSuffix, keywordFieldMapping(lowercaseAnalyzer))
+		document.AddFieldMappingsAt(field+personEmailSuffix, keywordFieldMapping(lowercaseAnalyzer))
+	}
+	document.AddFieldMappingsAt(commitField, keywordFieldMapping(lowercaseAnalyzer))
+	document.AddFieldMappingsAt(pathField, keywordFieldMapping(keyword_analyzer.Name))
+	document.AddFieldMappingsAt(sourcesField, keywordFieldMapping(keyword_analyzer.Name))
+	for _, field := range []string{authorFacetField, directoryFacetField, monthFacetField} {
+		document.AddFieldMappingsAt(field, keywordFieldMapping(keyword_analyzer.Name))
+	}
+	for _, field := range []string{lineField, createdField, amendedField} {
+		fieldMapping := bleve.NewNumericFieldMapping()
+		if field != lineField {
+			fieldMapping = bleve.NewDateTimeFieldMapping()
+		}
+		fieldMapping.IncludeInAll = false
+		document.AddFieldMappingsAt(field, fieldMapping)
+	}
+	mapping.DefaultMapping = document
+	mapping.DefaultField = contentField
+	return mapping, nil
+}
+
+func keywordFieldMapping(analyzer string) *bleve.FieldMapping {
+	fieldMapping := bleve.NewTextFieldMapping()
+	fieldMapping.Analyzer = analyzer
+	fieldMapping.IncludeInAll = false
+	fieldMapping.IncludeTermVectors = false
+	return fieldMapping
+}
+
+func commentIndex(comment *gc.Comment, sources []string) *CommentIndex {
+	index := &CommentIndex{
+		Content:      comment.Content,
+		AuthorName:   comment.Author.Name,
+		AuthorEmail:  comment.Author.Email,
+		AmenderName:  comment.Amender.Name,
+		AmenderEmail: comment.Amender.Email,
+		Commit:       *comment.Commit,
+		Created:      comment.Author.Date,
+		Amended:      comment.Amender.Date,
+		AuthorFacet:  comment.Author.Name,
+		MonthFacet:   comment.Author.Date.Format(monthFormat),
+		Sources:      sources,
+	}
+	if comment.FileRef != nil {
+		line := float64(comment.FileRef.Line)
+		index.Path = comment.FileRef.Path
+		index.Line = &line
+		index.DirectoryFacet = topDirectory(comment.FileRef.Path)
+	}
+	return index
+}
@@ -0,0 +1,89 @@
+package search
+
+import (
+	bleve_search "github.com/blevesearch/bleve/search"
+	"github.com/stvp/assert"
+	"testing"
+	"time"
+)
+
+func TestIndexChangesNewIndex(t *testing.T) {
+	added, removed := indexChanges([]string{}, []string{"c", "a"})
+	assert.Equal(t, added, []string{"a", "c"})
+	assert.Equal(t, len(removed), 0)
+}
+
+func TestIndexChangesUpToDate(t *testing.T) {
+	added, removed := indexChanges([]string{"a", "b"}, []string{"b", "a"})
+	assert.Equal(t, len(added), 0)
+	assert.Equal(t, len(removed), 0)
+}
+
+func TestIndexChangesAmended(t *testing.T) {
+	added, removed := indexChanges([]string{"a", "b"}, []string{"a", "d"})
+	assert.Equal(t, added, []string{"d"})
+	assert.Equal(t, removed, []string{"b"})
+}
+
+func TestIndexChangesRemoved(t *testing.T) {
+	added, removed := indexChanges([]string{"b", "a"}, []string{})
+	assert.Equal(t, len(added), 0)
+	assert.Equal(t, removed, []string{"a", "b"})
+}
+
+func documentMatch(name string, unix int64) *bleve_search.DocumentMatch {
+	fields := map[string]interface{}{
+		createdField:    time.Unix(unix, 0).UTC().Format(time.RFC3339),
+		authorNameField: name,
+	}
+	return &bleve_search.DocumentMatch{ID: name, Score: 1, Fields: fields}
+}
+
+func TestSortMatchesByScore(t *testing.T) {
+	a, b := documentMatch("Bo", 1437498000), documentMatch("Al", 1437499000)
+	matches := bleve_search.DocumentMatchCollection{a, b}
+	sortMatches(matches, SortByScore)
+	assert.Equal(t, matches, bleve_search.DocumentMatchCollection{a, b})
+}
+
+func TestSortMatchesByDate(t *testing.T) {
+	a, b, c := documentMatch("Bo", 1437498000), documentMatch("Al", 1437499000), documentMatch("Cy", 1437497000)
+	matches := bleve_search.DocumentMatchCollection{a, b, c}
+	sortMatches(matches, SortByDate)
+	assert.Equal(t, matches, bleve_search.DocumentMatchCollection{b, a, c})
+}
+
+func TestSortMatchesByAuthor(t *testing.T) {
+	a, b, c := documentMatch("bo", 1437498000), documentMatch("Al", 1437497000), documentMatch("Bo", 1437499000)
+	matches := bleve_search.DocumentMatchCollection{a, b, c}
+	sortMatches(matches, SortByAuthor)
+	assert.Equal(t, matches, bleve_search.DocumentMatchCollection{b, c, a})
+}
+
+func TestPageMatches(t *testing.T) {
+	a, b, c := documentMatch("A", 1), documentMatch("B", 2), documentMatch("C", 3)
+	matches := bleve_search.DocumentMatchCollection{a, b, c}
+	assert.Equal(t, pageMatches(matches, 0, 0), matches)
+	assert.Equal(t, pageMatches(matches, 1, 1), bleve_search.DocumentMatchCollection{b})
+	assert.Equal(t, pageMatches(matches, 2, 5), bleve_search.DocumentMatchCollection{c})
+	assert.Equal(t, len(pageMatches(matches, 3, 1)), 0)
+}
+
+func TestSearchRequestPagesByScore(t *testing.T) {
+	query, err := ParseQuery("parser")
+	assert.Nil(t, err)
+	request := searchRequest(query, &SearchOptions{Sort: SortByScore, Offset: 10, Limit: 5}, 100)
+	assert.Equal(t, request.From, 10)
+	assert.Equal(t, request.Size, 5)
+	assert.NotNil(t, request.Highlight)
+}
+
+func TestSearchRequestSortedByFields(t *testing.T) {
+	query, err := ParseQuery("parser")
+	assert.Nil(t, err)
+	request := searchRequest(query, &SearchOptions{Sort: SortByDate, Offset: 10, Limit: 5}, 100)
+	assert.Equal(t, request.From, 0)
+	assert.Equal(t, request.Size, 100)
+	assert.Equal(t, request.Fields, []string{sourcesField, createdField, authorNameField})
+	assert.Nil(t, request.Highlight)
+}
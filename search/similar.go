@@ -0,0 +1,463 @@
+package search
+
+import (
+	"fmt"
+	gg "git"
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/registry"
+	"github.com/kylef/result.go/src/result"
+	git "gopkg.in/libgit2/git2go.v23"
+	gc "libgitcomment"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	// Similarity above which a new comment nearly duplicates another
+	DuplicateSimilarity = 0.8
+
+	// Number of comments sharing the most with a comment which are
+	// compared with it in full
+	maxSimilarCandidates = 200
+	// Fraction of comments above which a term is too common to compare,
+	// once there are enough comments for the fraction to be meaningful
+	commonTermRatio        = 0.5
+	commonTermMinDocuments = 20
+
+	unknownCommentError   = "No comment matches '%v'"
+	ambiguousCommentError = "'%v' matches more than one comment"
+	unindexedCommentError = "Comment %v has no searchable content"
+)
+
+// Options controlling which similar comments are returned
+type SimilarOptions struct {
+	// Minimum similarity of the comments returned, from zero to one
+	MinSimilarity float64
+	// Maximum number of comments to return, or zero for all
+	Limit int
+}
+
+// A comment with content similar to another
+type SimilarComment struct {
+	Comment *gc.Comment
+	// Similarity of the comment content from zero to one, where one
+	// means the comments use the same words
+	Similarity float64
+	// Where the comment was found, LocalSource or remote names
+	Sources []string
+}
+
+// Find the comments with content most similar to a comment, identified
+// by its ID or an unambiguous prefix. Content is compared by the words
+// indexed for each comment, where words used more often within a comment
+// and words used by fewer comments count for more, and words used by
+// most comments are ignored.
+// @return result.Result<[]*SimilarComment, error>
+func SimilarComments(repoPath, identifier string, options *SimilarOptions) result.Result {
+	return openIndex(repoPath, true, func(repo *git.Repository, indexes *indexSet) result.Result {
+		var sources map[string][]string
+		return updateIndex(repo, indexes).FlatMap(func(value interface{}) result.Result {
+			return commentSources(repo)
+		}).FlatMap(func(value interface{}) result.Result {
+			sources = value.(map[string][]string)
+			return resolveCommentID(identifier, sources)
+		}).FlatMap(func(id interface{}) result.Result {
+			return withReaders(indexes, func(readers []index.IndexReader) result.Result {
+				return similarDocuments(readers, id.(string))
+			})
+		}).FlatMap(func(value interface{}) result.Result {
+			similar := make([]*SimilarComment, 0)
+			for id, similarity := range value.(map[string]float64) {
+				if similarity < options.MinSimilarity {
+					continue
+				}
+				gc.CommentByID(repo, id).FlatMap(func(comment interface{}) result.Result {
+					similar = append(similar, &SimilarComment{comment.(*gc.Comment), similarity, sources[id]})
+					return result.Result{}
+				})
+			}
+			sort.Sort(bySimilarity(similar))
+			if options.Limit > 0 && options.Limit < len(similar) {
+				similar = similar[:options.Limit]
+			}
+			return result.NewSuccess(similar)
+		})
+	})
+}
+
+// Find the comments on the same commit, file and line as new content
+// which nearly duplicate it
+// @return result.Result<[]*SimilarComment, error>
+func DuplicateComments(repoPath, commit string, fileRef *gc.FileRef, content, language string) result.Result {
+	languages, err := parseLanguages(gg.ConfiguredString(repoPath, searchLanguageConfig, defaultSearchLanguage))
+	if err != nil {
+		return result.NewFailure(err)
+	}
+	tokenMaps, err := languageTokenMaps(languages)
+	if err != nil {
+		return result.NewFailure(err)
+	}
+	cache := registry.NewCache()
+	terms, err := contentTerms(cache, content, language, languages, tokenMaps)
+	if err != nil {
+		return result.NewFailure(err)
+	}
+	return gc.CommentsOnCommit(repoPath, commit).FlatMap(func(value interface{}) result.Result {
+		duplicates := make([]*SimilarComment, 0)
+		for _, c := range value.([]interface{}) {
+			comment := c.(*gc.Comment)
+			if comment.Deleted || !sameFileRef(comment.FileRef, fileRef) {
+				continue
+			}
+			existing, err := contentTerms(cache, comment.Content, commentLanguage(comment), languages, tokenMaps)
+			if err != nil {
+				return result.NewFailure(err)
+			}
+			if similarity := termSimilarity(termFrequencies(terms), termFrequencies(existing), nil); similarity >= DuplicateSimilarity {
+				duplicates = append(duplicates, &SimilarComment{comment, similarity, []string{LocalSource}})
+			}
+		}
+		sort.Sort(bySimilarity(duplicates))
+		return result.NewSuccess(duplicates)
+	})
+}
+
+// Find the full identifier of an indexed comment from a prefix
+// @return result.Result<string, error>
+func resolveCommentID(identifier string, sources map[string][]string) result.Result {
+	matched := make([]string, 0, 1)
+	for id := range sources {
+		if len(identifier) > 0 && strings.HasPrefix(id, identifier) {
+			matched = append(matched, id)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return result.NewFailure(fmt.Errorf(unknownCommentError, identifier))
+	case 1:
+		return result.NewSuccess(matched[0])
+	}
+	return result.NewFailure(fmt.Errorf(ambiguousCommentError, identifier))
+}
+
+// Perform a block with a reader of each index, closing them afterward
+func withReaders(indexes *indexSet, ifSuccess func([]index.IndexReader) result.Result) result.Result {
+	readers := make([]index.IndexReader, 0, len(indexes.indexes))
+	defer func() {
+		for _, reader := range readers {
+			reader.Close()
+		}
+	}()
+	for _, searchIndex := range indexes.indexes {
+		advanced, _, err := searchIndex.Advanced()
+		if err != nil {
+			return result.NewFailure(err)
+		}
+		reader, err := advanced.Reader()
+		if err != nil {
+			return result.NewFailure(err)
+		}
+		readers = append(readers, reader)
+	}
+	return ifSuccess(readers)
+}
+
+// Measure the similarity of the documents sharing the most terms with a
+// document. The postings of each of the document's terms are read once,
+// skipping terms too common to tell documents apart, and only the
+// documents sharing the most weight with it are compared in full.
+// @return result.Result<map[string]float64, error>
+func similarDocuments(readers []index.IndexReader, id string) result.Result {
+	terms, err := documentTerms(readers, id)
+	if err != nil {
+		return result.NewFailure(err)
+	} else if len(terms) == 0 {
+		return result.NewFailure(fmt.Errorf(unindexedCommentError, id))
+	}
+	postings := newTermPostings(readers)
+	distinctive, err := postings.distinctiveTerms(terms)
+	if err != nil {
+		return result.NewFailure(err)
+	}
+	documents := make(map[string]map[string]float64)
+	for _, term := range distinctive {
+		err := postings.each(term, func(doc string, frequency float64) {
+			if _, ok := documents[doc]; !ok {
+				documents[doc] = make(map[string]float64)
+			}
+			documents[doc][term] = frequency
+		})
+		if err != nil {
+			return result.NewFailure(err)
+		}
+	}
+	frequencies := documents[id]
+	delete(documents, id)
+	candidates := make(sharedWeights, 0, len(documents))
+	for candidate, shared := range documents {
+		weight := 0.0
+		for term, frequency := range shared {
+			weight += frequency * frequencies[term] * postings.weight(term) * postings.weight(term)
+		}
+		candidates = append(candidates, &sharedWeight{candidate, weight})
+	}
+	sort.Sort(candidates)
+	if len(candidates) > maxSimilarCandidates {
+		candidates = candidates[:maxSimilarCandidates]
+	}
+	similarities := make(map[string]float64)
+	for _, candidate := range candidates {
+		candidateFrequencies, err := postings.documentFrequencies(candidate.id, documents[candidate.id])
+		if err != nil {
+			return result.NewFailure(err)
+		}
+		similarities[candidate.id] = termSimilarity(frequencies, candidateFrequencies, postings.weight)
+	}
+	return result.NewSuccess(similarities)
+}
+
+// The content terms indexed for a document
+func documentTerms(readers []index.IndexReader, id string) ([]string, error) {
+	for _, reader := range readers {
+		fieldTerms, err := reader.DocumentFieldTerms(id)
+		if err != nil {
+			return nil, err
+		} else if terms := fieldTerms[contentField]; len(terms) > 0 {
+			return terms, nil
+		}
+	}
+	return []string{}, nil
+}
+
+// The number of documents containing each content term, and the
+// documents using a term and how often, read as needed
+type termPostings struct {
+	readers []index.IndexReader
+	count   float64
+	counts  map[string]float64
+	// Whether common terms are compared, when every term of the
+	// document being compared is common
+	keepCommon bool
+}
+
+func newTermPostings(readers []index.IndexReader) *termPostings {
+	count := uint64(0)
+	for _, reader := range readers {
+		count += reader.DocCount()
+	}
+	return &termPostings{readers, float64(count), make(map[string]float64), false}
+}
+
+// The terms which are not too common to compare, or every term if
+// they are all common
+func (p *termPostings) distinctiveTerms(terms []string) ([]string, error) {
+	distinctive := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if common, err := p.common(term); err != nil {
+			return nil, err
+		} else if !common {
+			distinctive = append(distinctive, term)
+		}
+	}
+	if len(distinctive) == 0 {
+		p.keepCommon = true
+		return terms, nil
+	}
+	return distinctive, nil
+}
+
+// Whether a term is used by so many documents that it does not tell
+// them apart
+func (p *termPostings) common(term string) (bool, error) {
+	count, err := p.documentCount(term)
+	if err != nil || p.keepCommon || p.count < commonTermMinDocuments {
+		return false, err
+	}
+	return count > p.count*commonTermRatio, nil
+}
+
+// The number of documents using a term, which is read from the term
+// dictionary without reading its postings
+func (p *termPostings) documentCount(term string) (float64, error) {
+	if count, ok := p.counts[term]; ok {
+		return count, nil
+	}
+	count := uint64(0)
+	for _, reader := range p.readers {
+		termReader, err := reader.TermFieldReader([]byte(term), contentField)
+		if err != nil {
+			return 0, err
+		}
+		count += termReader.Count()
+		termReader.Close()
+	}
+	p.counts[term] = float64(count)
+	return float64(count), nil
+}
+
+// Provide each document using a term and the number of times it does
+func (p *termPostings) each(term string, found func(id string, frequency float64)) error {
+	for _, reader := range p.readers {
+		termReader, err := reader.TermFieldReader([]byte(term), contentField)
+		if err != nil {
+			return err
+		}
+		for doc, err := termReader.Next(); doc != nil || err != nil; doc, err = termReader.Next() {
+			if err != nil {
+				termReader.Close()
+				return err
+			}
+			found(doc.ID, float64(doc.Freq))
+		}
+		termReader.Close()
+	}
+	return nil
+}
+
+// The number of times a document uses each of its terms which are not
+// too common to compare, given the frequencies already known
+func (p *termPostings) documentFrequencies(id string, known map[string]float64) (map[string]float64, error) {
+	terms, err := documentTerms(p.readers, id)
+	if err != nil {
+		return nil, err
+	}
+	frequencies := make(map[string]float64)
+	for _, term := range terms {
+		if frequency, ok := known[term]; ok {
+			frequencies[term] = frequency
+		} else if common, err := p.common(term); err != nil {
+			return nil, err
+		} else if !common {
+			if frequencies[term], err = p.frequency(term, id); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return frequencies, nil
+}
+
+// The number of times a document uses a term, seeking to the document
+// within the postings of the term
+func (p *termPostings) frequency(term, id string) (float64, error) {
+	for _, reader := range p.readers {
+		termReader, err := reader.TermFieldReader([]byte(term), contentField)
+		if err != nil {
+			return 0, err
+		}
+		doc, err := termReader.Advance(id)
+		termReader.Close()
+		if err != nil {
+			return 0, err
+		} else if doc != nil && doc.ID == id {
+			return float64(doc.Freq), nil
+		}
+	}
+	return 0, nil
+}
+
+// Weight a term by how rarely it is used
+func (p *termPostings) weight(term string) float64 {
+	if count := p.counts[term]; count > 0 {
+		return 1 + math.Log(p.count/count)
+	}
+	return 1
+}
+
+// A document and the weight of the terms it shares with another
+type sharedWeight struct {
+	id     string
+	weight float64
+}
+
+type sharedWeights []*sharedWeight
+
+func (s sharedWeights) Len() int {
+	return len(s)
+}
+
+func (s sharedWeights) Less(i, j int) bool {
+	if s[i].weight == s[j].weight {
+		return s[i].id < s[j].id
+	}
+	return s[i].weight > s[j].weight
+}
+
+func (s sharedWeights) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// The terms of content analyzed in its given or detected language
+func contentTerms(cache *registry.Cache, content, given string, languages []string, tokenMaps map[string]analysis.TokenMap) ([]string, error) {
+	language := detectLanguage(content, given, languages, tokenMaps)
+	analyzer, err := cache.AnalyzerNamed(languageAnalyzers[language])
+	if err != nil {
+		return nil, err
+	}
+	terms := make([]string, 0)
+	for _, token := range analyzer.Analyze([]byte(content)) {
+		terms = append(terms, string(token.Term))
+	}
+	return terms, nil
+}
+
+// The cosine similarity of the term frequencies of two documents, each
+// term weighted by a function or equally if it is nil
+func termSimilarity(a, b map[string]float64, weight func(string) float64) float64 {
+	if weight == nil {
+		weight = func(string) float64 { return 1 }
+	}
+	shared, normA, normB := 0.0, 0.0, 0.0
+	for term, frequency := range a {
+		w := weight(term)
+		normA += frequency * frequency * w * w
+		shared += frequency * b[term] * w * w
+	}
+	for term, frequency := range b {
+		w := weight(term)
+		normB += frequency * frequency * w * w
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return shared / math.Sqrt(normA*normB)
+}
+
+// The number of times each term is used
+func termFrequencies(terms []string) map[string]float64 {
+	frequencies := make(map[string]float64)
+	for _, term := range terms {
+		frequencies[term]++
+	}
+	return frequencies
+}
+
+// Whether comments are on the same file and line, or both on a whole
+// commit
+func sameFileRef(a, b *gc.FileRef) bool {
+	wholeCommit := func(ref *gc.FileRef) bool {
+		return ref == nil || len(ref.Path) == 0
+	}
+	if wholeCommit(a) || wholeCommit(b) {
+		return wholeCommit(a) && wholeCommit(b)
+	}
+	return a.Path == b.Path && a.Line == b.Line && a.LineType == b.LineType
+}
+
+type bySimilarity []*SimilarComment
+
+func (s bySimilarity) Len() int {
+	return len(s)
+}
+
+func (s bySimilarity) Less(i, j int) bool {
+	if s[i].Similarity == s[j].Similarity {
+		return s[i].Comment.Author.Date.After(s[j].Comment.Author.Date)
+	}
+	return s[i].Similarity > s[j].Similarity
+}
+
+func (s bySimilarity) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
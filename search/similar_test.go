@@ -0,0 +1,98 @@
+package search
+
+import (
+	"github.com/stvp/assert"
+	gc "libgitcomment"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestTermSimilarityIdentical(t *testing.T) {
+	assert.Equal(t, termSimilarity(termFrequencies([]string{"miss", "error", "check"}), termFrequencies([]string{"check", "error", "miss"}), nil), 1.0)
+}
+
+func TestTermSimilarityDisjoint(t *testing.T) {
+	assert.Equal(t, termSimilarity(termFrequencies([]string{"use", "defer"}), termFrequencies([]string{"miss", "check"}), nil), 0.0)
+}
+
+func TestTermSimilarityEmpty(t *testing.T) {
+	assert.Equal(t, termSimilarity(termFrequencies([]string{}), termFrequencies([]string{"miss"}), nil), 0.0)
+}
+
+func TestTermSimilarityWeighted(t *testing.T) {
+	weight := func(term string) float64 {
+		if term == "defer" {
+			return 3
+		}
+		return 1
+	}
+	a, b := termFrequencies([]string{"use", "defer"}), termFrequencies([]string{"should", "defer"})
+	assert.Equal(t, termSimilarity(a, b, nil), 0.5)
+	assert.Equal(t, termSimilarity(a, b, weight), 0.9)
+}
+
+func TestTermSimilarityFrequency(t *testing.T) {
+	a := termFrequencies([]string{"defer", "defer", "defer", "use"})
+	b := termFrequencies([]string{"defer", "should"})
+	assert.True(t, termSimilarity(a, b, nil) > termSimilarity(termFrequencies([]string{"defer", "use"}), b, nil))
+}
+
+func TestResolveCommentID(t *testing.T) {
+	sources := map[string][]string{"abc123": {"local"}, "abd456": {"origin"}}
+	id, err := resolveCommentID("abd", sources).Dematerialize()
+	assert.Nil(t, err)
+	assert.Equal(t, id, "abd456")
+	assert.NotNil(t, resolveCommentID("ab", sources).Failure)
+	assert.NotNil(t, resolveCommentID("fff", sources).Failure)
+	assert.NotNil(t, resolveCommentID("", sources).Failure)
+}
+
+func TestSameFileRef(t *testing.T) {
+	ref := &gc.FileRef{Path: "src/a.c", Line: 3}
+	assert.True(t, sameFileRef(nil, nil))
+	assert.True(t, sameFileRef(nil, &gc.FileRef{}))
+	assert.True(t, sameFileRef(ref, &gc.FileRef{Path: "src/a.c", Line: 3}))
+	assert.False(t, sameFileRef(ref, &gc.FileRef{Path: "src/a.c", Line: 4}))
+	assert.False(t, sameFileRef(ref, &gc.FileRef{Path: "src/a.c", Line: 3, LineType: gc.RefLineTypeOld}))
+	assert.False(t, sameFileRef(ref, nil))
+}
+
+func TestSortBySimilarity(t *testing.T) {
+	similar := func(similarity float64, unix int64) *SimilarComment {
+		author := &gc.Person{Date: time.Unix(unix, 0)}
+		return &SimilarComment{&gc.Comment{Author: author}, similarity, nil}
+	}
+	a, b, c := similar(0.5, 1437498000), similar(0.9, 1437498000), similar(0.5, 1437499000)
+	comments := []*SimilarComment{a, b, c}
+	sort.Sort(bySimilarity(comments))
+	assert.Equal(t, comments, []*SimilarComment{b, c, a})
+}
+
+func TestSortSharedWeights(t *testing.T) {
+	a, b, c := &sharedWeight{"b2", 0.5}, &sharedWeight{"a1", 2}, &sharedWeight{"a3", 0.5}
+	weights := sharedWeights{a, b, c}
+	sort.Sort(weights)
+	assert.Equal(t, weights, sharedWeights{b, c, a})
+}
+
+func TestTermPostingsWeight(t *testing.T) {
+	postings := &termPostings{count: 40, counts: map[string]float64{"defer": 40, "mutex": 4}}
+	assert.Equal(t, postings.weight("defer"), 1.0)
+	assert.True(t, postings.weight("mutex") > postings.weight("defer"))
+	assert.Equal(t, postings.weight("unknown"), 1.0)
+}
+
+func TestTermPostingsCommon(t *testing.T) {
+	postings := &termPostings{count: 40, counts: map[string]float64{"defer": 30, "mutex": 4}}
+	terms, err := postings.distinctiveTerms([]string{"defer", "mutex"})
+	assert.Nil(t, err)
+	assert.Equal(t, terms, []string{"mutex"})
+	terms, err = postings.distinctiveTerms([]string{"defer"})
+	assert.Nil(t, err)
+	assert.Equal(t, terms, []string{"defer"})
+	small := &termPostings{count: 4, counts: map[string]float64{"defer": 3}}
+	common, err := small.common("defer")
+	assert.Nil(t, err)
+	assert.False(t, common)
+}
@@ -0,0 +1,57 @@
+package search
+
+import (
+	"fmt"
+	"github.com/blevesearch/bleve/registry"
+	"github.com/blevesearch/bleve/search/highlight"
+	simple_fragmenter "github.com/blevesearch/bleve/search/highlight/fragmenters/simple"
+	simple_highlighter "github.com/blevesearch/bleve/search/highlight/highlighters/simple"
+	"strings"
+)
+
+const (
+	// Name of the highlighter producing comment snippets
+	snippetHighlighter = "git-comment-snippet"
+	// Marks the start of a matched term within a snippet
+	MatchStart = "\x02"
+	// Marks the end of a matched term within a snippet
+	MatchEnd = "\x03"
+)
+
+func init() {
+	registry.RegisterHighlighter(snippetHighlighter, newSnippetHighlighter)
+}
+
+func newSnippetHighlighter(config map[string]interface{}, cache *registry.Cache) (highlight.Highlighter, error) {
+	fragmenter, err := cache.FragmenterNamed(simple_fragmenter.Name)
+	if err != nil {
+		return nil, fmt.Errorf("error building fragmenter: %v", err)
+	}
+	return simple_highlighter.NewHighlighter(fragmenter, &snippetFormatter{}, simple_highlighter.DefaultSeparator), nil
+}
+
+// Formats fragments of comment content, surrounding matched terms with
+// MatchStart and MatchEnd so they can be presented as needed
+type snippetFormatter struct{}
+
+func (f *snippetFormatter) Format(fragment *highlight.Fragment, locations highlight.TermLocations) string {
+	snippet := ""
+	current := fragment.Start
+	for _, location := range locations {
+		if location == nil || location.Start < current {
+			continue
+		} else if location.End > fragment.End {
+			break
+		}
+		snippet += string(fragment.Orig[current:location.Start])
+		snippet += MatchStart + string(fragment.Orig[location.Start:location.End]) + MatchEnd
+		current = location.End
+	}
+	snippet += string(fragment.Orig[current:fragment.End])
+	return snippet
+}
+
+// Collapse the whitespace of a snippet so it fits on a single line
+func snippetLine(snippet string) string {
+	return strings.Join(strings.Fields(snippet), " ")
+}
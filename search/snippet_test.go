@@ -0,0 +1,27 @@
+package search
+
+import (
+	"github.com/blevesearch/bleve/search/highlight"
+	"github.com/stvp/assert"
+	"testing"
+)
+
+func TestFormatSnippet(t *testing.T) {
+	content := []byte("the parser leaks")
+	fragment := &highlight.Fragment{Orig: content, Start: 0, End: len(content)}
+	locations := highlight.TermLocations{&highlight.TermLocation{Term: "parser", Start: 4, End: 10}}
+	snippet := (&snippetFormatter{}).Format(fragment, locations)
+	assert.Equal(t, snippet, "the "+MatchStart+"parser"+MatchEnd+" leaks")
+}
+
+func TestFormatSnippetOutsideFragment(t *testing.T) {
+	content := []byte("the parser leaks")
+	fragment := &highlight.Fragment{Orig: content, Start: 0, End: 8}
+	locations := highlight.TermLocations{&highlight.TermLocation{Term: "parser", Start: 4, End: 10}}
+	snippet := (&snippetFormatter{}).Format(fragment, locations)
+	assert.Equal(t, snippet, "the pars")
+}
+
+func TestSnippetLine(t *testing.T) {
+	assert.Equal(t, snippetLine("Title\n\n  the parser\tleaks\n"), "Title the parser leaks")
+}
@@ -0,0 +1,118 @@
+package search
+
+import (
+	gg "git"
+	"github.com/kylef/result.go/src/result"
+	git "gopkg.in/libgit2/git2go.v23"
+	gc "libgitcomment"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// Source of comments in the local comment namespace, as opposed to
+	// the remote-tracking namespace of a remote
+	LocalSource = "local"
+
+	sourcesField = "sources"
+	// Separates a comment identifier from its sources in the index state
+	sourcesSeparator = ":"
+)
+
+// Find the comments in the local namespace and the remote-tracking
+// namespace of each remote, along with the sources each comment was
+// found in, either LocalSource or remote names
+// @return result.Result<map[string][]string, error>
+func commentSources(repo *git.Repository) result.Result {
+	sources := make(map[string][]string)
+	addSource := func(source string) func(*git.Reference) {
+		return func(ref *git.Reference) {
+			if id := path.Base(ref.Name()); ref.Target() != nil && ref.Target().String() == id {
+				sources[id] = append(sources[id], source)
+			}
+		}
+	}
+	return gg.CommentRefIterator(repo, addSource(LocalSource)).FlatMap(func(value interface{}) result.Result {
+		return result.NewResult(repo.Remotes.List())
+	}).FlatMap(func(names interface{}) result.Result {
+		for _, name := range names.([]string) {
+			if iterated := gg.RemoteCommentRefIterator(repo, name, addSource(name)); iterated.Failure != nil {
+				return iterated
+			}
+		}
+		return result.NewSuccess(sources)
+	})
+}
+
+// Find the comments in every namespace, each only once, along with
+// their sources
+// @return result.Result<[]*sourcedComment, error>
+func sourcedComments(repo *git.Repository) result.Result {
+	return commentSources(repo).FlatMap(func(value interface{}) result.Result {
+		sources := value.(map[string][]string)
+		comments := make(gc.CommentSlice, 0, len(sources))
+		for id := range sources {
+			gc.CommentByID(repo, id).FlatMap(func(comment interface{}) result.Result {
+				comments = append(comments, comment.(*gc.Comment))
+				return result.Result{}
+			})
+		}
+		sort.Stable(comments)
+		found := make([]*sourcedComment, 0, len(comments))
+		for _, comment := range comments {
+			found = append(found, &sourcedComment{comment, sources[*comment.ID]})
+		}
+		return result.NewSuccess(found)
+	})
+}
+
+// A comment and where it was found
+type sourcedComment struct {
+	comment *gc.Comment
+	sources []string
+}
+
+// The entries of the index state, each a comment identifier and its
+// sources, so comments are indexed again when their sources change
+func sourceEntries(sources map[string][]string) []string {
+	entries := make([]string, 0, len(sources))
+	for id, names := range sources {
+		sorted := append([]string{}, names...)
+		sort.Strings(sorted)
+		entries = append(entries, id+sourcesSeparator+strings.Join(sorted, ","))
+	}
+	return entries
+}
+
+// The comment identifier of an index state entry
+func entryID(entry string) string {
+	return strings.SplitN(entry, sourcesSeparator, 2)[0]
+}
+
+// Whether a comment was found in a source
+func hasSource(sources []string, source string) bool {
+	for _, name := range sources {
+		if name == source {
+			return true
+		}
+	}
+	return false
+}
+
+// Read the sources stored with a search hit
+func storedSources(value interface{}) []string {
+	switch stored := value.(type) {
+	case string:
+		return []string{stored}
+	case []interface{}:
+		sources := make([]string, 0, len(stored))
+		for _, source := range stored {
+			if name, ok := source.(string); ok {
+				sources = append(sources, name)
+			}
+		}
+		return sources
+	}
+	return []string{}
+}
@@ -0,0 +1,40 @@
+package search
+
+import (
+	"github.com/stvp/assert"
+	"sort"
+	"testing"
+)
+
+func TestSourceEntries(t *testing.T) {
+	entries := sourceEntries(map[string][]string{
+		"a": {"origin", "local"},
+		"b": {"upstream"},
+	})
+	sort.Strings(entries)
+	assert.Equal(t, entries, []string{"a:local,origin", "b:upstream"})
+}
+
+func TestSourceEntriesChanged(t *testing.T) {
+	indexed := sourceEntries(map[string][]string{"a": {"local"}, "b": {"local"}})
+	current := sourceEntries(map[string][]string{"a": {"local", "origin"}, "b": {"local"}})
+	added, removed := indexChanges(indexed, current)
+	assert.Equal(t, added, []string{"a:local,origin"})
+	assert.Equal(t, removed, []string{"a:local"})
+}
+
+func TestEntryID(t *testing.T) {
+	assert.Equal(t, entryID("a:local,origin"), "a")
+	assert.Equal(t, entryID("a"), "a")
+}
+
+func TestHasSource(t *testing.T) {
+	assert.True(t, hasSource([]string{"local", "origin"}, "origin"))
+	assert.False(t, hasSource([]string{"local"}, "origin"))
+}
+
+func TestStoredSources(t *testing.T) {
+	assert.Equal(t, storedSources("origin"), []string{"origin"})
+	assert.Equal(t, storedSources([]interface{}{"local", "origin"}), []string{"local", "origin"})
+	assert.Equal(t, storedSources(nil), []string{})
+}